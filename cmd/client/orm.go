@@ -0,0 +1,166 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/cobra"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/bhojpur/dbm/pkg/orm/name"
+	"github.com/bhojpur/dbm/pkg/orm/reverse"
+	"github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+var ormCmd = &cobra.Command{
+	Use:   "orm",
+	Short: "Works with the databases through the Bhojpur DBM object relational mapper",
+}
+
+var ormReverseCmdOpts struct {
+	Package      string
+	Output       string
+	TableMapper  string
+	ColumnMapper string
+	TablePrefix  string
+	TagName      string
+	Template     string
+	Tables       []string
+}
+
+var ormReverseCmd = &cobra.Command{
+	Use:   "reverse <driver> <data source>",
+	Short: "Generates the Go structs with orm tags of the tables of a database",
+	Args:  cobra.ExactArgs(2),
+	// the errors are printed by Execute, without the usage
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options, err := ormReverseOptions()
+		if err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+		engine, err := orm.NewEngine(args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("cannot open database: %w", err)
+		}
+		defer engine.Close()
+		tables, err := engine.DBMetas()
+		if err != nil {
+			return fmt.Errorf("cannot read database schema: %w", err)
+		}
+		tables = filterTables(tables, ormReverseCmdOpts.Tables)
+
+		// generate before touching the output so that a failure keeps the
+		// existing file
+		var buf bytes.Buffer
+		if err := reverse.Generate(&buf, tables, options); err != nil {
+			return fmt.Errorf("cannot generate structs: %w", err)
+		}
+		if ormReverseCmdOpts.Output == "" || ormReverseCmdOpts.Output == "-" {
+			_, err = buf.WriteTo(os.Stdout)
+			return err
+		}
+		if err := os.WriteFile(ormReverseCmdOpts.Output, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("cannot write output file: %w", err)
+		}
+		return nil
+	},
+}
+
+// ormReverseOptions returns the generator options of the flags
+func ormReverseOptions() (*reverse.Options, error) {
+	tableMapper, err := nameMapper(ormReverseCmdOpts.TableMapper)
+	if err != nil {
+		return nil, err
+	}
+	if ormReverseCmdOpts.TablePrefix != "" {
+		tableMapper = name.NewPrefixMapper(tableMapper, ormReverseCmdOpts.TablePrefix)
+	}
+	columnMapper, err := nameMapper(ormReverseCmdOpts.ColumnMapper)
+	if err != nil {
+		return nil, err
+	}
+	options := &reverse.Options{
+		Package:      ormReverseCmdOpts.Package,
+		TableMapper:  tableMapper,
+		ColumnMapper: columnMapper,
+		TagName:      ormReverseCmdOpts.TagName,
+	}
+	if ormReverseCmdOpts.Template != "" {
+		if options.Template, err = template.ParseFiles(ormReverseCmdOpts.Template); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// nameMapper returns the mapper of pkg/orm/name with the name
+func nameMapper(mapper string) (name.Mapper, error) {
+	switch mapper {
+	case "snake":
+		return name.SnakeMapper{}, nil
+	case "same":
+		return name.SameMapper{}, nil
+	case "gonic":
+		return name.LintGonicMapper, nil
+	}
+	return nil, fmt.Errorf("unknown name mapper %q, valid values are snake, same or gonic", mapper)
+}
+
+// filterTables returns the tables with the names, all of them if names is empty
+func filterTables(tables []*schema.Table, names []string) []*schema.Table {
+	if len(names) == 0 {
+		return tables
+	}
+	var res []*schema.Table
+	for _, table := range tables {
+		for _, tableName := range names {
+			if strings.EqualFold(table.Name, tableName) {
+				res = append(res, table)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func init() {
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.Package, "package", reverse.DefaultOptions.Package, "name of the package of the generated file")
+	ormReverseCmd.Flags().StringVarP(&ormReverseCmdOpts.Output, "output", "o", "-", "file the structs are written to, - is the standard output")
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.TableMapper, "table-mapper", "snake", "maps the table names to the struct names. Valid values are \"snake\", \"same\" or \"gonic\"")
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.ColumnMapper, "column-mapper", "snake", "maps the column names to the field names. Valid values are \"snake\", \"same\" or \"gonic\"")
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.TablePrefix, "table-prefix", "", "prefix of the table names left out of the struct names")
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.TagName, "tag", reverse.DefaultOptions.TagName, "key of the struct tags")
+	ormReverseCmd.Flags().StringVar(&ormReverseCmdOpts.Template, "template", "", "Go template file executed with the tables instead of the default one")
+	ormReverseCmd.Flags().StringSliceVar(&ormReverseCmdOpts.Tables, "tables", nil, "names of the tables to generate, all the tables if empty")
+	ormCmd.AddCommand(ormReverseCmd)
+	rootCmd.AddCommand(ormCmd)
+}
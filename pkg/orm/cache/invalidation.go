@@ -0,0 +1,214 @@
+package cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// enumerates the invalidation operations
+const (
+	// InvalidateIds clears the sql-ids mapping of a table, see ClearIds
+	InvalidateIds = "ids"
+	// InvalidateBeans clears the beans of a table, see ClearBeans
+	InvalidateBeans = "beans"
+	// InvalidateBean deletes a bean of a table, see DelBean
+	InvalidateBean = "bean"
+	// InvalidateSQL deletes the ids of a sql of a table, see DelIds
+	InvalidateSQL = "sql"
+	// InvalidateAll clears everything, it's sent when messages may have been lost
+	InvalidateAll = "all"
+)
+
+// Invalidation represents a cache eviction which should be applied on all the replicas
+type Invalidation struct {
+	Origin string `json:"origin"`
+	Op     string `json:"op"`
+	Table  string `json:"table,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// InvalidationChannel broadcasts cache evictions between replicas
+type InvalidationChannel interface {
+	Publish(msg Invalidation) error
+	Subscribe(handler func(Invalidation)) error
+}
+
+func newNodeID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// RedisInvalidationChannel implements InvalidationChannel via the PUBLISH and
+// SUBSCRIBE commands of a server speaking the Redis protocol
+type RedisInvalidationChannel struct {
+	opts     RedisOptions
+	channel  string
+	pool     *redisPool
+	mutex    sync.Mutex
+	handlers []func(Invalidation)
+	sub      *respConn
+	started  bool
+	closed   bool
+}
+
+var _ InvalidationChannel = &RedisInvalidationChannel{}
+
+// NewRedisInvalidationChannel creates an invalidation channel with the name
+func NewRedisInvalidationChannel(opts RedisOptions, channel string) (*RedisInvalidationChannel, error) {
+	ch := &RedisInvalidationChannel{
+		opts:    opts,
+		channel: opts.Prefix + channel,
+	}
+	ch.pool = newRedisPool(&ch.opts)
+	if _, err := ch.pool.do("PING"); err != nil {
+		ch.pool.close()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Publish implements InvalidationChannel
+func (ch *RedisInvalidationChannel) Publish(msg Invalidation) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = ch.pool.do("PUBLISH", ch.channel, data)
+	return err
+}
+
+// Subscribe implements InvalidationChannel, the subscription is established
+// before Subscribe returns and is re-established when the connection is lost
+func (ch *RedisInvalidationChannel) Subscribe(handler func(Invalidation)) error {
+	ch.mutex.Lock()
+	ch.handlers = append(ch.handlers, handler)
+	if ch.started {
+		ch.mutex.Unlock()
+		return nil
+	}
+	ch.mutex.Unlock()
+
+	conn, err := ch.subscribe()
+	if err != nil {
+		return err
+	}
+	ch.mutex.Lock()
+	ch.started = true
+	ch.mutex.Unlock()
+	go ch.loop(conn)
+	return nil
+}
+
+func (ch *RedisInvalidationChannel) subscribe() (*respConn, error) {
+	conn, err := ch.opts.dial()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.do(ch.opts.Timeout, "SUBSCRIBE", ch.channel); err != nil {
+		conn.conn.Close()
+		return nil, err
+	}
+	_ = conn.conn.SetDeadline(time.Time{})
+
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	if ch.closed {
+		conn.conn.Close()
+		return nil, errRedisClosed
+	}
+	ch.sub = conn
+	return conn, nil
+}
+
+func (ch *RedisInvalidationChannel) dispatch(msg Invalidation) {
+	ch.mutex.Lock()
+	handlers := ch.handlers
+	ch.mutex.Unlock()
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+func (ch *RedisInvalidationChannel) isClosed() bool {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	return ch.closed
+}
+
+func (ch *RedisInvalidationChannel) loop(conn *respConn) {
+	backoff := 100 * time.Millisecond
+	for {
+		for {
+			reply, err := conn.readReply()
+			if err != nil {
+				break
+			}
+			values, ok := reply.([]interface{})
+			if !ok || len(values) != 3 {
+				continue
+			}
+			if kind, _ := values[0].([]byte); string(kind) != "message" {
+				continue
+			}
+			payload, _ := values[2].([]byte)
+			var msg Invalidation
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				continue
+			}
+			ch.dispatch(msg)
+		}
+		conn.conn.Close()
+
+		for {
+			if ch.isClosed() {
+				return
+			}
+			var err error
+			if conn, err = ch.subscribe(); err == nil {
+				break
+			}
+			time.Sleep(backoff)
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+		}
+		backoff = 100 * time.Millisecond
+		// messages published while reconnecting are lost
+		ch.dispatch(Invalidation{Op: InvalidateAll})
+	}
+}
+
+// Close stops the subscription and closes all the connections
+func (ch *RedisInvalidationChannel) Close() {
+	ch.mutex.Lock()
+	ch.closed = true
+	if ch.sub != nil {
+		ch.sub.conn.Close()
+	}
+	ch.mutex.Unlock()
+	ch.pool.close()
+}
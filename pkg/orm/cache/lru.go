@@ -38,6 +38,8 @@ type LRUCacher struct {
 	MaxElementSize int
 	Expired        time.Duration
 	GcInterval     time.Duration
+	invalidation   InvalidationChannel
+	nodeID         string
 }
 
 // NewLRUCacher creates a cacher
@@ -57,6 +59,56 @@ func NewLRUCacher2(store CacheStore, expired time.Duration, maxElementSize int)
 	return cacher
 }
 
+// SetInvalidationChannel broadcasts ClearIds, ClearBeans, DelIds and DelBean
+// to the other replicas via the channel and applies the ones received from them
+func (m *LRUCacher) SetInvalidationChannel(ch InvalidationChannel) error {
+	m.mutex.Lock()
+	m.invalidation = ch
+	m.nodeID = newNodeID()
+	m.mutex.Unlock()
+	return ch.Subscribe(m.applyInvalidation)
+}
+
+func (m *LRUCacher) publish(op, tableName, key string) {
+	m.mutex.Lock()
+	ch, nodeID := m.invalidation, m.nodeID
+	m.mutex.Unlock()
+	if ch == nil {
+		return
+	}
+	_ = ch.Publish(Invalidation{
+		Origin: nodeID,
+		Op:     op,
+		Table:  tableName,
+		Key:    key,
+	})
+}
+
+func (m *LRUCacher) applyInvalidation(msg Invalidation) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if msg.Origin == m.nodeID {
+		return
+	}
+	switch msg.Op {
+	case InvalidateIds:
+		m.clearIds(msg.Table)
+	case InvalidateBeans:
+		m.clearBeans(msg.Table)
+	case InvalidateBean:
+		m.delBean(msg.Table, msg.Key)
+	case InvalidateSQL:
+		m.delIds(msg.Table, msg.Key)
+	case InvalidateAll:
+		for tableName := range m.idIndex {
+			m.clearBeans(tableName)
+		}
+		for tableName := range m.sqlIndex {
+			m.clearIds(tableName)
+		}
+	}
+}
+
 // RunGC run once every m.GcInterval
 func (m *LRUCacher) RunGC() {
 	time.AfterFunc(m.GcInterval, func() {
@@ -169,6 +221,7 @@ func (m *LRUCacher) ClearIds(tableName string) {
 	m.mutex.Lock()
 	m.clearIds(tableName)
 	m.mutex.Unlock()
+	m.publish(InvalidateIds, tableName, "")
 }
 func (m *LRUCacher) clearBeans(tableName string) {
 	if tis, ok := m.idIndex[tableName]; ok {
@@ -186,6 +239,7 @@ func (m *LRUCacher) ClearBeans(tableName string) {
 	m.mutex.Lock()
 	m.clearBeans(tableName)
 	m.mutex.Unlock()
+	m.publish(InvalidateBeans, tableName, "")
 }
 
 // PutIds pus ids into table
@@ -243,6 +297,7 @@ func (m *LRUCacher) DelIds(tableName, sql string) {
 	m.mutex.Lock()
 	m.delIds(tableName, sql)
 	m.mutex.Unlock()
+	m.publish(InvalidateSQL, tableName, sql)
 }
 func (m *LRUCacher) delBean(tableName string, id string) {
 	tid := genID(tableName, id)
@@ -259,6 +314,7 @@ func (m *LRUCacher) DelBean(tableName string, id string) {
 	m.mutex.Lock()
 	m.delBean(tableName, id)
 	m.mutex.Unlock()
+	m.publish(InvalidateBean, tableName, id)
 }
 
 type idNode struct {
@@ -20,7 +20,10 @@ package cache
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // Manager represents a cache manager
 type Manager struct {
@@ -28,12 +31,15 @@ type Manager struct {
 	disableGlobalCache bool
 	cachers            map[string]Cacher
 	cacherLock         sync.RWMutex
+	dependents         map[string]map[string]bool
+	dependentsLock     sync.RWMutex
 }
 
 // NewManager creates a cache manager
 func NewManager() *Manager {
 	return &Manager{
-		cachers: make(map[string]Cacher),
+		cachers:    make(map[string]Cacher),
+		dependents: make(map[string]map[string]bool),
 	}
 }
 
@@ -73,3 +79,31 @@ func (mgr *Manager) SetDefaultCacher(cacher Cacher) {
 func (mgr *Manager) GetDefaultCacher() Cacher {
 	return mgr.cacher
 }
+
+// AddDependency records that the cached sqls of tableName, e.g. join queries,
+// depend on the other tables so that they are cleared when any of them changes
+func (mgr *Manager) AddDependency(tableName string, tables ...string) {
+	mgr.dependentsLock.Lock()
+	defer mgr.dependentsLock.Unlock()
+	for _, table := range tables {
+		if table == tableName {
+			continue
+		}
+		if mgr.dependents[table] == nil {
+			mgr.dependents[table] = make(map[string]bool)
+		}
+		mgr.dependents[table][tableName] = true
+	}
+}
+
+// Dependents returns the tables whose cached sqls depend on the table
+func (mgr *Manager) Dependents(tableName string) []string {
+	mgr.dependentsLock.RLock()
+	defer mgr.dependentsLock.RUnlock()
+	var tables = make([]string, 0, len(mgr.dependents[tableName]))
+	for table := range mgr.dependents[tableName] {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
@@ -0,0 +1,93 @@
+package cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"time"
+)
+
+// RedisStore implements CacheStore on a server speaking the Redis protocol so
+// that the cached values are shared by all the replicas. Like KeyValueDBStore
+// the values are gob encoded, so the cached types should be registered via
+// gob.Register.
+type RedisStore struct {
+	opts RedisOptions
+	pool *redisPool
+}
+
+var _ CacheStore = &RedisStore{}
+
+// NewRedisStore creates a redis store and checks the server is reachable
+func NewRedisStore(opts RedisOptions) (*RedisStore, error) {
+	s := &RedisStore{opts: opts}
+	s.pool = newRedisPool(&s.opts)
+	if _, err := s.pool.do("PING"); err != nil {
+		s.pool.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put implements CacheStore
+func (s *RedisStore) Put(key string, value interface{}) error {
+	val, err := Encode(value)
+	if err != nil {
+		return err
+	}
+	if s.opts.Expiration > 0 {
+		_, err = s.pool.do("SET", s.opts.Prefix+key, val, "PX", int64(s.opts.Expiration/time.Millisecond))
+	} else {
+		_, err = s.pool.do("SET", s.opts.Prefix+key, val)
+	}
+	return err
+}
+
+// Get implements CacheStore
+func (s *RedisStore) Get(key string) (interface{}, error) {
+	reply, err := s.pool.do("GET", s.opts.Prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotExist
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, errors.New("orm/cache: redis: unexpected GET reply")
+	}
+	var v interface{}
+	if err := Decode(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Del implements CacheStore
+func (s *RedisStore) Del(key string) error {
+	_, err := s.pool.do("DEL", s.opts.Prefix+key)
+	return err
+}
+
+// Close closes all the idle connections
+func (s *RedisStore) Close() {
+	s.pool.close()
+}
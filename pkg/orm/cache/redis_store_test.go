@@ -0,0 +1,301 @@
+package cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis is an in-process server implementing the subset of the Redis
+// protocol used by RedisStore and RedisInvalidationChannel
+type fakeRedis struct {
+	ln          net.Listener
+	mutex       sync.Mutex
+	data        map[string][]byte
+	conns       map[net.Conn]bool
+	subscribers map[string]map[*respConn]bool
+	// commands counts the commands run by their names
+	commands map[string]int
+	// dropReply is a command whose connection is closed instead of replying
+	dropReply string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &fakeRedis{
+		ln:          ln,
+		data:        make(map[string][]byte),
+		conns:       make(map[net.Conn]bool),
+		subscribers: make(map[string]map[*respConn]bool),
+		commands:    make(map[string]int),
+	}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// dropConnections closes all the client connections
+func (s *fakeRedis) dropConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeRedis) close() {
+	s.ln.Close()
+	s.dropConnections()
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	c := newRespConn(conn)
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		for _, subs := range s.subscribers {
+			delete(subs, c)
+		}
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			return
+		}
+		values, _ := reply.([]interface{})
+		var args []string
+		for _, v := range values {
+			bs, _ := v.([]byte)
+			args = append(args, string(bs))
+		}
+		if len(args) == 0 {
+			return
+		}
+		s.mutex.Lock()
+		s.commands[strings.ToUpper(args[0])]++
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			c.w.WriteString("+PONG\r\n")
+		case "AUTH", "SELECT":
+			c.w.WriteString("+OK\r\n")
+		case "SET":
+			s.data[args[1]] = []byte(args[2])
+			c.w.WriteString("+OK\r\n")
+		case "GET":
+			if v, ok := s.data[args[1]]; ok {
+				c.w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + string(v) + "\r\n")
+			} else {
+				c.w.WriteString("$-1\r\n")
+			}
+		case "DEL":
+			_, ok := s.data[args[1]]
+			delete(s.data, args[1])
+			if ok {
+				c.w.WriteString(":1\r\n")
+			} else {
+				c.w.WriteString(":0\r\n")
+			}
+		case "SUBSCRIBE":
+			if s.subscribers[args[1]] == nil {
+				s.subscribers[args[1]] = make(map[*respConn]bool)
+			}
+			s.subscribers[args[1]][c] = true
+			c.w.WriteString("*3\r\n$9\r\nsubscribe\r\n$" + strconv.Itoa(len(args[1])) + "\r\n" + args[1] + "\r\n:1\r\n")
+		case "PUBLISH":
+			for sub := range s.subscribers[args[1]] {
+				_ = sub.writeCommand("message", args[1], args[2])
+			}
+			c.w.WriteString(":" + strconv.Itoa(len(s.subscribers[args[1]])) + "\r\n")
+		default:
+			c.w.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+		if strings.EqualFold(args[0], s.dropReply) {
+			s.mutex.Unlock()
+			return
+		}
+		err = c.w.Flush()
+		s.mutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) has(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.data[key]
+	return ok
+}
+
+func (s *fakeRedis) subscriberCount(channel string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subscribers[channel])
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedis(t)
+	store, err := NewRedisStore(RedisOptions{
+		Addr:       server.addr(),
+		Prefix:     "orm:",
+		Expiration: time.Minute,
+	})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Get("user-1")
+	assert.EqualValues(t, ErrNotExist, err)
+
+	assert.NoError(t, store.Put("user-1", "bean"))
+	v, err := store.Get("user-1")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "bean", v)
+	assert.True(t, server.has("orm:user-1"))
+
+	assert.NoError(t, store.Del("user-1"))
+	_, err = store.Get("user-1")
+	assert.EqualValues(t, ErrNotExist, err)
+
+	// the pool reconnects after the server drops the connections
+	server.dropConnections()
+	assert.NoError(t, store.Put("user-2", int64(2)))
+	v, err = store.Get("user-2")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, v)
+}
+
+func TestRedisStoreUnknownCommand(t *testing.T) {
+	server := newFakeRedis(t)
+	store, err := NewRedisStore(RedisOptions{Addr: server.addr()})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.pool.do("FLUSHALL")
+	assert.Error(t, err)
+	_, ok := err.(RedisError)
+	assert.True(t, ok)
+}
+
+func TestRedisPoolRetry(t *testing.T) {
+	server := newFakeRedis(t)
+	store, err := NewRedisStore(RedisOptions{Addr: server.addr()})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	commandCount := func(name string) int {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return server.commands[name]
+	}
+
+	// the reply of PUBLISH is lost after the server ran it
+	server.mutex.Lock()
+	server.dropReply = "PUBLISH"
+	server.mutex.Unlock()
+	_, err = store.pool.do("PUBLISH", "channel", "message")
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, commandCount("PUBLISH"))
+
+	// GET is retried once on a new connection
+	_, err = store.pool.do("PING")
+	assert.NoError(t, err)
+	server.mutex.Lock()
+	server.dropReply = "GET"
+	server.mutex.Unlock()
+	_, err = store.pool.do("GET", "key")
+	assert.Error(t, err)
+	assert.EqualValues(t, 2, commandCount("GET"))
+}
+
+func TestLRUCacherInvalidation(t *testing.T) {
+	server := newFakeRedis(t)
+	opts := RedisOptions{Addr: server.addr()}
+
+	newCacher := func() *LRUCacher {
+		ch, err := NewRedisInvalidationChannel(opts, "invalidation")
+		assert.NoError(t, err)
+		t.Cleanup(ch.Close)
+		cacher := NewLRUCacher(NewMemoryStore(), 10000)
+		assert.NoError(t, cacher.SetInvalidationChannel(ch))
+		return cacher
+	}
+	node1, node2 := newCacher(), newCacher()
+
+	tableName := "cache_object"
+	for _, cacher := range []*LRUCacher{node1, node2} {
+		cacher.GetBean(tableName, "1")
+		cacher.PutBean(tableName, "1", "bean1")
+		cacher.PutBean(tableName, "2", "bean2")
+		cacher.PutIds(tableName, "select * from cache_object", "ids")
+	}
+
+	node1.DelBean(tableName, "1")
+	assert.Eventually(t, func() bool {
+		return node2.GetBean(tableName, "1") == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, "bean2", node2.GetBean(tableName, "2"))
+
+	node2.PutIds(tableName, "select * from cache_object", "ids")
+	node1.ClearIds(tableName)
+	assert.Eventually(t, func() bool {
+		return node2.GetIds(tableName, "select * from cache_object") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	node1.ClearBeans(tableName)
+	assert.Eventually(t, func() bool {
+		return node2.GetBean(tableName, "2") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// after the subscription is lost everything is cleared since messages may be lost
+	node2.PutBean(tableName, "3", "bean3")
+	server.dropConnections()
+	assert.Eventually(t, func() bool {
+		return server.subscriberCount("invalidation") == 2 && node2.GetBean(tableName, "3") == nil
+	}, 3*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,303 @@
+package cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errRedisClosed = errors.New("orm/cache: redis: connection pool is closed")
+
+// RedisError represents an error reply of a RESP server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "orm/cache: redis: " + string(e)
+}
+
+// respWriteError is an I/O error of sending a command, the server did not get
+// the whole command so that it did not run it
+type respWriteError struct {
+	err error
+}
+
+func (e respWriteError) Error() string {
+	return e.err.Error()
+}
+
+func (e respWriteError) Unwrap() error {
+	return e.err
+}
+
+// respConn is a connection speaking the REdis Serialization Protocol
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func newRespConn(conn net.Conn) *respConn {
+	return &respConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+func (c *respConn) writeCommand(args ...interface{}) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var bs []byte
+		switch a := arg.(type) {
+		case []byte:
+			bs = a
+		case string:
+			bs = []byte(a)
+		case int:
+			bs = strconv.AppendInt(nil, int64(a), 10)
+		case int64:
+			bs = strconv.AppendInt(nil, a, 10)
+		default:
+			bs = []byte(fmt.Sprint(a))
+		}
+		fmt.Fprintf(c.w, "$%d\r\n", len(bs))
+		c.w.Write(bs)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("orm/cache: redis: malformed reply line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply reads one reply, the result is a string for simple strings, an
+// int64 for integers, a []byte for bulk strings, a []interface{} for arrays,
+// nil for null values or a RedisError
+func (c *respConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("orm/cache: redis: empty reply line")
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("orm/cache: redis: unexpected reply type %q", line[0])
+}
+
+// do sends a command and returns its reply, error replies are returned as error
+func (c *respConn) do(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	if timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := c.writeCommand(args...); err != nil {
+		return nil, respWriteError{err}
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(RedisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// RedisOptions represents the options of a RESP server connection
+type RedisOptions struct {
+	// Addr is the host:port of the server
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to every key and channel name
+	Prefix string
+	// Expiration is the TTL of every stored value, zero means no expiration
+	Expiration  time.Duration
+	DialTimeout time.Duration
+	// Timeout is the read and write timeout of every command
+	Timeout time.Duration
+	// PoolSize is the max idle connections kept
+	PoolSize int
+}
+
+func (opts *RedisOptions) dial() (*respConn, error) {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", opts.Addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := newRespConn(conn)
+	if opts.Password != "" {
+		if _, err := c.do(opts.Timeout, "AUTH", opts.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if opts.DB != 0 {
+		if _, err := c.do(opts.Timeout, "SELECT", opts.DB); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// redisPool keeps idle connections to a RESP server
+type redisPool struct {
+	opts   *RedisOptions
+	idle   chan *respConn
+	mutex  sync.Mutex
+	closed bool
+}
+
+func newRedisPool(opts *RedisOptions) *redisPool {
+	size := opts.PoolSize
+	if size <= 0 {
+		size = 10
+	}
+	return &redisPool{
+		opts: opts,
+		idle: make(chan *respConn, size),
+	}
+}
+
+// idempotentCommands could run twice with the same result
+var idempotentCommands = map[string]bool{
+	"PING": true,
+	"GET":  true,
+	"SET":  true,
+	"DEL":  true,
+}
+
+// do runs a command on an idle or new connection, a command failed on an
+// idle connection which may have been closed by the server is retried once
+// on a new connection. As the server may have run a command whose reply was
+// lost, only the idempotent commands and the commands which were not sent
+// are retried.
+func (p *redisPool) do(args ...interface{}) (interface{}, error) {
+	var c *respConn
+	var ok bool
+	select {
+	case c, ok = <-p.idle:
+		if !ok {
+			return nil, errRedisClosed
+		}
+	default:
+	}
+	if c != nil {
+		reply, err := p.doConn(c, args...)
+		if _, isRedisErr := err.(RedisError); err == nil || isRedisErr {
+			return reply, err
+		}
+		if _, isWriteErr := err.(respWriteError); !isWriteErr && !idempotentCommands[fmt.Sprint(args[0])] {
+			return nil, err
+		}
+	}
+	c, err := p.opts.dial()
+	if err != nil {
+		return nil, err
+	}
+	return p.doConn(c, args...)
+}
+
+func (p *redisPool) doConn(c *respConn, args ...interface{}) (interface{}, error) {
+	reply, err := c.do(p.opts.Timeout, args...)
+	if _, ok := err.(RedisError); err != nil && !ok {
+		c.conn.Close()
+		return nil, err
+	}
+	p.put(c)
+	return reply, err
+}
+
+func (p *redisPool) put(c *respConn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		c.conn.Close()
+		return
+	}
+	select {
+	case p.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (p *redisPool) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.idle)
+	for c := range p.idle {
+		c.conn.Close()
+	}
+}
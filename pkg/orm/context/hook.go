@@ -35,18 +35,37 @@ type ContextHook struct {
 	Result      sql.Result
 	ExecuteTime time.Duration
 	Err         error // SQL executed error
+	TxAttempt   int   // the attempt of the retried transaction running the SQL, 0 if it's not retried
 }
 
 // NewContextHook return context for hook
 func NewContextHook(ctx context.Context, sql string, args []interface{}) *ContextHook {
 	return &ContextHook{
-		start: time.Now(),
-		Ctx:   ctx,
-		SQL:   sql,
-		Args:  args,
+		start:     time.Now(),
+		Ctx:       ctx,
+		SQL:       sql,
+		Args:      args,
+		TxAttempt: TxAttempt(ctx),
 	}
 }
 
+type txAttemptKey struct{}
+
+// WithTxAttempt returns a context telling the hooks which attempt of a retried
+// transaction runs the SQLs, the first attempt is 1
+func WithTxAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, txAttemptKey{}, attempt)
+}
+
+// TxAttempt returns the attempt of the retried transaction, 0 if there is none
+func TxAttempt(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	attempt, _ := ctx.Value(txAttemptKey{}).(int)
+	return attempt
+}
+
 // End finish the hook invokation
 func (c *ContextHook) End(ctx context.Context, result sql.Result, err error) {
 	c.Ctx = ctx
@@ -80,6 +99,10 @@ func (h *Hooks) BeforeProcess(c *ContextHook) (context.Context, error) {
 		if err != nil {
 			return nil, err
 		}
+		// pass the context returned by the hook to the next one
+		if ctx != nil {
+			c.Ctx = ctx
+		}
 	}
 	return ctx, nil
 }
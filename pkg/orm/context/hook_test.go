@@ -99,6 +99,36 @@ func TestBeforeProcess(t *testing.T) {
 		})
 	}
 }
+
+type ctxKey struct{}
+
+func TestBeforeProcessChainsContext(t *testing.T) {
+	hooks := Hooks{}
+	hooks.AddHook(
+		&testHook{
+			before: func(c *ContextHook) (context.Context, error) {
+				return context.WithValue(c.Ctx, ctxKey{}, "first"), nil
+			},
+		},
+		&testHook{
+			before: func(c *ContextHook) (context.Context, error) {
+				if v := c.Ctx.Value(ctxKey{}); v != "first" {
+					t.Errorf("got %v, expect the context of the previous hook", v)
+				}
+				return c.Ctx, nil
+			},
+		},
+	)
+	ctx, err := hooks.BeforeProcess(&ContextHook{
+		Ctx: context.Background(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ctx.Value(ctxKey{}); v != "first" {
+		t.Errorf("got %v, expect first", v)
+	}
+}
 func TestAfterProcess(t *testing.T) {
 	expectErr := errors.New("expect err")
 	tests := []struct {
@@ -154,3 +184,13 @@ func TestAfterProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestTxAttempt(t *testing.T) {
+	if c := NewContextHook(context.Background(), "SELECT 1", nil); c.TxAttempt != 0 {
+		t.Errorf("got %v, expect 0", c.TxAttempt)
+	}
+	ctx := WithTxAttempt(context.Background(), 2)
+	if c := NewContextHook(ctx, "SELECT 1", nil); c.TxAttempt != 2 {
+		t.Errorf("got %v, expect 2", c.TxAttempt)
+	}
+}
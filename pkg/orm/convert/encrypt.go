@@ -0,0 +1,219 @@
+package convert
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	// ErrUnknownKey the key provider has no key with the ID
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrInvalidCiphertext the value is not encrypted by an Encryptor or is corrupted
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+	// ErrKeyIDTooLong the key ID is longer than MaxKeyIDLen
+	ErrKeyIDTooLong = errors.New("encryption key ID is too long")
+)
+
+// MaxKeyIDLen is the max length of the key IDs stored with the ciphertexts
+const MaxKeyIDLen = 32
+
+// CiphertextLen returns the max length of the ciphertexts of the plaintexts up
+// to n bytes, the key ID, the colon and the base64 of the nonce, the plaintext
+// and the tag
+func CiphertextLen(n int) int {
+	return MaxKeyIDLen + 1 + (12+n+16+2)/3*4
+}
+
+// KeyProvider provides the keys of the encrypted columns, the ID of the key is
+// stored with every ciphertext so the keys can be rotated
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key encrypting the new values
+	CurrentKeyID() string
+	// Key returns the AES key, 16, 24 or 32 bytes, with the ID
+	Key(id string) ([]byte, error)
+	// KeyIDs returns the IDs of all the keys which the stored values may be encrypted with
+	KeyIDs() []string
+}
+
+// KeyRing is a KeyProvider holding the keys in memory
+type KeyRing struct {
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = &KeyRing{}
+
+// NewKeyRing creates a key ring encrypting with the key current
+func NewKeyRing(current string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, current)
+	}
+	for id, key := range keys {
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+	}
+	return &KeyRing{current: current, keys: keys}, nil
+}
+
+// CurrentKeyID implements KeyProvider
+func (ring *KeyRing) CurrentKeyID() string {
+	return ring.current
+}
+
+// Key implements KeyProvider
+func (ring *KeyRing) Key(id string) ([]byte, error) {
+	key, ok := ring.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// KeyIDs implements KeyProvider
+func (ring *KeyRing) KeyIDs() []string {
+	ids := make([]string, 0, len(ring.keys))
+	for id := range ring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Encryptor encrypts the values of the columns with AES-GCM, the ciphertexts
+// are stored as "<key id>:<base64 of nonce and sealed value>". The column name
+// is authenticated with the value so a ciphertext cannot be moved to another
+// column. The deterministic ciphertexts derive the nonce from the value, the
+// equal values have the equal ciphertexts and can be compared by the database.
+type Encryptor struct {
+	provider KeyProvider
+}
+
+// NewEncryptor creates an encryptor with the keys of provider
+func NewEncryptor(provider KeyProvider) *Encryptor {
+	return &Encryptor{provider: provider}
+}
+
+// aead returns the cipher of the key with the ID and the key deriving the
+// deterministic nonces, both are derived from the key by HKDF so the key of
+// the cipher is not reused for the nonces
+func (e *Encryptor) aead(keyID string) (cipher.AEAD, []byte, error) {
+	key, err := e.provider.Key(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	encKey, nonceKey := make([]byte, len(key)), make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("enc")), encKey); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("nonce")), nonceKey); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, nonceKey, nil
+}
+
+func (e *Encryptor) encrypt(keyID, colName string, plaintext []byte, deterministic bool) (string, error) {
+	if len(keyID) > MaxKeyIDLen {
+		return "", fmt.Errorf("%w: %s", ErrKeyIDTooLong, keyID)
+	}
+	aead, nonceKey, err := e.aead(keyID)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if deterministic {
+		mac := hmac.New(sha256.New, nonceKey)
+		mac.Write([]byte(colName))
+		mac.Write([]byte{0})
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(colName))
+	return keyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Encrypt encrypts the plaintext of the column with the current key
+func (e *Encryptor) Encrypt(colName string, plaintext []byte, deterministic bool) (string, error) {
+	return e.encrypt(e.provider.CurrentKeyID(), colName, plaintext, deterministic)
+}
+
+// EncryptAll returns the deterministic ciphertexts of the plaintext of the
+// column with every key, which the stored values equal to plaintext are one of
+func (e *Encryptor) EncryptAll(colName string, plaintext []byte) ([]string, error) {
+	ids := e.provider.KeyIDs()
+	ciphertexts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ciphertext, err := e.encrypt(id, colName, plaintext, true)
+		if err != nil {
+			return nil, err
+		}
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+	return ciphertexts, nil
+}
+
+// Decrypt decrypts the ciphertext of the column with the key it was encrypted with
+func (e *Encryptor) Decrypt(colName string, ciphertext []byte) ([]byte, error) {
+	s := string(ciphertext)
+	idx := strings.LastIndexByte(s, ':')
+	if idx < 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s[idx+1:])
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	aead, _, err := e.aead(s[:idx])
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(colName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,106 @@
+package convert
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptor(t *testing.T) {
+	_, err := NewKeyRing("k2", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = NewKeyRing("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+
+	ring, err := NewKeyRing("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	assert.NoError(t, err)
+	e := NewEncryptor(ring)
+
+	c1, err := e.Encrypt("token", []byte("secret"), false)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(c1, "k1:"))
+	c2, err := e.Encrypt("token", []byte("secret"), false)
+	assert.NoError(t, err)
+	assert.NotEqual(t, c1, c2)
+	plaintext, err := e.Decrypt("token", []byte(c1))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "secret", plaintext)
+	// the ciphertext belongs to its column
+	_, err = e.Decrypt("other", []byte(c1))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	_, err = e.Decrypt("token", []byte("secret"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	d1, err := e.Encrypt("token", []byte("secret"), true)
+	assert.NoError(t, err)
+	d2, err := e.Encrypt("token", []byte("secret"), true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, d1, d2)
+	d3, err := e.Encrypt("other", []byte("secret"), true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, d1, d3)
+
+	// rotate the key, the old values are still decrypted and matched
+	ring, err = NewKeyRing("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	})
+	assert.NoError(t, err)
+	e = NewEncryptor(ring)
+	plaintext, err = e.Decrypt("token", []byte(d1))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "secret", plaintext)
+	d4, err := e.Encrypt("token", []byte("secret"), true)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(d4, "k2:"))
+	all, err := e.EncryptAll("token", []byte("secret"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{d1, d4}, all)
+
+	// the cipher does not use the key of the provider
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(d4, "k2:"))
+	assert.NoError(t, err)
+	block, err := aes.NewCipher(bytes.Repeat([]byte{2}, 16))
+	assert.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	_, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte("token"))
+	assert.Error(t, err)
+
+	// the ciphertexts fit the columns sized by CiphertextLen
+	keyID := strings.Repeat("k", MaxKeyIDLen)
+	ring, err = NewKeyRing(keyID, map[string][]byte{keyID: bytes.Repeat([]byte{1}, 32)})
+	assert.NoError(t, err)
+	c, err := NewEncryptor(ring).Encrypt("token", bytes.Repeat([]byte{'a'}, 255), true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, CiphertextLen(255), len(c))
+	ring, err = NewKeyRing(keyID+"k", map[string][]byte{keyID + "k": bytes.Repeat([]byte{1}, 32)})
+	assert.NoError(t, err)
+	_, err = NewEncryptor(ring).Encrypt("token", []byte("secret"), false)
+	assert.ErrorIs(t, err, ErrKeyIDTooLong)
+}
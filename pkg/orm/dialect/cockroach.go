@@ -0,0 +1,144 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bhojpur/dbm/pkg/orm/core"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// cockroach speaks the postgres dialect but generates the ids of the
+// autoincrement columns with unique_rowid() instead of sequences
+type cockroach struct {
+	postgres
+}
+
+func (db *cockroach) Init(uri *URI) error {
+	db.quoter = postgresQuoter
+	return db.Base.Init(db, uri)
+}
+func (db *cockroach) SQLType(c *schemasvr.Column) string {
+	switch c.SQLType.Name {
+	case schemasvr.Serial, schemasvr.BigSerial:
+		c.IsAutoIncrement = true
+		c.Nullable = false
+	}
+	// unique_rowid() returns an INT8, so the column could not be narrower
+	if c.IsAutoIncrement {
+		return schemasvr.BigInt
+	}
+	return db.postgres.SQLType(c)
+}
+func (db *cockroach) Features() *DialectFeatures {
+	return &DialectFeatures{
+		AutoincrMode: IncrAutoincrMode,
+		RetryTx:      true,
+	}
+}
+func (db *cockroach) AutoIncrStr() string {
+	return "DEFAULT unique_rowid()"
+}
+func (db *cockroach) DropIndexSQL(tableName string, index *schemasvr.Index) string {
+	idxName := index.Name
+	tableParts := strings.Split(strings.Replace(tableName, `"`, "", -1), ".")
+	tableName = tableParts[len(tableParts)-1]
+	if !strings.HasPrefix(idxName, "UQE_") &&
+		!strings.HasPrefix(idxName, "IDX_") {
+		if index.Type == schemasvr.UniqueType {
+			idxName = fmt.Sprintf("UQE_%v_%v", tableName, index.Name)
+		} else {
+			idxName = fmt.Sprintf("IDX_%v_%v", tableName, index.Name)
+		}
+	}
+	if db.getSchema() != "" {
+		tableName = db.getSchema() + "." + tableName
+	}
+	// the index names are only unique per table in cockroach
+	return fmt.Sprintf("DROP INDEX %v@%v", db.Quoter().Quote(tableName), db.Quoter().Quote(idxName))
+}
+func (db *cockroach) GetIndexes(queryer core.Queryer, ctx context.Context, tableName string) (map[string]*schemasvr.Index, error) {
+	quotedName := db.Quoter().Quote(tableName)
+	if len(db.getSchema()) != 0 {
+		quotedName = db.Quoter().Quote(db.getSchema()) + "." + quotedName
+	}
+	// the implicit columns are the primary keys cockroach appends to every index
+	s := fmt.Sprintf("SELECT index_name, non_unique, column_name FROM [SHOW INDEXES FROM %s]"+
+		" WHERE NOT storing AND NOT implicit ORDER BY index_name, seq_in_index", quotedName)
+	rows, err := queryer.QueryContext(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]*schemasvr.Index)
+	for rows.Next() {
+		var indexName, colName string
+		var nonUnique bool
+		if err = rows.Scan(&indexName, &nonUnique, &colName); err != nil {
+			return nil, err
+		}
+		indexName = strings.Trim(indexName, `" `)
+		// ignore primary index
+		if strings.HasSuffix(indexName, "_pkey") || strings.EqualFold(indexName, "primary") {
+			continue
+		}
+		var isRegular bool
+		if strings.HasPrefix(indexName, "IDX_"+tableName) || strings.HasPrefix(indexName, "UQE_"+tableName) {
+			newIdxName := indexName[5+len(tableName):]
+			isRegular = true
+			if newIdxName != "" {
+				indexName = newIdxName
+			}
+		}
+		index, ok := indexes[indexName]
+		if !ok {
+			index = &schemasvr.Index{Name: indexName, Type: schemasvr.IndexType, Cols: make([]string, 0)}
+			if !nonUnique {
+				index.Type = schemasvr.UniqueType
+			}
+			index.IsRegular = isRegular
+			indexes[indexName] = index
+		}
+		index.Cols = append(index.Cols, colName)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return indexes, nil
+}
+
+// cockroachDriver parses the postgres connection strings of cockroach, the
+// database/sql driver, lib/pq or pgx, has to be registered with the same name
+type cockroachDriver struct {
+	pqDriverPgx
+}
+
+func (p *cockroachDriver) Parse(driverName, dataSourceName string) (*URI, error) {
+	uri, err := p.pqDriverPgx.Parse(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	uri.DBType = schemasvr.COCKROACH
+	return uri, nil
+}
@@ -0,0 +1,81 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCockroach(t *testing.T) {
+	driver := QueryDriver("cockroach")
+	uri, err := driver.Parse("cockroach", "postgresql://root@localhost:26257/defaultdb?sslmode=disable")
+	assert.NoError(t, err)
+	assert.EqualValues(t, schemasvr.COCKROACH, uri.DBType)
+	assert.EqualValues(t, "defaultdb", uri.DBName)
+
+	_, err = driver.Parse("cockroach", "mysql://localhost:26257/defaultdb")
+	assert.Error(t, err)
+}
+
+func TestCockroachAutoIncr(t *testing.T) {
+	dialect := QueryDialect(schemasvr.COCKROACH)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.COCKROACH}))
+	assert.True(t, dialect.Features().RetryTx)
+
+	col := &schemasvr.Column{
+		Name:            "id",
+		SQLType:         schemasvr.SQLType{Name: schemasvr.Int},
+		IsPrimaryKey:    true,
+		IsAutoIncrement: true,
+		DefaultIsEmpty:  true,
+	}
+	s, err := ColumnString(dialect, col, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, `"id" BIGINT PRIMARY KEY DEFAULT unique_rowid() NOT NULL`, s)
+
+	col = &schemasvr.Column{Name: "age", SQLType: schemasvr.SQLType{Name: schemasvr.Int}, Nullable: true, DefaultIsEmpty: true}
+	s, err = ColumnString(dialect, col, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, `"age" INTEGER NULL`, s)
+
+	assert.EqualValues(t, `DROP INDEX "public"."user"@"IDX_user_name"`,
+		dialect.DropIndexSQL("user", &schemasvr.Index{Name: "name", Type: schemasvr.IndexType}))
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsSerializationFailure(t *testing.T) {
+	assert.False(t, IsSerializationFailure(nil))
+	assert.False(t, IsSerializationFailure(errors.New("pq: duplicate key value")))
+	assert.False(t, IsSerializationFailure(sqlStateError("23505")))
+	assert.True(t, IsSerializationFailure(sqlStateError("40001")))
+	assert.True(t, IsSerializationFailure(fmt.Errorf("insert: %w", sqlStateError("40001"))))
+	assert.True(t, IsSerializationFailure(errors.New("ERROR: restart transaction: TransactionRetryWithProtoRefreshError (SQLSTATE 40001)")))
+	assert.True(t, IsSerializationFailure(errors.New("pq: restart transaction: TransactionRetryWithProtoRefreshError")))
+}
@@ -618,7 +618,7 @@ func (db *dameng) SQLType(c *schema.Column) string {
 		res = "REAL"
 	case schema.Numeric, schema.Decimal, "NUMBER":
 		res = "NUMERIC"
-	case schema.Text, schema.Json:
+	case schema.Text, schema.Json, schema.Jsonb:
 		return "TEXT"
 	case schema.MediumText, schema.LongText:
 		res = "CLOB"
@@ -1031,6 +1031,16 @@ func (db *dameng) Filters() []Filter {
 	return []Filter{}
 }
 
+// ReleaseSavepointSQL returns empty, dameng keeps the savepoints until the transaction ends
+func (db *dameng) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// IsRetryableError returns true on deadlocks and serialization failures
+func (db *dameng) IsRetryableError(err error) bool {
+	return isOracleRetryableError(err)
+}
+
 type damengDriver struct {
 	baseDriver
 }
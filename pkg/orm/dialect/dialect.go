@@ -22,6 +22,7 @@ package dialect
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -49,7 +50,7 @@ type URI struct {
 // SetSchema set schema
 func (uri *URI) SetSchema(schema string) {
 	// hack me
-	if uri.DBType == schemasvr.POSTGRES {
+	if uri.DBType.IsPostgres() {
 		uri.Schema = strings.TrimSpace(schema)
 	}
 }
@@ -62,7 +63,74 @@ const (
 
 // DialectFeatures represents a dialect parameters
 type DialectFeatures struct {
-	AutoincrMode int // 0 autoincrement column, 1 sequence
+	AutoincrMode int  // 0 autoincrement column, 1 sequence
+	RetryTx      bool // transactions may abort with serialization failures and should be retried
+}
+
+// ForUpdateOptions represents how SELECT ... FOR UPDATE locks the rows,
+// the zero value means a plain FOR UPDATE
+type ForUpdateOptions struct {
+	Share      bool     // lock the rows in share mode, FOR SHARE
+	NoWait     bool     // fail at once when a row is locked, NOWAIT
+	SkipLocked bool     // leave out the locked rows, SKIP LOCKED
+	Of         []string // lock only the rows of these tables of the join
+}
+
+// ErrUnsupportedLockMode is wrapped by the errors of the dialects which could
+// not express a row lock mode
+var ErrUnsupportedLockMode = errors.New("unsupported lock mode")
+
+// check returns an error when the options ask for a mode the dialect could not express
+func (opts *ForUpdateOptions) check(dbType schemasvr.DBType, share, noWait, skipLocked, of bool) error {
+	var mode string
+	switch {
+	case opts.NoWait && opts.SkipLocked:
+		return fmt.Errorf("%w: NOWAIT and SKIP LOCKED are exclusive", ErrUnsupportedLockMode)
+	case opts.Share && !share:
+		mode = "FOR SHARE"
+	case opts.NoWait && !noWait:
+		mode = "NOWAIT"
+	case opts.SkipLocked && !skipLocked:
+		mode = "SKIP LOCKED"
+	case len(opts.Of) > 0 && !of:
+		mode = "OF table"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s could not lock rows with %s", ErrUnsupportedLockMode, dbType, mode)
+}
+
+// waitSQL returns the clause telling what to do with the locked rows
+func (opts *ForUpdateOptions) waitSQL() string {
+	if opts.NoWait {
+		return " NOWAIT"
+	} else if opts.SkipLocked {
+		return " SKIP LOCKED"
+	}
+	return ""
+}
+
+// forUpdateSQL appends FOR UPDATE or FOR SHARE with the options to the query
+func forUpdateSQL(quoter schemasvr.Quoter, query string, opts *ForUpdateOptions) string {
+	var buf strings.Builder
+	buf.WriteString(query)
+	if opts.Share {
+		buf.WriteString(" FOR SHARE")
+	} else {
+		buf.WriteString(" FOR UPDATE")
+	}
+	if len(opts.Of) > 0 {
+		buf.WriteString(" OF ")
+		buf.WriteString(quoter.Join(opts.Of, ", "))
+	}
+	buf.WriteString(opts.waitSQL())
+	return buf.String()
+}
+
+// LockHinter is implemented by the dialects which lock the rows of a SELECT
+// with table hints instead of a FOR UPDATE clause
+type LockHinter interface {
+	LockHint(opts *ForUpdateOptions) (string, error)
 }
 
 // Dialect represents a kind of database
@@ -93,7 +161,11 @@ type Dialect interface {
 	IsColumnExist(queryer core.Queryer, ctx context.Context, tableName string, colName string) (bool, error)
 	AddColumnSQL(tableName string, col *schemasvr.Column) string
 	ModifyColumnSQL(tableName string, col *schemasvr.Column) string
-	ForUpdateSQL(query string) string
+	ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error)
+	IsRetryableError(err error) bool // whether the transaction failed with err could succeed when run again
+	SavepointSQL(name string) string
+	RollbackToSavepointSQL(name string) string
+	ReleaseSavepointSQL(name string) string // empty if the savepoints could not be released
 	Filters() []Filter
 	SetParams(params map[string]string)
 }
@@ -221,7 +293,7 @@ func (db *Base) CreateIndexSQL(tableName string, index *schemasvr.Index) string
 	idxName = index.XName(tableName)
 	return fmt.Sprintf("CREATE%s INDEX %v ON %v (%v)", unique,
 		quoter.Quote(idxName), quoter.Quote(tableName),
-		quoter.Join(index.Cols, ","))
+		indexColumnsSQL(db.dialect, index))
 }
 
 // DropIndexSQL returns a SQL to drop index
@@ -242,9 +314,37 @@ func (db *Base) ModifyColumnSQL(tableName string, col *schemasvr.Column) string
 	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", db.quoter.Quote(tableName), s)
 }
 
-// ForUpdateSQL returns for updateSQL
-func (db *Base) ForUpdateSQL(query string) string {
-	return query + " FOR UPDATE"
+// ForUpdateSQL appends FOR UPDATE [NOWAIT | SKIP LOCKED] to the query, nil opts
+// means a plain FOR UPDATE
+func (db *Base) ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error) {
+	if opts == nil {
+		return query + " FOR UPDATE", nil
+	}
+	if err := opts.check(db.uri.DBType, false, true, true, false); err != nil {
+		return "", err
+	}
+	return query + " FOR UPDATE" + opts.waitSQL(), nil
+}
+
+// IsRetryableError returns false, the dialects knowing their deadlock and
+// serialization failure errors override it
+func (db *Base) IsRetryableError(err error) bool {
+	return false
+}
+
+// SavepointSQL returns the SQL to create a savepoint
+func (db *Base) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL returns the SQL to roll back to a savepoint
+func (db *Base) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL returns the SQL to release a savepoint
+func (db *Base) ReleaseSavepointSQL(name string) string {
+	return "RELEASE SAVEPOINT " + name
 }
 
 // SetParams set params
@@ -276,16 +376,19 @@ func regDrvsNDialects() bool {
 		getDriver  func() Driver
 		getDialect func() Dialect
 	}{
-		"mssql":    {"mssql", func() Driver { return &odbcDriver{} }, func() Dialect { return &mssql{} }},
-		"odbc":     {"mssql", func() Driver { return &odbcDriver{} }, func() Dialect { return &mssql{} }}, // !nashtsai! TODO change this when supporting MS Access
-		"mysql":    {"mysql", func() Driver { return &mysqlDriver{} }, func() Dialect { return &mysql{} }},
-		"mymysql":  {"mysql", func() Driver { return &mymysqlDriver{} }, func() Dialect { return &mysql{} }},
-		"postgres": {"postgres", func() Driver { return &pqDriver{} }, func() Dialect { return &postgres{} }},
-		"pgx":      {"postgres", func() Driver { return &pqDriverPgx{} }, func() Dialect { return &postgres{} }},
-		"sqlite3":  {"sqlite3", func() Driver { return &sqlite3Driver{} }, func() Dialect { return &sqlite3{} }},
-		"sqlite":   {"sqlite3", func() Driver { return &sqlite3Driver{} }, func() Dialect { return &sqlite3{} }},
-		"oci8":     {"oracle", func() Driver { return &oci8Driver{} }, func() Dialect { return &oracle{} }},
-		"godror":   {"oracle", func() Driver { return &godrorDriver{} }, func() Dialect { return &oracle{} }},
+		"mssql":      {"mssql", func() Driver { return &odbcDriver{} }, func() Dialect { return &mssql{} }},
+		"odbc":       {"mssql", func() Driver { return &odbcDriver{} }, func() Dialect { return &mssql{} }}, // !nashtsai! TODO change this when supporting MS Access
+		"mysql":      {"mysql", func() Driver { return &mysqlDriver{} }, func() Dialect { return &mysql{} }},
+		"mymysql":    {"mysql", func() Driver { return &mymysqlDriver{} }, func() Dialect { return &mysql{} }},
+		"postgres":   {"postgres", func() Driver { return &pqDriver{} }, func() Dialect { return &postgres{} }},
+		"pgx":        {"postgres", func() Driver { return &pqDriverPgx{} }, func() Dialect { return &postgres{} }},
+		"sqlite3":    {"sqlite3", func() Driver { return &sqlite3Driver{} }, func() Dialect { return &sqlite3{} }},
+		"sqlite":     {"sqlite3", func() Driver { return &sqlite3Driver{} }, func() Dialect { return &sqlite3{} }},
+		"oci8":       {"oracle", func() Driver { return &oci8Driver{} }, func() Dialect { return &oracle{} }},
+		"godror":     {"oracle", func() Driver { return &godrorDriver{} }, func() Dialect { return &oracle{} }},
+		"clickhouse": {"clickhouse", func() Driver { return &clickhouseDriver{} }, func() Dialect { return &clickhouse{} }},
+		"cockroach":  {"cockroach", func() Driver { return &cockroachDriver{} }, func() Dialect { return &cockroach{} }},
+		"yugabyte":   {"yugabyte", func() Driver { return &yugabyteDriver{} }, func() Dialect { return &yugabyte{} }},
 	}
 	for driverName, v := range providedDrvsNDialects {
 		if driver := QueryDriver(driverName); driver == nil {
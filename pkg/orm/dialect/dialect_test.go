@@ -0,0 +1,160 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"testing"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+type sqlErrorNumber int32
+
+func (e sqlErrorNumber) Error() string         { return "mssql: error" }
+func (e sqlErrorNumber) SQLErrorNumber() int32 { return int32(e) }
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		dbType    schemasvr.DBType
+		err       error
+		retryable bool
+	}{
+		{schemasvr.POSTGRES, nil, false},
+		{schemasvr.POSTGRES, sqlStateError("40001"), true},
+		{schemasvr.POSTGRES, sqlStateError("40P01"), true},
+		{schemasvr.POSTGRES, sqlStateError("23505"), false},
+		{schemasvr.POSTGRES, errors.New("ERROR: deadlock detected (SQLSTATE 40P01)"), true},
+		{schemasvr.COCKROACH, errors.New("pq: restart transaction: TransactionRetryWithProtoRefreshError"), true},
+		{schemasvr.MYSQL, errors.New("Error 1213: Deadlock found when trying to get lock; try restarting transaction"), true},
+		{schemasvr.MYSQL, errors.New("Error 1205 (HY000): Lock wait timeout exceeded; try restarting transaction"), true},
+		{schemasvr.MYSQL, errors.New("Error 1062: Duplicate entry '1' for key 'PRIMARY'"), false},
+		{schemasvr.MSSQL, sqlErrorNumber(1205), true},
+		{schemasvr.MSSQL, sqlErrorNumber(2627), false},
+		{schemasvr.SQLITE, errors.New("database is locked"), true},
+		{schemasvr.SQLITE, errors.New("UNIQUE constraint failed: user.id"), false},
+		{schemasvr.ORACLE, errors.New("ORA-08177: can't serialize access for this transaction"), true},
+		{schemasvr.ORACLE, errors.New("ORA-00001: unique constraint violated"), false},
+		{schemasvr.CLICKHOUSE, errors.New("database is locked"), false},
+	}
+	for _, test := range tests {
+		dialect := QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&URI{DBType: test.dbType}))
+		assert.EqualValues(t, test.retryable, dialect.IsRetryableError(test.err), "%s %v", test.dbType, test.err)
+	}
+}
+
+func TestSavepointSQL(t *testing.T) {
+	tests := []struct {
+		dbType                       schemasvr.DBType
+		savepoint, rollback, release string
+	}{
+		{schemasvr.POSTGRES, "SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		{schemasvr.MYSQL, "SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		{schemasvr.SQLITE, "SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		{schemasvr.MSSQL, "SAVE TRANSACTION sp1", "ROLLBACK TRANSACTION sp1", ""},
+		{schemasvr.ORACLE, "SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", ""},
+		{schemasvr.CLICKHOUSE, "", "", ""},
+	}
+	for _, test := range tests {
+		dialect := QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&URI{DBType: test.dbType}))
+		assert.EqualValues(t, test.savepoint, dialect.SavepointSQL("sp1"), test.dbType)
+		assert.EqualValues(t, test.rollback, dialect.RollbackToSavepointSQL("sp1"), test.dbType)
+		assert.EqualValues(t, test.release, dialect.ReleaseSavepointSQL("sp1"), test.dbType)
+	}
+}
+
+func TestJSONPathSQL(t *testing.T) {
+	tests := []struct {
+		dbType schemasvr.DBType
+		path   string
+		sql    string
+	}{
+		{schemasvr.POSTGRES, "$.name", `"attrs" ->> 'name'`},
+		{schemasvr.POSTGRES, "$.address.city", `"attrs" #>> '{"address","city"}'`},
+		{schemasvr.POSTGRES, "$.tags[1]", `"attrs" #>> '{"tags",1}'`},
+		{schemasvr.POSTGRES, "$.tags[*].name", `jsonb_path_query_first("attrs"::jsonb, '$.tags[*].name') #>> '{}'`},
+		{schemasvr.MYSQL, "$.address.city", "JSON_UNQUOTE(JSON_EXTRACT(`attrs`, '$.address.city'))"},
+		{schemasvr.SQLITE, "$.address.city", "json_extract(`attrs`, '$.address.city')"},
+		{schemasvr.MSSQL, "$.address.city", "JSON_VALUE([attrs], '$.address.city')"},
+		{schemasvr.ORACLE, "$.a[?(@.b == 'x')]", `JSON_VALUE("attrs", '$.a[?(@.b == ''x'')]')`},
+		{schemasvr.MYSQL, `$.a[?(@.b == "x\' OR 1=1")]`, "JSON_UNQUOTE(JSON_EXTRACT(`attrs`, '$.a[?(@.b == \"x\\\\'' OR 1=1\")]'))"},
+	}
+	for _, test := range tests {
+		dialect := QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&URI{DBType: test.dbType}))
+		sql, err := JSONPathSQL(dialect, "attrs", test.path)
+		assert.NoError(t, err)
+		assert.EqualValues(t, test.sql, sql, test.dbType)
+	}
+
+	dialect := QueryDialect(schemasvr.SQLITE)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.SQLITE}))
+	_, err := JSONPathSQL(dialect, "attrs", "address.city")
+	assert.ErrorIs(t, err, ErrInvalidJSONPath)
+	for _, path := range []string{`$.a\' OR 1=1 -- `, `$."o'k"`, "$.a\nb"} {
+		_, err = JSONPathSQL(dialect, "attrs", path)
+		assert.ErrorIs(t, err, ErrInvalidJSONPath, path)
+	}
+	dialect = QueryDialect(schemasvr.CLICKHOUSE)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.CLICKHOUSE}))
+	_, err = JSONPathSQL(dialect, "attrs", "$.a")
+	assert.ErrorIs(t, err, ErrJSONPathUnsupported)
+}
+
+func TestJSONIndexSQL(t *testing.T) {
+	index := schemasvr.NewIndex("attrs_city", schemasvr.IndexType)
+	index.AddJSONPath("attrs", "$.city")
+	gin := schemasvr.NewIndex("attrs", schemasvr.IndexType)
+	gin.AddColumn("attrs")
+	gin.Method = "GIN"
+
+	dialect := QueryDialect(schemasvr.POSTGRES)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.POSTGRES}))
+	assert.EqualValues(t, `CREATE INDEX "IDX_user_attrs_city" ON "user" (("attrs" ->> 'city'))`, dialect.CreateIndexSQL("user", index))
+	assert.EqualValues(t, `CREATE INDEX "IDX_user_attrs" ON "user" USING GIN ("attrs")`, dialect.CreateIndexSQL("user", gin))
+
+	dialect = QueryDialect(schemasvr.MYSQL)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.MYSQL}))
+	assert.EqualValues(t, "CREATE INDEX `IDX_user_attrs_city` ON `user` ((CAST(JSON_UNQUOTE(JSON_EXTRACT(`attrs`, '$.city')) AS CHAR(255)) COLLATE utf8mb4_bin))", dialect.CreateIndexSQL("user", index))
+	assert.EqualValues(t, "CREATE INDEX `IDX_user_attrs` ON `user` (`attrs`)", dialect.CreateIndexSQL("user", gin))
+}
+
+func TestParseJSONIndexExpr(t *testing.T) {
+	tests := []struct {
+		expr, col, path string
+	}{
+		{"(json_extract(`attrs`, '$.address.city')))", "attrs", "$.address.city"},
+		{"((attrs ->> 'city'::text)))", "attrs", "$.city"},
+		{`((attrs #>> '{address,city,0}'::text[])))`, "attrs", "$.address.city[0]"},
+		{`(JSON_VALUE([attrs], '$.city')))`, "attrs", "$.city"},
+	}
+	for _, test := range tests {
+		col, path, ok := parseJSONIndexExpr(test.expr)
+		assert.True(t, ok, test.expr)
+		assert.EqualValues(t, test.col, col, test.expr)
+		assert.EqualValues(t, test.path, path, test.expr)
+	}
+	_, _, ok := parseJSONIndexExpr("`name`, `attrs`)")
+	assert.False(t, ok)
+}
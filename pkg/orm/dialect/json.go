@@ -0,0 +1,244 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+var (
+	// ErrInvalidJSONPath represents a JSON path which is not like $.a.b[0]
+	ErrInvalidJSONPath = errors.New("invalid JSON path")
+	// ErrJSONPathUnsupported represents the database has no JSON path function
+	ErrJSONPathUnsupported = errors.New("JSON path is not supported by the database")
+)
+
+// CondWriter is a builder.Writer which knows the dialect of the SQL it writes,
+// conditions rendered differently per database type assert it for the dialect
+type CondWriter struct {
+	*builder.BytesWriter
+	dialect Dialect
+}
+
+// NewCondWriter creates a CondWriter for the dialect
+func NewCondWriter(dialect Dialect) *CondWriter {
+	return &CondWriter{
+		BytesWriter: builder.NewWriter(),
+		dialect:     dialect,
+	}
+}
+
+// Dialect returns the dialect of the writer
+func (w *CondWriter) Dialect() Dialect {
+	return w.dialect
+}
+
+// jsonPathStep is one step of a JSON path, a member key or an array index
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+// validJSONKey reports whether the member key may be written into the string
+// literal of the path, the keys cannot have quotes, backslashes or control
+// characters which some databases read as escapes
+func validJSONKey(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c == '\'' || c == '\\' || c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// parseJSONPath parses a JSON path like $.a."b c"[0], simple is false when the
+// path has wildcards, recursive descents or filters which only the path
+// functions of the database understand
+func parseJSONPath(path string) (steps []jsonPathStep, simple bool, err error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, false, ErrInvalidJSONPath
+	}
+	simple = true
+	for i := 1; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+			if i >= len(path) {
+				return nil, false, ErrInvalidJSONPath
+			}
+			switch {
+			case path[i] == '"':
+				end := strings.IndexByte(path[i+1:], '"')
+				if end < 0 || !validJSONKey(path[i+1:i+1+end]) {
+					return nil, false, ErrInvalidJSONPath
+				}
+				steps = append(steps, jsonPathStep{key: path[i+1 : i+1+end], index: -1})
+				i += end + 2
+			case path[i] == '.':
+				simple = false
+			case path[i] == '*':
+				simple = false
+				i++
+			default:
+				end := i
+				for end < len(path) && path[end] != '.' && path[end] != '[' {
+					end++
+				}
+				if !validJSONKey(path[i:end]) {
+					return nil, false, ErrInvalidJSONPath
+				}
+				steps = append(steps, jsonPathStep{key: path[i:end], index: -1})
+				i = end
+			}
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, false, ErrInvalidJSONPath
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(path[i+1 : i+end]))
+			if err != nil || idx < 0 {
+				simple = false
+			} else {
+				steps = append(steps, jsonPathStep{index: idx})
+			}
+			i += end + 1
+		default:
+			return nil, false, ErrInvalidJSONPath
+		}
+	}
+	return steps, simple, nil
+}
+
+// CheckJSONPath returns an error if the path is not a JSON path like $.a.b[0]
+func CheckJSONPath(path string) error {
+	if _, _, err := parseJSONPath(path); err != nil {
+		return fmt.Errorf("%w: %s", err, path)
+	}
+	return nil
+}
+
+// quoteString quotes a string literal for SQL, mysql reads the backslashes of
+// string literals as escapes
+func quoteString(dialect Dialect, s string) string {
+	if dialect.URI().DBType == schemasvr.MYSQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// JSONPathSQL returns an expression which extracts the scalar value at the JSON
+// path of the column as text, i.e. col ->> 'a' on postgres, JSON_EXTRACT on
+// mysql and sqlite and JSON_VALUE on mssql and oracle
+func JSONPathSQL(dialect Dialect, col, path string) (string, error) {
+	steps, simple, err := parseJSONPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, path)
+	}
+	col = dialect.Quoter().Quote(col)
+	switch dbType := dialect.URI().DBType; {
+	case dbType.IsPostgres():
+		if !simple {
+			return fmt.Sprintf("jsonb_path_query_first(%s::jsonb, %s) #>> '{}'", col, quoteString(dialect, path)), nil
+		}
+		if len(steps) == 1 && steps[0].index < 0 {
+			return fmt.Sprintf("%s ->> %s", col, quoteString(dialect, steps[0].key)), nil
+		}
+		elems := make([]string, 0, len(steps))
+		for _, step := range steps {
+			if step.index >= 0 {
+				elems = append(elems, strconv.Itoa(step.index))
+			} else {
+				elems = append(elems, `"`+strings.ReplaceAll(step.key, `"`, `\"`)+`"`)
+			}
+		}
+		return fmt.Sprintf("%s #>> %s", col, quoteString(dialect, "{"+strings.Join(elems, ",")+"}")), nil
+	case dbType == schemasvr.MYSQL:
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, %s))", col, quoteString(dialect, path)), nil
+	case dbType == schemasvr.SQLITE:
+		return fmt.Sprintf("json_extract(%s, %s)", col, quoteString(dialect, path)), nil
+	case dbType == schemasvr.MSSQL, dbType == schemasvr.ORACLE, dbType == schemasvr.DAMENG:
+		return fmt.Sprintf("JSON_VALUE(%s, %s)", col, quoteString(dialect, path)), nil
+	}
+	return "", ErrJSONPathUnsupported
+}
+
+// indexColumnsSQL returns the column list of the index, the JSON paths are
+// indexed as expressions
+func indexColumnsSQL(dialect Dialect, index *schemasvr.Index) string {
+	quoter := dialect.Quoter()
+	cols := make([]string, 0, len(index.Cols))
+	for i, col := range index.Cols {
+		path := index.Path(i)
+		if path == "" {
+			cols = append(cols, quoter.Quote(col))
+			continue
+		}
+		expr, err := JSONPathSQL(dialect, col, path)
+		if err != nil {
+			// the paths are validated when the tags are parsed
+			cols = append(cols, quoter.Quote(col))
+			continue
+		}
+		if dialect.URI().DBType == schemasvr.MYSQL {
+			// functional indexes of mysql cannot be built on the LONGTEXT of JSON_UNQUOTE
+			expr = fmt.Sprintf("CAST(%s AS CHAR(255)) COLLATE utf8mb4_bin", expr)
+		}
+		cols = append(cols, "("+expr+")")
+	}
+	return strings.Join(cols, ",")
+}
+
+// jsonIndexExprRegexp matches the index expressions of indexColumnsSQL read back
+// from the database, json_extract(`col`, '$.a') or col #>> '{a,b}'::text[]
+var jsonIndexExprRegexp = regexp.MustCompile("^[\\s(]*(?:(?i:cast|json_unquote|json_extract|json_value)[\\s(]*)*" +
+	"[`\"\\[]?(\\w+)[`\"\\]]?\\s*(?:,\\s*'([^']*)'|(->>|#>>)\\s*'([^']*)')")
+
+// parseJSONIndexExpr returns the column and the JSON path of an index expression
+func parseJSONIndexExpr(expr string) (col, path string, ok bool) {
+	m := jsonIndexExprRegexp.FindStringSubmatch(expr)
+	if m == nil {
+		return "", "", false
+	}
+	switch m[3] {
+	case "":
+		return m[1], m[2], true
+	case "->>":
+		return m[1], "$." + m[4], true
+	}
+	path = "$"
+	for _, elem := range strings.Split(strings.Trim(m[4], "{}"), ",") {
+		elem = strings.Trim(elem, `"`)
+		if _, err := strconv.Atoi(elem); err == nil {
+			path += "[" + elem + "]"
+		} else if elem != "" {
+			path += "." + elem
+		}
+	}
+	return m[1], path, true
+}
@@ -337,7 +337,7 @@ func (db *mssql) SQLType(c *schemasvr.Column) string {
 		c.Length = 7
 	case schemasvr.MediumInt, schemasvr.TinyInt, schemasvr.SmallInt, schemasvr.UnsignedMediumInt, schemasvr.UnsignedTinyInt, schemasvr.UnsignedSmallInt:
 		res = schemasvr.Int
-	case schemasvr.Text, schemasvr.MediumText, schemasvr.TinyText, schemasvr.LongText, schemasvr.Json:
+	case schemasvr.Text, schemasvr.MediumText, schemasvr.TinyText, schemasvr.LongText, schemasvr.Json, schemasvr.Jsonb:
 		res = db.defaultVarchar + "(MAX)"
 	case schemasvr.Double:
 		res = schemasvr.Real
@@ -635,12 +635,58 @@ func (db *mssql) CreateTableSQL(ctx context.Context, queryer core.Queryer, table
 	b.WriteString(")")
 	return b.String(), true, nil
 }
-func (db *mssql) ForUpdateSQL(query string) string {
-	return query
+// ForUpdateSQL returns the query, mssql locks the rows with the table hints of LockHint
+func (db *mssql) ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error) {
+	return query, nil
+}
+
+// LockHint returns the table hints locking the selected rows
+func (db *mssql) LockHint(opts *ForUpdateOptions) (string, error) {
+	if opts == nil {
+		opts = &ForUpdateOptions{}
+	}
+	if err := opts.check(db.uri.DBType, true, true, true, false); err != nil {
+		return "", err
+	}
+	hints := []string{"UPDLOCK", "ROWLOCK"}
+	if opts.Share {
+		hints[0] = "HOLDLOCK"
+	}
+	if opts.NoWait {
+		hints = append(hints, "NOWAIT")
+	} else if opts.SkipLocked {
+		hints = append(hints, "READPAST")
+	}
+	return "WITH (" + strings.Join(hints, ", ") + ")", nil
 }
 func (db *mssql) Filters() []Filter {
 	return []Filter{}
 }
+func (db *mssql) SavepointSQL(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+func (db *mssql) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+// ReleaseSavepointSQL returns empty, mssql keeps the savepoints until the transaction ends
+func (db *mssql) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// IsRetryableError returns true when the transaction was chosen as deadlock
+// victim or aborted by a snapshot isolation update conflict
+func (db *mssql) IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var numErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &numErr) {
+		n := numErr.SQLErrorNumber()
+		return n == 1205 || n == 3960
+	}
+	return strings.Contains(err.Error(), "deadlocked on lock")
+}
 
 type odbcDriver struct {
 	baseDriver
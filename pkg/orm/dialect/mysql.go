@@ -310,6 +310,8 @@ func (db *mysql) SQLType(c *schemasvr.Column) string {
 		c.Length = 40
 	case schemasvr.Json:
 		res = schemasvr.Text
+	case schemasvr.Jsonb:
+		res = schemasvr.Json
 	case schemasvr.UnsignedInt:
 		res = schemasvr.Int
 		isUnsigned = true
@@ -559,7 +561,8 @@ func (db *mysql) GetIndexes(queryer core.Queryer, ctx context.Context, tableName
 	indexes := make(map[string]*schemasvr.Index)
 	for rows.Next() {
 		var indexType int
-		var indexName, colName, nonUnique string
+		var indexName, nonUnique string
+		var colName sql.NullString
 		err = rows.Scan(&indexName, &nonUnique, &colName)
 		if err != nil {
 			return nil, err
@@ -572,7 +575,6 @@ func (db *mysql) GetIndexes(queryer core.Queryer, ctx context.Context, tableName
 		} else {
 			indexType = schemasvr.UniqueType
 		}
-		colName = strings.Trim(colName, "` ")
 		var isRegular bool
 		if strings.HasPrefix(indexName, "IDX_"+tableName) || strings.HasPrefix(indexName, "UQE_"+tableName) {
 			indexName = indexName[5+len(tableName):]
@@ -587,7 +589,10 @@ func (db *mysql) GetIndexes(queryer core.Queryer, ctx context.Context, tableName
 			index.Name = indexName
 			indexes[indexName] = index
 		}
-		index.AddColumn(colName)
+		// the functional key parts have no column name
+		if colName.Valid {
+			index.AddColumn(strings.Trim(colName.String, "` "))
+		}
 	}
 	if rows.Err() != nil {
 		return nil, rows.Err()
@@ -649,6 +654,30 @@ func (db *mysql) Filters() []Filter {
 	return []Filter{}
 }
 
+// ForUpdateSQL appends FOR UPDATE or FOR SHARE [OF tables] [NOWAIT | SKIP LOCKED] to the query,
+// a plain share lock uses LOCK IN SHARE MODE which is understood before mysql 8.0 too
+func (db *mysql) ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error) {
+	if opts == nil {
+		return query + " FOR UPDATE", nil
+	}
+	if err := opts.check(db.uri.DBType, true, true, true, true); err != nil {
+		return "", err
+	}
+	if opts.Share && len(opts.Of) == 0 && !opts.NoWait && !opts.SkipLocked {
+		return query + " LOCK IN SHARE MODE", nil
+	}
+	return forUpdateSQL(db.Quoter(), query, opts), nil
+}
+
+// IsRetryableError returns true on deadlocks and lock wait timeouts
+func (db *mysql) IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1213") || strings.Contains(msg, "Error 1205")
+}
+
 type mysqlDriver struct {
 	baseDriver
 }
@@ -568,7 +568,7 @@ func (db *oracle) SQLType(c *schemasvr.Column) string {
 		res = "TIMESTAMP WITH TIME ZONE"
 	case schemasvr.Float, schemasvr.Double, schemasvr.Numeric, schemasvr.Decimal:
 		res = "NUMBER"
-	case schemasvr.Text, schemasvr.MediumText, schemasvr.LongText, schemasvr.Json:
+	case schemasvr.Text, schemasvr.MediumText, schemasvr.LongText, schemasvr.Json, schemasvr.Jsonb:
 		res = "CLOB"
 	case schemasvr.Char, schemasvr.Varchar, schemasvr.TinyText:
 		res = "VARCHAR2"
@@ -822,6 +822,16 @@ func (db *oracle) Filters() []Filter {
 	}
 }
 
+// ReleaseSavepointSQL returns empty, oracle keeps the savepoints until the transaction ends
+func (db *oracle) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// IsRetryableError returns true on deadlocks and serialization failures
+func (db *oracle) IsRetryableError(err error) bool {
+	return isOracleRetryableError(err)
+}
+
 type godrorDriver struct {
 	baseDriver
 }
@@ -895,3 +905,13 @@ func (o *oci8Driver) Parse(driverName, dataSourceName string) (*URI, error) {
 	}
 	return db, nil
 }
+
+// isOracleRetryableError returns true on ORA-00060 deadlock detected and
+// ORA-08177 can't serialize access for this transaction
+func isOracleRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "ORA-00060") || strings.Contains(msg, "ORA-08177")
+}
@@ -1011,6 +1011,23 @@ func (db *postgres) ModifyColumnSQL(tableName string, col *schemasvr.Column) str
 	commentSQL += fmt.Sprintf("COMMENT ON COLUMN %s.%s.%s IS '%s'", quoter.Quote(db.getSchema()), quoter.Quote(tableName), quoter.Quote(col.Name), col.Comment)
 	return modifyColumnSQL + commentSQL
 }
+
+// CreateIndexSQL returns a SQL to create index with its access method, i.e. a
+// GIN index for a jsonb column
+func (db *postgres) CreateIndexSQL(tableName string, index *schemasvr.Index) string {
+	if index.Method == "" {
+		return db.Base.CreateIndexSQL(tableName, index)
+	}
+	quoter := db.dialect.Quoter()
+	var unique string
+	if index.Type == schemasvr.UniqueType {
+		unique = " UNIQUE"
+	}
+	return fmt.Sprintf("CREATE%s INDEX %v ON %v USING %s (%v)", unique,
+		quoter.Quote(index.XName(tableName)), quoter.Quote(tableName),
+		index.Method, indexColumnsSQL(db.dialect, index))
+}
+
 func (db *postgres) DropIndexSQL(tableName string, index *schemasvr.Index) string {
 	idxName := index.Name
 	tableParts := strings.Split(strings.Replace(tableName, `"`, "", -1), ".")
@@ -1092,10 +1109,10 @@ WHERE n.nspname= s.table_schema AND c.relkind = 'r'::char AND c.relname = $1%s A
 				return nil, nil, err
 			}
 		}
-		if colDefault != nil && *colDefault == "unique_rowid()" { // ignore the system column added by cockroach
+		col.Name = strings.Trim(colName, `" `)
+		if col.Name == "rowid" && colDefault != nil && *colDefault == "unique_rowid()" { // ignore the system column added by cockroach
 			continue
 		}
-		col.Name = strings.Trim(colName, `" `)
 		if colDefault != nil {
 			var theDefault = *colDefault
 			// cockroach has type with the default value with :::
@@ -1112,7 +1129,7 @@ WHERE n.nspname= s.table_schema AND c.relkind = 'r'::char AND c.relname = $1%s A
 			}
 			col.Default = theDefault
 			col.DefaultIsEmpty = false
-			if strings.HasPrefix(col.Default, "nextval(") {
+			if strings.HasPrefix(col.Default, "nextval(") || col.Default == "unique_rowid()" {
 				col.IsAutoIncrement = true
 				col.Default = ""
 				col.DefaultIsEmpty = true
@@ -1211,13 +1228,62 @@ func (db *postgres) GetTables(queryer core.Queryer, ctx context.Context) ([]*sch
 	return tables, nil
 }
 func getIndexColName(indexdef string) []string {
+	start := strings.Index(indexdef, "(")
+	if start == -1 {
+		return nil
+	}
+	return splitIndexColumns(indexdef[start+1:])
+}
+
+// splitIndexColumns returns the column names of an index column list up to
+// its closing parenthesis, yugabyte groups the hash columns like ((a, b) HASH, c ASC)
+func splitIndexColumns(s string) []string {
 	var colNames []string
-	cs := strings.Split(indexdef, "(")
-	for _, v := range strings.Split(strings.Split(cs[1], ")")[0], ",") {
-		colNames = append(colNames, strings.Split(strings.TrimLeft(v, " "), " ")[0])
+	var depth, begin int
+	appendCol := func(v string) {
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "(") {
+			colNames = append(colNames, splitIndexColumns(v[1:])...)
+			return
+		}
+		if v != "" {
+			colNames = append(colNames, strings.Split(v, " ")[0])
+		}
+	}
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				appendCol(s[begin:i])
+				return colNames
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				appendCol(s[begin:i])
+				begin = i + 1
+			}
+		}
 	}
+	appendCol(s[begin:])
 	return colNames
 }
+
+// indexMethod returns the access method of an index definition
+func indexMethod(indexdef string) string {
+	idx := strings.Index(indexdef, " USING ")
+	if idx == -1 {
+		return ""
+	}
+	fields := strings.Fields(indexdef[idx+7:])
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
 func (db *postgres) GetIndexes(queryer core.Queryer, ctx context.Context, tableName string) (map[string]*schemasvr.Index, error) {
 	args := []interface{}{tableName}
 	s := "SELECT indexname, indexdef FROM pg_indexes WHERE tablename=$1"
@@ -1273,11 +1339,18 @@ func (db *postgres) GetIndexes(queryer core.Queryer, ctx context.Context, tableN
 			}
 		}
 		index := &schemasvr.Index{Name: indexName, Type: indexType, Cols: make([]string, 0)}
+		if col, path, ok := parseJSONIndexExpr(indexdef[strings.Index(indexdef, "(")+1:]); ok {
+			colNames = nil
+			index.AddJSONPath(col, path)
+		}
 		for _, colName := range colNames {
 			col := strings.TrimSpace(strings.Replace(colName, `"`, "", -1))
 			fields := strings.Split(col, " ")
 			index.Cols = append(index.Cols, fields[0])
 		}
+		if method := indexMethod(indexdef); method != "BTREE" {
+			index.Method = method
+		}
 		index.IsRegular = isRegular
 		indexes[index.Name] = index
 	}
@@ -1306,6 +1379,29 @@ func (db *postgres) Filters() []Filter {
 	return []Filter{&SeqFilter{Prefix: "$", Start: 1}}
 }
 
+// ForUpdateSQL appends FOR UPDATE or FOR SHARE [OF tables] [NOWAIT | SKIP LOCKED] to the query
+func (db *postgres) ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error) {
+	if opts == nil {
+		return query + " FOR UPDATE", nil
+	}
+	if err := opts.check(db.uri.DBType, true, true, true, true); err != nil {
+		return "", err
+	}
+	return forUpdateSQL(db.Quoter(), query, opts), nil
+}
+
+// IsRetryableError returns true on serialization failures and deadlocks
+func (db *postgres) IsRetryableError(err error) bool {
+	if IsSerializationFailure(err) {
+		return true
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == "40P01"
+	}
+	return err != nil && strings.Contains(err.Error(), "SQLSTATE 40P01")
+}
+
 type pqDriver struct {
 	baseDriver
 }
@@ -1480,3 +1576,20 @@ func QueryDefaultPostgresSchema(ctx context.Context, queryer core.Queryer) (stri
 	}
 	return "", errors.New("no default schema")
 }
+
+// IsSerializationFailure returns true if the error is a postgres serialization
+// failure, SQLSTATE 40001, which aborts the transaction and asks the client to
+// run it again. cockroach and yugabyte return it on transaction conflicts.
+func IsSerializationFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == "40001"
+	}
+	// the errors of old lib/pq versions have no SQLState method, cockroach
+	// prefixes the message with restart transaction and pgx appends (SQLSTATE 40001)
+	msg := err.Error()
+	return strings.Contains(msg, "SQLSTATE 40001") || strings.Contains(msg, "restart transaction")
+}
@@ -122,4 +122,14 @@ func TestGetIndexColName(t *testing.T) {
 		assert.Equal(t, []string{"major"}, colNames)
 	})
 	t.Run("Indexes on Expressions", func(t *testing.T) {})
+	t.Run("Yugabyte hash columns", func(t *testing.T) {
+		s := "CREATE INDEX test2_mm_idx ON public.test2 USING lsm ((major, minor) HASH, patch ASC)"
+		colNames := getIndexColName(s)
+		assert.Equal(t, []string{"major", "minor", "patch"}, colNames)
+	})
+	t.Run("Cockroach storing", func(t *testing.T) {
+		s := "CREATE INDEX test2_mm_idx ON db.public.test2 USING btree (major ASC) STORING (minor)"
+		colNames := getIndexColName(s)
+		assert.Equal(t, []string{"major"}, colNames)
+	})
 }
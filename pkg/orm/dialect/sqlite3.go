@@ -230,7 +230,7 @@ func (db *sqlite3) SQLType(c *schemasvr.Column) string {
 	case schemasvr.TimeStampz:
 		return schemasvr.Text
 	case schemasvr.Char, schemasvr.Varchar, schemasvr.NVarchar, schemasvr.TinyText,
-		schemasvr.Text, schemasvr.MediumText, schemasvr.LongText, schemasvr.Json:
+		schemasvr.Text, schemasvr.MediumText, schemasvr.LongText, schemasvr.Json, schemasvr.Jsonb:
 		return schemasvr.Text
 	case schemasvr.Bit, schemasvr.TinyInt, schemasvr.UnsignedTinyInt, schemasvr.SmallInt,
 		schemasvr.UnsignedSmallInt, schemasvr.MediumInt, schemasvr.Int, schemasvr.UnsignedInt,
@@ -292,8 +292,15 @@ func (db *sqlite3) DropIndexSQL(tableName string, index *schemasvr.Index) string
 	}
 	return fmt.Sprintf("DROP INDEX %v", db.Quoter().Quote(idxName))
 }
-func (db *sqlite3) ForUpdateSQL(query string) string {
-	return query
+// ForUpdateSQL returns the query, sqlite locks the whole database in a
+// write transaction so there is nothing to skip or to wait for
+func (db *sqlite3) ForUpdateSQL(query string, opts *ForUpdateOptions) (string, error) {
+	if opts != nil {
+		if err := opts.check(db.uri.DBType, true, false, false, true); err != nil {
+			return "", err
+		}
+	}
+	return query, nil
 }
 func (db *sqlite3) IsColumnExist(queryer core.Queryer, ctx context.Context, tableName, colName string) (bool, error) {
 	query := "SELECT * FROM " + tableName + " LIMIT 0"
@@ -489,10 +496,14 @@ func (db *sqlite3) GetIndexes(queryer core.Queryer, ctx context.Context, tableNa
 		}
 		nStart := strings.Index(sql, "(")
 		nEnd := strings.Index(sql, ")")
-		colIndexes := strings.Split(sql[nStart+1:nEnd], ",")
 		index.Cols = make([]string, 0)
-		for _, col := range colIndexes {
-			index.Cols = append(index.Cols, strings.Trim(col, "` []"))
+		if col, path, ok := parseJSONIndexExpr(sql[nStart+1:]); ok {
+			index.AddJSONPath(col, path)
+		} else {
+			colIndexes := strings.Split(sql[nStart+1:nEnd], ",")
+			for _, col := range colIndexes {
+				index.Cols = append(index.Cols, strings.Trim(col, "` []"))
+			}
 		}
 		index.IsRegular = isRegular
 		indexes[index.Name] = index
@@ -506,6 +517,15 @@ func (db *sqlite3) Filters() []Filter {
 	return []Filter{}
 }
 
+// IsRetryableError returns true when the database was locked by another connection
+func (db *sqlite3) IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
 type sqlite3Driver struct {
 	baseDriver
 }
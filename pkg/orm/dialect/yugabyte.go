@@ -0,0 +1,93 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bhojpur/dbm/pkg/orm/core"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// yugabyte speaks the postgres dialect with serial columns, its distributed
+// transactions abort on conflicts and have to be retried
+type yugabyte struct {
+	postgres
+}
+
+func (db *yugabyte) Init(uri *URI) error {
+	db.quoter = postgresQuoter
+	return db.Base.Init(db, uri)
+}
+func (db *yugabyte) Version(ctx context.Context, queryer core.Queryer) (*schemasvr.Version, error) {
+	rows, err := queryer.QueryContext(ctx, "SELECT version()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var version string
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+		return nil, errors.New("unknow version")
+	}
+	if err := rows.Scan(&version); err != nil {
+		return nil, err
+	}
+	// PostgreSQL 11.2-YB-2.18.0.0-b0 on x86_64-pc-linux-gnu, compiled by clang version 15.0.3, 64-bit
+	versions := strings.Split(strings.TrimPrefix(version, "PostgreSQL "), " on ")
+	idx := strings.Index(versions[0], "-YB-")
+	if idx == -1 {
+		return nil, errors.New("unknow database version")
+	}
+	v := &schemasvr.Version{
+		Number:  strings.SplitN(versions[0][idx+4:], "-b", 2)[0],
+		Edition: "YugabyteDB",
+	}
+	if len(versions) > 1 {
+		v.Level = versions[1]
+	}
+	return v, nil
+}
+func (db *yugabyte) Features() *DialectFeatures {
+	return &DialectFeatures{
+		AutoincrMode: IncrAutoincrMode,
+		RetryTx:      true,
+	}
+}
+
+// yugabyteDriver parses the postgres connection strings of yugabyte, the
+// database/sql driver, lib/pq or pgx, has to be registered with the same name
+type yugabyteDriver struct {
+	pqDriverPgx
+}
+
+func (p *yugabyteDriver) Parse(driverName, dataSourceName string) (*URI, error) {
+	uri, err := p.pqDriverPgx.Parse(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	uri.DBType = schemasvr.YUGABYTE
+	return uri, nil
+}
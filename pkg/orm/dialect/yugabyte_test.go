@@ -0,0 +1,54 @@
+package dialect
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYugabyte(t *testing.T) {
+	driver := QueryDriver("yugabyte")
+	uri, err := driver.Parse("yugabyte", "host=localhost port=5433 user=yugabyte dbname=yugabyte sslmode=disable")
+	assert.NoError(t, err)
+	assert.EqualValues(t, schemasvr.YUGABYTE, uri.DBType)
+	assert.EqualValues(t, "yugabyte", uri.DBName)
+}
+
+func TestYugabyteSQLType(t *testing.T) {
+	dialect := QueryDialect(schemasvr.YUGABYTE)
+	assert.NoError(t, dialect.Init(&URI{DBType: schemasvr.YUGABYTE}))
+	assert.True(t, dialect.Features().RetryTx)
+	assert.True(t, dialect.URI().DBType.IsPostgres())
+
+	col := &schemasvr.Column{
+		Name:            "id",
+		SQLType:         schemasvr.SQLType{Name: schemasvr.BigInt},
+		IsPrimaryKey:    true,
+		IsAutoIncrement: true,
+		DefaultIsEmpty:  true,
+	}
+	s, err := ColumnString(dialect, col, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, `"id" BIGSERIAL PRIMARY KEY  NOT NULL`, s)
+}
@@ -25,16 +25,19 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"reflect"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm/cache"
 	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
 	"github.com/bhojpur/dbm/pkg/orm/core"
 	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
@@ -60,6 +63,10 @@ type Engine struct {
 	TZLocation     *time.Location // The timezone of the application
 	DatabaseTZ     *time.Location // The timezone of the database
 	logSessionID   bool           // create session id
+	queryStatsLock sync.Mutex
+	queryStatsHook *queryStatsHook
+	encryptor      *convert.Encryptor // encrypts the columns tagged encrypted
+	events         eventBus           // the subscribers of the changes of the rows
 }
 
 // NewEngine new a db manager according to the parameter. Currently support four
@@ -271,6 +278,16 @@ func (engine *Engine) SetDefaultCacher(cacher cache.Cacher) {
 	engine.cacherMgr.SetDefaultCacher(cacher)
 }
 
+// SetKeyProvider sets the provider of the keys encrypting the columns tagged encrypted,
+// nil removes it
+func (engine *Engine) SetKeyProvider(provider convert.KeyProvider) {
+	if provider == nil {
+		engine.encryptor = nil
+		return
+	}
+	engine.encryptor = convert.NewEncryptor(provider)
+}
+
 // GetDefaultCacher returns the default cacher
 func (engine *Engine) GetDefaultCacher() cache.Cacher {
 	return engine.cacherMgr.GetDefaultCacher()
@@ -439,7 +456,7 @@ func (engine *Engine) DumpTables(tables []*schemasvr.Table, w io.Writer, tp ...s
 	return engine.dumpTables(context.Background(), tables, w, tp...)
 }
 func formatBool(s bool, dstDialect dialectsvr.Dialect) string {
-	if dstDialect.URI().DBType != schemasvr.POSTGRES {
+	if !dstDialect.URI().DBType.IsPostgres() {
 		if s {
 			return "1"
 		}
@@ -466,7 +483,7 @@ func (engine *Engine) dumpTables(ctx context.Context, tables []*schemasvr.Table,
 			DBName: uri.DBName,
 			// DO NOT SET SCHEMA HERE
 		}
-		if tp[0] == schemasvr.POSTGRES {
+		if tp[0].IsPostgres() {
 			destURI.Schema = engine.dialect.URI().Schema
 		}
 		if err := dstDialect.Init(&destURI); err != nil {
@@ -596,7 +613,7 @@ func (engine *Engine) dumpTables(ctx context.Context, tables []*schemasvr.Table,
 						if _, err := io.WriteString(w, "''"); err != nil {
 							return err
 						}
-					} else if dstDialect.URI().DBType == schemasvr.POSTGRES {
+					} else if dstDialect.URI().DBType.IsPostgres() {
 						if dstTable.Columns()[i].SQLType.IsBlob() {
 							// Postgres has the escape format and we should use that for bytea data
 							if _, err := fmt.Fprintf(w, "'\\x%x'", s.String); err != nil {
@@ -789,7 +806,7 @@ func (engine *Engine) dumpTables(ctx context.Context, tables []*schemasvr.Table,
 			return rows.Err()
 		}
 		// FIXME: Hack for postgres
-		if dstDialect.URI().DBType == schemasvr.POSTGRES && table.AutoIncrColumn() != nil {
+		if (dstDialect.URI().DBType == schemasvr.POSTGRES || dstDialect.URI().DBType == schemasvr.YUGABYTE) && table.AutoIncrColumn() != nil {
 			_, err = io.WriteString(w, "SELECT setval('"+dstTableName+"_id_seq', COALESCE((SELECT MAX("+table.AutoIncrColumn().Name+") + 1 FROM "+dstDialect.Quoter().Quote(dstTableName)+"), 1), false);\n")
 			if err != nil {
 				return err
@@ -841,7 +858,7 @@ func (engine *Engine) Charset(charset string) *Session {
 	return session.Charset(charset)
 }
 
-// StoreEngine set store engine when create table, only support mysql now
+// StoreEngine set store engine when create table, only support mysql and clickhouse now
 func (engine *Engine) StoreEngine(storeEngine string) *Session {
 	session := engine.NewSession()
 	session.isAutoClose = true
@@ -1020,6 +1037,55 @@ func (engine *Engine) Having(conditions string) *Session {
 	return session.Having(conditions)
 }
 
+// With adds a common table expression to the query
+func (engine *Engine) With(name string, query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.With(name, query, args...)
+}
+
+// WithRecursive adds a common table expression which could refer to itself
+func (engine *Engine) WithRecursive(name string, query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithRecursive(name, query, args...)
+}
+
+// Union combines the rows with the rows of another query without duplicates
+func (engine *Engine) Union(query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Union(query, args...)
+}
+
+// UnionAll combines the rows with all the rows of another query
+func (engine *Engine) UnionAll(query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.UnionAll(query, args...)
+}
+
+// Intersect keeps the rows another query returns too
+func (engine *Engine) Intersect(query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Intersect(query, args...)
+}
+
+// Except keeps the rows another query does not return
+func (engine *Engine) Except(query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Except(query, args...)
+}
+
+// Over selects the result of the window function into the column
+func (engine *Engine) Over(column, function, partitionBy, orderBy string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Over(column, function, partitionBy, orderBy)
+}
+
 // DBVersion returns the database version
 func (engine *Engine) DBVersion() (*schemasvr.Version, error) {
 	return engine.dialect.Version(engine.defaultContext, engine.db)
@@ -1258,6 +1324,20 @@ func (engine *Engine) Rows(bean interface{}) (*Rows, error) {
 	return session.Rows(bean)
 }
 
+// Export writes the rows of the table of bean in the format
+func (engine *Engine) Export(w io.Writer, format DataFormat, bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.Table(bean).Export(w, format)
+}
+
+// ImportRows reads the rows of the format and inserts them into the table of bean
+func (engine *Engine) ImportRows(r io.Reader, format DataFormat, bean interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.ImportRows(r, format, bean)
+}
+
 // Count counts the records. bean's non-empty fields are conditions.
 func (engine *Engine) Count(bean ...interface{}) (int64, error) {
 	session := engine.NewSession()
@@ -1357,6 +1437,35 @@ func (engine *Engine) AddHook(hook ctxsvr.Hook) {
 	engine.db.AddHook(hook)
 }
 
+// AsOf queries the rows of the audited table as they were at t
+func (engine *Engine) AsOf(t time.Time) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.AsOf(t)
+}
+
+// AllTenants disables struct tag "tenant", the admin jobs work on the rows of all the tenants
+func (engine *Engine) AllTenants() *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.AllTenants()
+}
+
+// Scopes applies the named scopes of the table, see schemasvr.Scoper
+func (engine *Engine) Scopes(names ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Scopes(names...)
+}
+
+// WithoutScopes disables the named default scopes of the table, all of them if
+// no name is given
+func (engine *Engine) WithoutScopes(names ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithoutScopes(names...)
+}
+
 // Unscoped always disable struct tag "deleted"
 func (engine *Engine) Unscoped() *Session {
 	session := engine.NewSession()
@@ -1386,19 +1495,101 @@ func (engine *Engine) PingContext(ctx context.Context) error {
 	return session.PingContext(ctx)
 }
 
-// Transaction Execute sql wrapped in a transaction(abbr as tx), tx will automatic commit if no errors occurred
+// Transaction Execute sql wrapped in a transaction(abbr as tx), tx will automatic commit if no errors occurred.
+// Calling Transaction of the Session passed to f instead nests a transaction in a savepoint.
+// On cockroach and yugabyte f is called again in a new transaction when the transaction
+// aborts with a serialization failure, SQLSTATE 40001, so f should have no other side effects.
 func (engine *Engine) Transaction(f func(*Session) (interface{}, error)) (interface{}, error) {
-	session := engine.NewSession()
-	defer session.Close()
-	if err := session.Begin(); err != nil {
-		return nil, err
+	if engine.dialect.Features().RetryTx {
+		return engine.TransactionWithRetry(engine.defaultContext, &TxRetryOptions{
+			Retryable: dialectsvr.IsSerializationFailure,
+		}, f)
 	}
-	result, err := f(session)
-	if err != nil {
-		return result, err
+	return engine.transaction(nil, nil, f)
+}
+
+// TxRetryOptions configures TransactionWithRetry, the zero value is usable
+type TxRetryOptions struct {
+	MaxAttempts int                  // the number of times f runs at most, default is 5
+	MinBackoff  time.Duration        // the delay before the first retry, default is 10ms
+	MaxBackoff  time.Duration        // the upper bound of the delays, default is 1s
+	Retryable   func(err error) bool // overrides the dialect classification of the retryable errors
+	OnRetry     func(attempt int, err error)
+	TxOptions   *sql.TxOptions // the isolation level and the read only flag of the transactions
+}
+
+// TransactionWithRetry runs f in a transaction like Transaction, but when the
+// transaction fails with an error the dialect classifies as retryable, like a
+// serialization failure on postgres or a deadlock on mysql, the transaction is
+// rolled back and f is called again in a new one after an exponential backoff
+// with jitter. The attempt is put into the context of the session, see
+// ctxsvr.TxAttempt, so the hooks know it. f should have no other side effects.
+func (engine *Engine) TransactionWithRetry(ctx context.Context, opts *TxRetryOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	return engine.retryTransaction(ctx, opts, engine.NewSession, f)
+}
+func (engine *Engine) retryTransaction(ctx context.Context, opts *TxRetryOptions, newSession func() *Session, f func(*Session) (interface{}, error)) (interface{}, error) {
+	var o TxRetryOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 10 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Second
 	}
-	if err := session.Commit(); err != nil {
-		return result, err
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
 	}
-	return result, nil
+	if o.Retryable == nil {
+		o.Retryable = engine.dialect.IsRetryableError
+	}
+	if ctx == nil {
+		ctx = engine.defaultContext
+	}
+	backoff := o.MinBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := runTransaction(newSession(), ctxsvr.WithTxAttempt(ctx, attempt), o.TxOptions, f)
+		if err == nil || attempt >= o.MaxAttempts || !o.Retryable(err) {
+			return result, err
+		}
+		if o.OnRetry != nil {
+			o.OnRetry(attempt, err)
+		}
+		engine.logger.Warnf("transaction attempt %d failed and will be retried: %v", attempt, err)
+		// equal jitter waits at least half of the backoff and keeps the conflicting
+		// transactions from retrying in lockstep
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > o.MaxBackoff {
+			backoff = o.MaxBackoff
+		}
+	}
+}
+func (engine *Engine) transaction(ctx context.Context, opts *sql.TxOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	return runTransaction(engine.NewSession(), ctx, opts, f)
+}
+
+// runTransaction runs f in a transaction of the session and closes the session
+func runTransaction(session *Session, ctx context.Context, opts *sql.TxOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	if ctx != nil {
+		session.Context(ctx)
+	}
+	defer session.Close()
+	return session.transaction(opts, f)
+}
+
+// TransactionWithOptions runs f in a transaction like Transaction, begun in the
+// context with the isolation level and the read only flag of opts
+func (engine *Engine) TransactionWithOptions(ctx context.Context, opts *sql.TxOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	return engine.transaction(ctx, opts, f)
 }
@@ -0,0 +1,871 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm/core"
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// DumpFormatVersion is the version of the streaming dump format written by DumpStream
+const DumpFormatVersion = 1
+
+// enumerates the record types of a streaming dump, every record is a JSON line
+const (
+	dumpRecordHeader   = "header"
+	dumpRecordTable    = "table"
+	dumpRecordRows     = "rows"
+	dumpRecordTableEnd = "table_end"
+	dumpRecordEnd      = "end"
+)
+
+// list all the streaming dump errors
+var (
+	// ErrDumpChecksum represents the rows of a table don't match the checksum of the dump
+	ErrDumpChecksum = errors.New("dump checksum mismatch")
+	// ErrDumpFormat represents the stream is not a valid dump
+	ErrDumpFormat = errors.New("invalid dump format")
+	// ErrDumpResumeNoPK represents a dump of a table without primary key is resumed
+	ErrDumpResumeNoPK = errors.New("dump of a table without primary key cannot be resumed")
+)
+
+// DumpPosition represents how far the dump or restore of a table went, it
+// could be persisted via OnProgress and passed back via Resume
+type DumpPosition struct {
+	Table string `json:"table"`
+	// LastPK is the primary key of the last row dumped
+	LastPK []string `json:"last_pk,omitempty"`
+	// Rows is the number of rows processed
+	Rows int64 `json:"rows"`
+	Done bool  `json:"done,omitempty"`
+}
+
+// DumpOptions represents the options of DumpStream
+type DumpOptions struct {
+	// Tables to dump, all the tables if empty
+	Tables []string
+	// BatchSize is the rows of a batch, default is 1000
+	BatchSize int
+	// Parallel is the number of tables exported concurrently, default is 1
+	Parallel int
+	// Resume skips the tables done and the rows before LastPK of the others,
+	// the tables without primary key have no stable order and can only be
+	// resumed once done
+	Resume []DumpPosition
+	// OnProgress is called after every batch written
+	OnProgress func(DumpPosition)
+}
+
+// RestoreOptions represents the options of Restore
+type RestoreOptions struct {
+	// BatchSize is the max rows of one INSERT statement, default is 500
+	BatchSize int
+	// Resume skips the tables done and the first Rows rows of the others
+	Resume []DumpPosition
+	// OnProgress is called after every batch restored
+	OnProgress func(DumpPosition)
+	// SkipCreate doesn't create the missing tables and indexes
+	SkipCreate bool
+}
+
+type dumpColumn struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Length         int    `json:"length,omitempty"`
+	Length2        int    `json:"length2,omitempty"`
+	Nullable       bool   `json:"nullable,omitempty"`
+	Default        string `json:"default,omitempty"`
+	DefaultIsEmpty bool   `json:"default_is_empty,omitempty"`
+	PK             bool   `json:"pk,omitempty"`
+	AutoIncr       bool   `json:"autoincr,omitempty"`
+	Comment        string `json:"comment,omitempty"`
+	// Base64 represents the values are base64 encoded binary
+	Base64 bool `json:"base64,omitempty"`
+}
+
+type dumpIndex struct {
+	Name      string   `json:"name"`
+	Type      int      `json:"type"`
+	IsRegular bool     `json:"is_regular,omitempty"`
+	Cols      []string `json:"cols"`
+}
+
+type dumpTable struct {
+	Columns []dumpColumn `json:"columns"`
+	Indexes []dumpIndex  `json:"indexes,omitempty"`
+	Comment string       `json:"comment,omitempty"`
+}
+
+type dumpRecord struct {
+	Type     string      `json:"type"`
+	Version  int         `json:"version,omitempty"`
+	DBType   string      `json:"db_type,omitempty"`
+	Created  *time.Time  `json:"created,omitempty"`
+	Table    string      `json:"table,omitempty"`
+	Schema   *dumpTable  `json:"schema,omitempty"`
+	Rows     [][]*string `json:"rows,omitempty"`
+	LastPK   []string    `json:"last_pk,omitempty"`
+	Count    int64       `json:"count,omitempty"`
+	Checksum string      `json:"checksum,omitempty"`
+}
+
+func newDumpTable(table *schemasvr.Table) *dumpTable {
+	dt := &dumpTable{Comment: table.Comment}
+	for _, col := range table.Columns() {
+		dt.Columns = append(dt.Columns, dumpColumn{
+			Name:           col.Name,
+			Type:           col.SQLType.Name,
+			Length:         col.Length,
+			Length2:        col.Length2,
+			Nullable:       col.Nullable,
+			Default:        col.Default,
+			DefaultIsEmpty: col.DefaultIsEmpty,
+			PK:             col.IsPrimaryKey,
+			AutoIncr:       col.IsAutoIncrement,
+			Comment:        col.Comment,
+			Base64:         col.SQLType.IsBlob(),
+		})
+	}
+	for _, index := range table.Indexes {
+		dt.Indexes = append(dt.Indexes, dumpIndex{
+			Name:      index.Name,
+			Type:      index.Type,
+			IsRegular: index.IsRegular,
+			Cols:      index.Cols,
+		})
+	}
+	return dt
+}
+
+func (dt *dumpTable) toTable(name string) *schemasvr.Table {
+	table := schemasvr.NewEmptyTable()
+	table.Name = name
+	table.Comment = dt.Comment
+	for _, c := range dt.Columns {
+		col := schemasvr.NewColumn(c.Name, "", schemasvr.SQLType{Name: c.Type}, c.Length, c.Length2, c.Nullable)
+		col.Default = c.Default
+		col.DefaultIsEmpty = c.DefaultIsEmpty
+		col.IsPrimaryKey = c.PK
+		col.IsAutoIncrement = c.AutoIncr
+		col.Comment = c.Comment
+		table.AddColumn(col)
+	}
+	for _, idx := range dt.Indexes {
+		index := schemasvr.NewIndex(idx.Name, idx.Type)
+		index.IsRegular = idx.IsRegular
+		index.AddColumn(idx.Cols...)
+		table.AddIndex(index)
+	}
+	return table
+}
+
+var dumpTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// dumpValue converts a dumped value to a value for the column
+func dumpValue(col *schemasvr.Column, s *string, isBase64 bool) (interface{}, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(*s)
+	}
+	switch schemasvr.SQLType2Type(col.SQLType) {
+	case schemasvr.IntType, schemasvr.Int64Type:
+		if v, err := strconv.ParseInt(*s, 10, 64); err == nil {
+			return v, nil
+		}
+		if v, err := strconv.ParseBool(*s); err == nil {
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	case schemasvr.UintType, schemasvr.Uint64Type:
+		if v, err := strconv.ParseUint(*s, 10, 64); err == nil {
+			return v, nil
+		}
+	case schemasvr.Float32Type, schemasvr.Float64Type:
+		if v, err := strconv.ParseFloat(*s, 64); err == nil {
+			return v, nil
+		}
+	case schemasvr.BoolType:
+		return strconv.ParseBool(*s)
+	case schemasvr.TimeType:
+		switch strings.ToUpper(col.SQLType.Name) {
+		case schemasvr.Time, schemasvr.Year:
+			return *s, nil
+		}
+		for _, layout := range dumpTimeLayouts {
+			if t, err := time.Parse(layout, *s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return *s, nil
+}
+
+// dumpWriter writes the records of concurrent table exports
+type dumpWriter struct {
+	mutex sync.Mutex
+	w     *bufio.Writer
+}
+
+func (dw *dumpWriter) write(rec *dumpRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	dw.mutex.Lock()
+	defer dw.mutex.Unlock()
+	if _, err := dw.w.Write(data); err != nil {
+		return err
+	}
+	if err := dw.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	return dw.w.Flush()
+}
+
+func hashDumpRow(h hash.Hash, row []*string) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, _ = h.Write(data)
+	_, _ = h.Write([]byte{'\n'})
+	return nil
+}
+
+// DumpStream writes the tables to w as a stream of JSON lines: a header, the
+// schema of every table followed by its rows in batches ordered by primary
+// key and a checksum of the rows. The records of tables exported in
+// parallel are interleaved. The stream could be loaded into any dialect via
+// Restore.
+func (engine *Engine) DumpStream(ctx context.Context, w io.Writer, opts DumpOptions) error {
+	tables, err := engine.DBMetas()
+	if err != nil {
+		return err
+	}
+	if len(opts.Tables) > 0 {
+		var wanted = make(map[string]bool, len(opts.Tables))
+		for _, name := range opts.Tables {
+			wanted[name] = true
+		}
+		var filtered = make([]*schemasvr.Table, 0, len(opts.Tables))
+		for _, table := range tables {
+			if wanted[table.Name] {
+				filtered = append(filtered, table)
+			}
+		}
+		tables = filtered
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = 1
+	}
+	var positions = make(map[string]DumpPosition, len(opts.Resume))
+	for _, pos := range opts.Resume {
+		positions[pos.Table] = pos
+	}
+	for _, table := range tables {
+		if pos := positions[table.Name]; len(table.PrimaryKeys) == 0 && !pos.Done && pos.Rows > 0 {
+			return fmt.Errorf("dump table %s: %w", table.Name, ErrDumpResumeNoPK)
+		}
+	}
+
+	dw := &dumpWriter{w: bufio.NewWriter(w)}
+	now := time.Now()
+	if err := dw.write(&dumpRecord{
+		Type:    dumpRecordHeader,
+		Version: DumpFormatVersion,
+		DBType:  string(engine.dialect.URI().DBType),
+		Created: &now,
+	}); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		jobs     = make(chan *schemasvr.Table)
+	)
+	for i := 0; i < opts.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for table := range jobs {
+				if err := engine.dumpStreamTable(ctx, dw, table, positions[table.Name], &opts); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("dump table %s: %w", table.Name, err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	for _, table := range tables {
+		if positions[table.Name].Done {
+			continue
+		}
+		select {
+		case jobs <- table:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return dw.write(&dumpRecord{Type: dumpRecordEnd})
+}
+
+func (engine *Engine) dumpStreamTable(ctx context.Context, dw *dumpWriter, table *schemasvr.Table, pos DumpPosition, opts *DumpOptions) error {
+	pos.Table = table.Name
+	if err := dw.write(&dumpRecord{
+		Type:   dumpRecordTable,
+		Table:  table.Name,
+		Schema: newDumpTable(table),
+		LastPK: pos.LastPK,
+		Count:  pos.Rows,
+	}); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	var count int64
+	writeBatch := func(rows [][]*string, lastPK []string) error {
+		bh := sha256.New()
+		for _, row := range rows {
+			if err := hashDumpRow(h, row); err != nil {
+				return err
+			}
+			if err := hashDumpRow(bh, row); err != nil {
+				return err
+			}
+		}
+		if err := dw.write(&dumpRecord{
+			Type:     dumpRecordRows,
+			Table:    table.Name,
+			Rows:     rows,
+			LastPK:   lastPK,
+			Checksum: hex.EncodeToString(bh.Sum(nil)),
+		}); err != nil {
+			return err
+		}
+		count += int64(len(rows))
+		pos.Rows += int64(len(rows))
+		pos.LastPK = lastPK
+		if opts.OnProgress != nil {
+			opts.OnProgress(pos)
+		}
+		return nil
+	}
+
+	var err error
+	if len(table.PrimaryKeys) > 0 {
+		err = engine.dumpStreamByPK(ctx, table, pos.LastPK, opts.BatchSize, writeBatch)
+	} else {
+		err = engine.dumpStreamAll(ctx, table, opts.BatchSize, writeBatch)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := dw.write(&dumpRecord{
+		Type:     dumpRecordTableEnd,
+		Table:    table.Name,
+		Count:    count,
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+	}); err != nil {
+		return err
+	}
+	pos.Done = true
+	if opts.OnProgress != nil {
+		opts.OnProgress(pos)
+	}
+	return nil
+}
+
+// dumpQuery returns a session selecting all the columns of the table
+func (engine *Engine) dumpQuery(ctx context.Context, table *schemasvr.Table) *Session {
+	session := engine.NewSession()
+	session.Context(ctx)
+	var cols = make([]string, 0, len(table.ColumnsSeq()))
+	for _, col := range table.ColumnsSeq() {
+		cols = append(cols, engine.Quote(col))
+	}
+	return session.Table(table.Name).Select(strings.Join(cols, ", "))
+}
+
+// scanDumpRows scans the rows as strings, binary values are base64 encoded
+func (engine *Engine) scanDumpRows(rows *core.Rows, table *schemasvr.Table, fn func(row []*string) error) error {
+	fields, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	cols := table.Columns()
+	for rows.Next() {
+		results, err := engine.scanStringInterface(rows, fields, types)
+		if err != nil {
+			return err
+		}
+		var row = make([]*string, len(results))
+		for i, res := range results {
+			s := res.(*sql.NullString)
+			if !s.Valid {
+				continue
+			}
+			v := s.String
+			if cols[i].SQLType.IsBlob() {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			row[i] = &v
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// keysetCond returns the condition of the rows after the primary key
+func (engine *Engine) keysetCond(pkCols []*schemasvr.Column, lastPK []string) (string, []interface{}, error) {
+	if len(lastPK) != len(pkCols) {
+		return "", nil, fmt.Errorf("resume position has %d primary key values but %d are needed", len(lastPK), len(pkCols))
+	}
+	var values = make([]interface{}, len(pkCols))
+	for i, col := range pkCols {
+		v, err := dumpValue(col, &lastPK[i], false)
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = v
+	}
+	var ors []string
+	var args []interface{}
+	for i := range pkCols {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, engine.Quote(pkCols[j].Name)+" = ?")
+			args = append(args, values[j])
+		}
+		ands = append(ands, engine.Quote(pkCols[i].Name)+" > ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return strings.Join(ors, " OR "), args, nil
+}
+
+func (engine *Engine) dumpStreamByPK(ctx context.Context, table *schemasvr.Table, lastPK []string, batchSize int, writeBatch func([][]*string, []string) error) error {
+	pkCols := table.PKColumns()
+	var pkIdxes = make([]int, len(pkCols))
+	var orders = make([]string, len(pkCols))
+	for i, col := range pkCols {
+		orders[i] = engine.Quote(col.Name)
+		for j, c := range table.Columns() {
+			if c == col {
+				pkIdxes[i] = j
+			}
+		}
+	}
+	for {
+		session := engine.dumpQuery(ctx, table)
+		if len(lastPK) > 0 {
+			cond, args, err := engine.keysetCond(pkCols, lastPK)
+			if err != nil {
+				session.Close()
+				return err
+			}
+			session.Where(cond, args...)
+		}
+		session.OrderBy(strings.Join(orders, ", ")).Limit(batchSize)
+
+		batch, err := engine.queryDumpRows(session, table)
+		session.Close()
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		lastPK = make([]string, len(pkIdxes))
+		for i, idx := range pkIdxes {
+			if last[idx] != nil {
+				lastPK[i] = *last[idx]
+			}
+		}
+		if err := writeBatch(batch, lastPK); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (engine *Engine) queryDumpRows(session *Session, table *schemasvr.Table) ([][]*string, error) {
+	sqlStr, args, err := session.statement.GenQuerySQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch [][]*string
+	err = engine.scanDumpRows(rows, table, func(row []*string) error {
+		batch = append(batch, row)
+		return nil
+	})
+	return batch, err
+}
+
+// dumpStreamAll dumps a table without primary key in one query
+func (engine *Engine) dumpStreamAll(ctx context.Context, table *schemasvr.Table, batchSize int, writeBatch func([][]*string, []string) error) error {
+	session := engine.dumpQuery(ctx, table)
+	defer session.Close()
+	sqlStr, args, err := session.statement.GenQuerySQL()
+	if err != nil {
+		return err
+	}
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var batch [][]*string
+	err = engine.scanDumpRows(rows, table, func(row []*string) error {
+		batch = append(batch, row)
+		if len(batch) >= batchSize {
+			if err := writeBatch(batch, nil); err != nil {
+				return err
+			}
+			batch = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return writeBatch(batch, nil)
+	}
+	return nil
+}
+
+// restoreTable represents the restore state of a table
+type restoreTable struct {
+	table    *schemasvr.Table
+	base64   []bool
+	hash     hash.Hash
+	pos      DumpPosition
+	skip     int64
+	skipAll  bool
+	finished bool
+}
+
+// Restore loads a stream written by DumpStream into the database of the
+// engine, the dialect could be different from the dumped one. Missing tables
+// are created, the rows are inserted in multi-row INSERT statements one
+// transaction per batch. The checksum of every batch is verified before it is
+// committed, the checksum of every table once its rows are restored.
+func (engine *Engine) Restore(ctx context.Context, r io.Reader, opts RestoreOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	var positions = make(map[string]DumpPosition, len(opts.Resume))
+	for _, pos := range opts.Resume {
+		positions[pos.Table] = pos
+	}
+	var tables = make(map[string]*restoreTable)
+	var hasHeader, hasEnd bool
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec dumpRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if rec.Type != dumpRecordHeader && !hasHeader {
+			return ErrDumpFormat
+		}
+		switch rec.Type {
+		case dumpRecordHeader:
+			if rec.Version > DumpFormatVersion {
+				return fmt.Errorf("unsupported dump format version %d", rec.Version)
+			}
+			hasHeader = true
+		case dumpRecordTable:
+			if rec.Schema == nil {
+				return ErrDumpFormat
+			}
+			rt := &restoreTable{
+				table: rec.Schema.toTable(rec.Table),
+				hash:  sha256.New(),
+				pos:   DumpPosition{Table: rec.Table},
+			}
+			for _, col := range rec.Schema.Columns {
+				rt.base64 = append(rt.base64, col.Base64)
+			}
+			if pos, ok := positions[rec.Table]; ok {
+				rt.skipAll = pos.Done
+				rt.skip = pos.Rows
+				rt.pos.Rows = pos.Rows
+			}
+			tables[rec.Table] = rt
+			if !rt.skipAll && !opts.SkipCreate {
+				if err := engine.restoreCreateTable(ctx, rt.table); err != nil {
+					return fmt.Errorf("restore table %s: %w", rec.Table, err)
+				}
+			}
+		case dumpRecordRows:
+			rt, ok := tables[rec.Table]
+			if !ok || rt.finished {
+				return ErrDumpFormat
+			}
+			if rec.Checksum != "" {
+				bh := sha256.New()
+				for _, row := range rec.Rows {
+					if err := hashDumpRow(bh, row); err != nil {
+						return err
+					}
+				}
+				if hex.EncodeToString(bh.Sum(nil)) != rec.Checksum {
+					return fmt.Errorf("restore table %s: %w", rec.Table, ErrDumpChecksum)
+				}
+			}
+			var rows = make([][]*string, 0, len(rec.Rows))
+			for _, row := range rec.Rows {
+				if err := hashDumpRow(rt.hash, row); err != nil {
+					return err
+				}
+				if rt.skipAll {
+					continue
+				}
+				if rt.skip > 0 {
+					rt.skip--
+					continue
+				}
+				rows = append(rows, row)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if err := engine.restoreRows(ctx, rt, rows, opts.BatchSize); err != nil {
+				return fmt.Errorf("restore table %s: %w", rec.Table, err)
+			}
+			rt.pos.Rows += int64(len(rows))
+			rt.pos.LastPK = rec.LastPK
+			if opts.OnProgress != nil {
+				opts.OnProgress(rt.pos)
+			}
+		case dumpRecordTableEnd:
+			rt, ok := tables[rec.Table]
+			if !ok || rt.finished {
+				return ErrDumpFormat
+			}
+			rt.finished = true
+			if hex.EncodeToString(rt.hash.Sum(nil)) != rec.Checksum {
+				return fmt.Errorf("restore table %s: %w", rec.Table, ErrDumpChecksum)
+			}
+			if rt.skipAll {
+				continue
+			}
+			if err := engine.restoreSequence(ctx, rt.table); err != nil {
+				return fmt.Errorf("restore table %s: %w", rec.Table, err)
+			}
+			rt.pos.Done = true
+			if opts.OnProgress != nil {
+				opts.OnProgress(rt.pos)
+			}
+		case dumpRecordEnd:
+			hasEnd = true
+		default:
+			return ErrDumpFormat
+		}
+	}
+	if !hasEnd {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (engine *Engine) restoreCreateTable(ctx context.Context, table *schemasvr.Table) error {
+	session := engine.NewSession()
+	defer session.Close()
+	session.Context(ctx)
+	exist, err := session.IsTableExist(table.Name)
+	if err != nil || exist {
+		return err
+	}
+	tableName := engine.tbNameWithSchema(table.Name)
+	if table.AutoIncrement != "" && engine.dialect.Features().AutoincrMode == dialectsvr.SequenceAutoincrMode {
+		sqlStr, err := engine.dialect.CreateSequenceSQL(ctx, engine.db, utils.SeqName(tableName))
+		if err != nil {
+			return err
+		}
+		if _, err := session.exec(sqlStr); err != nil {
+			return err
+		}
+	}
+	sqlStr, _, err := engine.dialect.CreateTableSQL(ctx, engine.db, table, tableName)
+	if err != nil {
+		return err
+	}
+	if _, err := session.exec(sqlStr); err != nil {
+		return err
+	}
+	for _, index := range table.Indexes {
+		if _, err := session.exec(engine.dialect.CreateIndexSQL(table.Name, index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (engine *Engine) restoreRows(ctx context.Context, rt *restoreTable, rows [][]*string, batchSize int) error {
+	cols := rt.table.Columns()
+	// keep the parameters of a statement under the limits of all the databases
+	perStmt := 999 / len(cols)
+	if perStmt > batchSize {
+		perStmt = batchSize
+	}
+	if perStmt < 1 {
+		perStmt = 1
+	}
+	switch engine.dialect.URI().DBType {
+	case schemasvr.ORACLE, schemasvr.DAMENG:
+		perStmt = 1
+	}
+
+	quotedTable := engine.Quote(engine.tbNameWithSchema(rt.table.Name))
+	head := "INSERT INTO " + quotedTable + " (" + engine.dialect.Quoter().Join(rt.table.ColumnsSeq(), ", ") + ") VALUES "
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")"
+
+	session := engine.NewSession()
+	defer session.Close()
+	session.Context(ctx)
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	identityInsert := engine.dialect.URI().DBType == schemasvr.MSSQL && rt.table.AutoIncrement != ""
+	if identityInsert {
+		if _, err := session.exec("SET IDENTITY_INSERT " + quotedTable + " ON"); err != nil {
+			_ = session.Rollback()
+			return err
+		}
+	}
+	for start := 0; start < len(rows); start += perStmt {
+		end := start + perStmt
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var values = make([]string, 0, end-start)
+		var args = make([]interface{}, 0, (end-start)*len(cols))
+		for _, row := range rows[start:end] {
+			if len(row) != len(cols) {
+				_ = session.Rollback()
+				return ErrDumpFormat
+			}
+			for i, col := range cols {
+				v, err := dumpValue(col, row[i], rt.base64[i])
+				if err != nil {
+					_ = session.Rollback()
+					return fmt.Errorf("column %s: %w", col.Name, err)
+				}
+				args = append(args, v)
+			}
+			values = append(values, placeholders)
+		}
+		if _, err := session.Exec(append([]interface{}{head + strings.Join(values, ", ")}, args...)...); err != nil {
+			_ = session.Rollback()
+			return err
+		}
+	}
+	if identityInsert {
+		if _, err := session.exec("SET IDENTITY_INSERT " + quotedTable + " OFF"); err != nil {
+			_ = session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
+
+// restoreSequence moves the sequence of the autoincrement column after the restored rows
+func (engine *Engine) restoreSequence(ctx context.Context, table *schemasvr.Table) error {
+	col := table.AutoIncrColumn()
+	// cockroach generates the ids with unique_rowid() and has no sequence to move
+	dbType := engine.dialect.URI().DBType
+	if col == nil || (dbType != schemasvr.POSTGRES && dbType != schemasvr.YUGABYTE) {
+		return nil
+	}
+	tableName := engine.tbNameWithSchema(table.Name)
+	session := engine.NewSession()
+	defer session.Close()
+	// the names come from the dump, they are bound rather than put in literals,
+	// the table name is parsed as an identifier so it is quoted to keep its case
+	_, err := session.Context(ctx).QueryString(fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+		engine.Quote(col.Name), engine.Quote(tableName)), engine.Quote(tableName), col.Name)
+	return err
+}
@@ -22,10 +22,12 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm/cache"
 	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
 	"github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/log"
 	"github.com/bhojpur/dbm/pkg/orm/name"
@@ -34,13 +36,17 @@ import (
 // EngineGroup defines an engine group
 type EngineGroup struct {
 	*Engine
-	slaves []*Engine
-	policy GroupPolicy
+	slaves       []*Engine
+	policy       GroupPolicy
+	health       *groupHealth
+	stickyWindow time.Duration
 }
 
 // NewEngineGroup creates a new engine group
 func NewEngineGroup(args1 interface{}, args2 interface{}, policies ...GroupPolicy) (*EngineGroup, error) {
-	var eg EngineGroup
+	var eg = EngineGroup{
+		health: newGroupHealth(),
+	}
 	if len(policies) > 0 {
 		eg.policy = policies[0]
 	} else {
@@ -78,6 +84,7 @@ func NewEngineGroup(args1 interface{}, args2 interface{}, policies ...GroupPolic
 
 // Close the engine
 func (eg *EngineGroup) Close() error {
+	eg.StopHealthCheck()
 	err := eg.Engine.Close()
 	if err != nil {
 		return err
@@ -105,6 +112,18 @@ func (eg *EngineGroup) NewSession() *Session {
 	return sess
 }
 
+// TransactionWithOptions runs f in a transaction of a group session, the read
+// only transactions run on a slave
+func (eg *EngineGroup) TransactionWithOptions(ctx context.Context, opts *sql.TxOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	return runTransaction(eg.NewSession(), ctx, opts, f)
+}
+
+// TransactionWithRetry runs f in transactions of group sessions like
+// Engine.TransactionWithRetry, the read only transactions run on a slave
+func (eg *EngineGroup) TransactionWithRetry(ctx context.Context, opts *TxRetryOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	return eg.Engine.retryTransaction(ctx, opts, eg.NewSession, f)
+}
+
 // Master returns the master engine
 func (eg *EngineGroup) Master() *Engine {
 	return eg.Engine
@@ -147,6 +166,14 @@ func (eg *EngineGroup) SetDefaultCacher(cacher cache.Cacher) {
 	}
 }
 
+// SetKeyProvider sets the provider of the keys encrypting the columns tagged encrypted
+func (eg *EngineGroup) SetKeyProvider(provider convert.KeyProvider) {
+	eg.Engine.SetKeyProvider(provider)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetKeyProvider(provider)
+	}
+}
+
 // SetLogger set the new logger
 func (eg *EngineGroup) SetLogger(logger interface{}) {
 	eg.Engine.SetLogger(logger)
@@ -163,6 +190,23 @@ func (eg *EngineGroup) AddHook(hook ctxsvr.Hook) {
 	}
 }
 
+// SetSlowQueryThreshold sets the slow query threshold of all the engines
+func (eg *EngineGroup) SetSlowQueryThreshold(threshold time.Duration) {
+	eg.Engine.SetSlowQueryThreshold(threshold)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetSlowQueryThreshold(threshold)
+	}
+}
+
+// EnableQueryStats starts or stops collecting the statistics of all the
+// engines in one registry
+func (eg *EngineGroup) EnableQueryStats(enable bool) {
+	eg.Engine.EnableQueryStats(enable)
+	for i := 0; i < len(eg.slaves); i++ {
+		eg.slaves[i].SetQueryStats(eg.Engine.QueryStats())
+	}
+}
+
 // SetLogLevel sets the logger level
 func (eg *EngineGroup) SetLogLevel(level log.LogLevel) {
 	eg.Engine.SetLogLevel(level)
@@ -233,15 +277,29 @@ func (eg *EngineGroup) ShowSQL(show ...bool) {
 	}
 }
 
-// Slave returns one of the physical databases which is a slave according the policy
+// Slave returns one of the physical databases which is a slave according the policy,
+// the slaves ejected by the health checks are skipped
 func (eg *EngineGroup) Slave() *Engine {
 	switch len(eg.slaves) {
 	case 0:
 		return eg.Engine
 	case 1:
-		return eg.slaves[0]
+		if eg.isHealthy(eg.slaves[0]) {
+			return eg.slaves[0]
+		}
+		return eg.Engine
 	}
-	return eg.policy.Slave(eg)
+	for i := 0; i < len(eg.slaves); i++ {
+		if slave := eg.policy.Slave(eg); eg.isHealthy(slave) {
+			return slave
+		}
+	}
+	for _, slave := range eg.slaves {
+		if eg.isHealthy(slave) {
+			return slave
+		}
+	}
+	return eg.Engine
 }
 
 // Slaves returns all the slaves
@@ -0,0 +1,342 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// LagProbe returns the replication lag of a slave
+type LagProbe func(ctx context.Context, slave *Engine) (time.Duration, error)
+
+// PostgresLagProbe measures the lag with pg_last_xact_replay_timestamp(), a
+// slave which has replayed all the received WAL is considered up to date.
+func PostgresLagProbe(ctx context.Context, slave *Engine) (time.Duration, error) {
+	var seconds float64
+	has, err := slave.Context(ctx).SQL(`SELECT CASE
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`).Get(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !has {
+		return 0, errors.New("no replication lag returned")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MySQLLagProbe measures the lag with Seconds_Behind_Master of SHOW SLAVE
+// STATUS, a NULL value means the replication is broken.
+func MySQLLagProbe(ctx context.Context, slave *Engine) (time.Duration, error) {
+	results, err := slave.Context(ctx).QueryString("SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, errors.New("not a slave")
+	}
+	v, ok := results[0]["Seconds_Behind_Master"]
+	if !ok || v == "" {
+		return 0, errors.New("replication is not running")
+	}
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// DefaultLagProbe returns the lag probe of the database type, nil if the
+// database is not supported
+func DefaultLagProbe(dbType schemasvr.DBType) LagProbe {
+	switch dbType {
+	case schemasvr.POSTGRES:
+		return PostgresLagProbe
+	case schemasvr.MYSQL:
+		return MySQLLagProbe
+	}
+	return nil
+}
+
+// HealthCheckConfig configures the health checks of the slaves of an
+// EngineGroup
+type HealthCheckConfig struct {
+	// Interval between two checks, default is 5 seconds
+	Interval time.Duration
+	// Timeout of one check of one slave, default is Interval
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed checks before a
+	// slave is ejected, default is 1
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful checks before
+	// an ejected slave is admitted again, default is 1
+	SuccessThreshold int
+	// MaxLag ejects the slaves lagging behind the master more than it, 0
+	// disables the lag probe
+	MaxLag time.Duration
+	// LagProbe measures the lag of a slave, default is DefaultLagProbe of the
+	// dialect of the slave
+	LagProbe LagProbe
+}
+
+// SlaveStatus is the health status of a slave
+type SlaveStatus struct {
+	Engine    *Engine
+	Healthy   bool
+	Lag       time.Duration
+	LastError error
+	LastCheck time.Time
+}
+
+type slaveHealth struct {
+	healthy   bool
+	failures  int
+	successes int
+	lag       time.Duration
+	lastErr   error
+	lastCheck time.Time
+}
+
+type groupHealth struct {
+	mutex  sync.RWMutex
+	config HealthCheckConfig
+	slaves map[*Engine]*slaveHealth
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (eg *EngineGroup) isHealthy(slave *Engine) bool {
+	eg.health.mutex.RLock()
+	defer eg.health.mutex.RUnlock()
+	h, ok := eg.health.slaves[slave]
+	return !ok || h.healthy
+}
+
+func (eg *EngineGroup) checkSlave(ctx context.Context, config HealthCheckConfig, slave *Engine) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	if err := slave.DB().PingContext(ctx); err != nil {
+		return 0, err
+	}
+	if config.MaxLag <= 0 {
+		return 0, nil
+	}
+	probe := config.LagProbe
+	if probe == nil {
+		probe = DefaultLagProbe(slave.Dialect().URI().DBType)
+	}
+	if probe == nil {
+		return 0, nil
+	}
+	lag, err := probe(ctx, slave)
+	if err != nil {
+		return 0, err
+	}
+	if lag > config.MaxLag {
+		return lag, fmt.Errorf("replication lag %v exceeds %v", lag, config.MaxLag)
+	}
+	return lag, nil
+}
+
+// CheckHealth checks all the slaves once, ejects the failing slaves and
+// admits the recovered ones again. It's called periodically after
+// StartHealthCheck, but could also be called directly.
+func (eg *EngineGroup) CheckHealth(ctx context.Context) {
+	eg.health.mutex.RLock()
+	config := eg.health.config
+	eg.health.mutex.RUnlock()
+
+	for _, slave := range eg.slaves {
+		lag, err := eg.checkSlave(ctx, config, slave)
+
+		eg.health.mutex.Lock()
+		h, ok := eg.health.slaves[slave]
+		if !ok {
+			h = &slaveHealth{healthy: true}
+			eg.health.slaves[slave] = h
+		}
+		h.lag, h.lastErr, h.lastCheck = lag, err, time.Now()
+		if err != nil {
+			h.successes = 0
+			h.failures++
+			if h.healthy && h.failures >= config.FailureThreshold {
+				h.healthy = false
+				eg.Engine.logger.Warnf("[EngineGroup] slave %s is ejected: %v", slave.DataSourceName(), err)
+			}
+		} else {
+			h.failures = 0
+			h.successes++
+			if !h.healthy && h.successes >= config.SuccessThreshold {
+				h.healthy = true
+				eg.Engine.logger.Infof("[EngineGroup] slave %s is admitted again", slave.DataSourceName())
+			}
+		}
+		eg.health.mutex.Unlock()
+	}
+}
+
+func newGroupHealth() *groupHealth {
+	return &groupHealth{
+		config: HealthCheckConfig{}.withDefaults(),
+		slaves: make(map[*Engine]*slaveHealth),
+	}
+}
+
+func (config HealthCheckConfig) withDefaults() HealthCheckConfig {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = config.Interval
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
+	}
+	return config
+}
+
+// SetHealthCheckConfig sets the config used by CheckHealth without starting
+// the background checks
+func (eg *EngineGroup) SetHealthCheckConfig(config HealthCheckConfig) {
+	eg.health.mutex.Lock()
+	eg.health.config = config.withDefaults()
+	eg.health.mutex.Unlock()
+}
+
+// StartHealthCheck checks the slaves in background until StopHealthCheck or
+// Close is called. Slave() never returns an ejected slave, the master will be
+// returned if all the slaves are ejected.
+func (eg *EngineGroup) StartHealthCheck(config HealthCheckConfig) {
+	eg.StopHealthCheck()
+	eg.SetHealthCheckConfig(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	eg.health.mutex.Lock()
+	eg.health.cancel, eg.health.done = cancel, done
+	interval := eg.health.config.Interval
+	eg.health.mutex.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			eg.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopHealthCheck stops the background health checks, the slaves keep their
+// last status
+func (eg *EngineGroup) StopHealthCheck() {
+	eg.health.mutex.Lock()
+	cancel, done := eg.health.cancel, eg.health.done
+	eg.health.cancel, eg.health.done = nil, nil
+	eg.health.mutex.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// SlaveStatuses returns the health status of all the slaves
+func (eg *EngineGroup) SlaveStatuses() []SlaveStatus {
+	statuses := make([]SlaveStatus, 0, len(eg.slaves))
+	for _, slave := range eg.slaves {
+		status := SlaveStatus{Engine: slave, Healthy: true}
+		eg.health.mutex.RLock()
+		if h, ok := eg.health.slaves[slave]; ok {
+			status.Healthy = h.healthy
+			status.Lag = h.lag
+			status.LastError = h.lastErr
+			status.LastCheck = h.lastCheck
+		}
+		eg.health.mutex.RUnlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+type readYourWritesKey struct{}
+
+type readYourWrites struct {
+	mutex     sync.Mutex
+	lastWrite time.Time
+}
+
+// ReadYourWrites returns a context in which the reads of an EngineGroup are
+// sent to the master once something has been written to the master through
+// the context, so that the writes are always visible to the following reads.
+//
+//	ctx = orm.ReadYourWrites(ctx)
+//	_, err := eg.Context(ctx).Insert(&user)
+//	// will read from master
+//	_, err = eg.Context(ctx).Get(&user)
+func ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(readYourWritesKey{}).(*readYourWrites); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, readYourWritesKey{}, &readYourWrites{})
+}
+
+func markWritten(ctx context.Context) {
+	if ryw, ok := ctx.Value(readYourWritesKey{}).(*readYourWrites); ok {
+		ryw.mutex.Lock()
+		ryw.lastWrite = time.Now()
+		ryw.mutex.Unlock()
+	}
+}
+
+// SetStickyWindow limits how long the reads stick to the master after a
+// write in a ReadYourWrites context, 0 means for the lifetime of the context
+func (eg *EngineGroup) SetStickyWindow(window time.Duration) {
+	eg.stickyWindow = window
+}
+
+func (eg *EngineGroup) mustReadMaster(ctx context.Context) bool {
+	ryw, ok := ctx.Value(readYourWritesKey{}).(*readYourWrites)
+	if !ok {
+		return false
+	}
+	ryw.mutex.Lock()
+	defer ryw.mutex.Unlock()
+	if ryw.lastWrite.IsZero() {
+		return false
+	}
+	return eg.stickyWindow <= 0 || time.Since(ryw.lastWrite) < eg.stickyWindow
+}
@@ -0,0 +1,953 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+var (
+	// ErrNoShardKey represents the shard key of a bean could not be determined
+	ErrNoShardKey = errors.New("shard key is required to route to a shard")
+	// ErrCrossShardTransaction represents a transaction touched more than one shard
+	ErrCrossShardTransaction = errors.New("transaction spans more than one shard")
+)
+
+// ShardFunc maps a shard key value to a shard index in [0, shards)
+type ShardFunc func(key interface{}, shards int) (int, error)
+
+// HashShardFunc returns a ShardFunc which uses modulo for integer keys and
+// FNV-1a hash modulo for any other keys
+func HashShardFunc() ShardFunc {
+	return func(key interface{}, shards int) (int, error) {
+		v := reflect.Indirect(reflect.ValueOf(key))
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n := v.Int() % int64(shards)
+			if n < 0 {
+				n = -n
+			}
+			return int(n), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(v.Uint() % uint64(shards)), nil
+		case reflect.Invalid:
+			return 0, ErrNoShardKey
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(fmt.Sprint(v.Interface())))
+		return int(h.Sum32() % uint32(shards)), nil
+	}
+}
+
+// ShardedEngine routes beans to one of several engines according the value
+// of the field tagged with `shardkey`
+type ShardedEngine struct {
+	shards            []*Engine
+	shardFunc         ShardFunc
+	allowCrossShardTx bool
+}
+
+// NewShardedEngine creates a sharded engine, if shardFunc is nil HashShardFunc will be used
+func NewShardedEngine(shards []*Engine, shardFunc ShardFunc) (*ShardedEngine, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("at least one shard is required")
+	}
+	if shardFunc == nil {
+		shardFunc = HashShardFunc()
+	}
+	return &ShardedEngine{
+		shards:    shards,
+		shardFunc: shardFunc,
+	}, nil
+}
+
+// Shards returns all the shard engines
+func (se *ShardedEngine) Shards() []*Engine {
+	return se.shards
+}
+
+// Shard returns the shard engine with the index
+func (se *ShardedEngine) Shard(i int) *Engine {
+	return se.shards[i]
+}
+
+// SetShardFunc sets the function which maps shard keys to shards
+func (se *ShardedEngine) SetShardFunc(shardFunc ShardFunc) {
+	se.shardFunc = shardFunc
+}
+
+// AllowCrossShardTransaction allows or refuses transactions touching more
+// than one shard. Cross shard transactions are committed one shard after
+// another and so they are not atomic.
+func (se *ShardedEngine) AllowCrossShardTransaction(allow bool) {
+	se.allowCrossShardTx = allow
+}
+
+// Close closes all the shard engines
+func (se *ShardedEngine) Close() error {
+	for _, engine := range se.shards {
+		if err := engine.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping tests if all the shards are alive
+func (se *ShardedEngine) Ping() error {
+	for _, engine := range se.shards {
+		if err := engine.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShowSQL show SQL statement or not on logger if log level is great than INFO
+func (se *ShardedEngine) ShowSQL(show ...bool) {
+	for _, engine := range se.shards {
+		engine.ShowSQL(show...)
+	}
+}
+
+// Sync2 synchronize structs to all the shards
+func (se *ShardedEngine) Sync2(beans ...interface{}) error {
+	for _, engine := range se.shards {
+		if err := engine.Sync2(beans...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardKey returns the value of the shard key field of the bean, the second
+// return value will be false if the field is missing or zero
+func (se *ShardedEngine) ShardKey(bean interface{}) (interface{}, bool, error) {
+	v := reflect.Indirect(reflect.ValueOf(bean))
+	if v.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+	table, err := se.shards[0].TableInfo(bean)
+	if err != nil {
+		return nil, false, err
+	}
+	col := table.ShardKeyColumn()
+	if col == nil {
+		return nil, false, fmt.Errorf("table %s has no shardkey column", table.Name)
+	}
+	fieldValue := v.FieldByIndex(col.FieldIndex)
+	if fieldValue.IsZero() {
+		return nil, false, nil
+	}
+	return fieldValue.Interface(), true, nil
+}
+
+// ShardIndex returns the index of the shard which the key belongs to
+func (se *ShardedEngine) ShardIndex(key interface{}) (int, error) {
+	idx, err := se.shardFunc(key, len(se.shards))
+	if err != nil {
+		return 0, err
+	}
+	if idx < 0 || idx >= len(se.shards) {
+		return 0, fmt.Errorf("shard index %d out of range [0, %d)", idx, len(se.shards))
+	}
+	return idx, nil
+}
+
+// ShardForKey returns the shard engine which the key belongs to
+func (se *ShardedEngine) ShardForKey(key interface{}) (*Engine, error) {
+	idx, err := se.ShardIndex(key)
+	if err != nil {
+		return nil, err
+	}
+	return se.shards[idx], nil
+}
+
+func (se *ShardedEngine) beanShardIndex(beans ...interface{}) (int, error) {
+	for _, bean := range beans {
+		key, ok, err := se.ShardKey(bean)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return se.ShardIndex(key)
+		}
+	}
+	return 0, ErrNoShardKey
+}
+
+// ShardFor returns the shard engine which the bean belongs to
+func (se *ShardedEngine) ShardFor(bean interface{}) (*Engine, error) {
+	idx, err := se.beanShardIndex(bean)
+	if err != nil {
+		return nil, err
+	}
+	return se.shards[idx], nil
+}
+
+// NewSession returns a sharded session
+func (se *ShardedEngine) NewSession() *ShardedSession {
+	return &ShardedSession{se: se}
+}
+
+// Context returns a sharded session with the context
+func (se *ShardedEngine) Context(ctx context.Context) *ShardedSession {
+	return se.NewSession().Context(ctx)
+}
+
+// ShardKeyValue returns a sharded session routed to the shard of the key
+func (se *ShardedEngine) ShardKeyValue(key interface{}) *ShardedSession {
+	return se.NewSession().ShardKeyValue(key)
+}
+
+// Where returns a sharded session with the condition
+func (se *ShardedEngine) Where(query interface{}, args ...interface{}) *ShardedSession {
+	return se.NewSession().Where(query, args...)
+}
+
+// In returns a sharded session with the in condition
+func (se *ShardedEngine) In(column string, args ...interface{}) *ShardedSession {
+	return se.NewSession().In(column, args...)
+}
+
+// Cols returns a sharded session which only operates on the columns
+func (se *ShardedEngine) Cols(columns ...string) *ShardedSession {
+	return se.NewSession().Cols(columns...)
+}
+
+// Asc returns a sharded session ordered by the columns ascending
+func (se *ShardedEngine) Asc(colNames ...string) *ShardedSession {
+	return se.NewSession().Asc(colNames...)
+}
+
+// Desc returns a sharded session ordered by the columns descending
+func (se *ShardedEngine) Desc(colNames ...string) *ShardedSession {
+	return se.NewSession().Desc(colNames...)
+}
+
+// OrderBy returns a sharded session ordered by the order string
+func (se *ShardedEngine) OrderBy(order string) *ShardedSession {
+	return se.NewSession().OrderBy(order)
+}
+
+// Limit returns a sharded session with the limit applied after merging
+func (se *ShardedEngine) Limit(limit int, start ...int) *ShardedSession {
+	return se.NewSession().Limit(limit, start...)
+}
+
+// Unscoped returns a sharded session which ignores the deleted column
+func (se *ShardedEngine) Unscoped() *ShardedSession {
+	return se.NewSession().Unscoped()
+}
+
+// Insert inserts the beans, every bean is routed to its own shard. Slices
+// of beans will be split by shard.
+func (se *ShardedEngine) Insert(beans ...interface{}) (int64, error) {
+	return se.NewSession().Insert(beans...)
+}
+
+// Get retrieves one record from the shard of the bean
+func (se *ShardedEngine) Get(bean interface{}) (bool, error) {
+	return se.NewSession().Get(bean)
+}
+
+// Exist returns true if the record exists on the shard of the bean
+func (se *ShardedEngine) Exist(bean interface{}) (bool, error) {
+	return se.NewSession().Exist(bean)
+}
+
+// Update updates records on the shard of the bean or the condition bean
+func (se *ShardedEngine) Update(bean interface{}, condiBeans ...interface{}) (int64, error) {
+	return se.NewSession().Update(bean, condiBeans...)
+}
+
+// Delete deletes records on the shard of the bean
+func (se *ShardedEngine) Delete(bean interface{}) (int64, error) {
+	return se.NewSession().Delete(bean)
+}
+
+// Find retrieves records from all the shards and merges them
+func (se *ShardedEngine) Find(rowsSlicePtr interface{}, condiBean ...interface{}) error {
+	return se.NewSession().Find(rowsSlicePtr, condiBean...)
+}
+
+// Count counts the records on all the shards
+func (se *ShardedEngine) Count(bean ...interface{}) (int64, error) {
+	return se.NewSession().Count(bean...)
+}
+
+// Sum sums the column on all the shards
+func (se *ShardedEngine) Sum(bean interface{}, colName string) (float64, error) {
+	return se.NewSession().Sum(bean, colName)
+}
+
+// SumInt sums the column on all the shards
+func (se *ShardedEngine) SumInt(bean interface{}, colName string) (int64, error) {
+	return se.NewSession().SumInt(bean, colName)
+}
+
+// Sums sums the columns on all the shards
+func (se *ShardedEngine) Sums(bean interface{}, colNames ...string) ([]float64, error) {
+	return se.NewSession().Sums(bean, colNames...)
+}
+
+// Transaction executes f in a transaction. The transaction is opened lazily
+// on the shards touched by the ShardedTx, touching a second shard returns
+// ErrCrossShardTransaction unless AllowCrossShardTransaction is enabled.
+func (se *ShardedEngine) Transaction(f func(*ShardedTx) (interface{}, error)) (interface{}, error) {
+	tx := &ShardedTx{
+		se:       se,
+		sessions: make(map[int]*Session),
+	}
+	defer tx.close()
+	result, err := f(tx)
+	if err != nil {
+		tx.rollback()
+		return result, err
+	}
+	if err := tx.commit(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+type shardOrder struct {
+	column string
+	desc   bool
+}
+
+// ShardedSession represents a query on a sharded engine. The conditions are
+// replayed on every shard the query is routed to.
+type ShardedSession struct {
+	se     *ShardedEngine
+	ctx    context.Context
+	ops    []func(*Session) *Session
+	orders []shardOrder
+	limit  int
+	start  int
+	key    interface{}
+	hasKey bool
+}
+
+func (ss *ShardedSession) apply(f func(*Session) *Session) *ShardedSession {
+	ss.ops = append(ss.ops, f)
+	return ss
+}
+
+// Context sets the context of the queries
+func (ss *ShardedSession) Context(ctx context.Context) *ShardedSession {
+	ss.ctx = ctx
+	return ss
+}
+
+// ShardKeyValue routes the session to the shard of the key
+func (ss *ShardedSession) ShardKeyValue(key interface{}) *ShardedSession {
+	ss.key = key
+	ss.hasKey = true
+	return ss
+}
+
+// Where provides custom query condition.
+func (ss *ShardedSession) Where(query interface{}, args ...interface{}) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.Where(query, args...)
+	})
+}
+
+// And provides custom query condition.
+func (ss *ShardedSession) And(query interface{}, args ...interface{}) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.And(query, args...)
+	})
+}
+
+// Or provides custom query condition.
+func (ss *ShardedSession) Or(query interface{}, args ...interface{}) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.Or(query, args...)
+	})
+}
+
+// In provides a query string like "id in (1, 2, 3)"
+func (ss *ShardedSession) In(column string, args ...interface{}) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.In(column, args...)
+	})
+}
+
+// NotIn provides a query string like "id not in (1, 2, 3)"
+func (ss *ShardedSession) NotIn(column string, args ...interface{}) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.NotIn(column, args...)
+	})
+}
+
+// Cols provides some columns to special
+func (ss *ShardedSession) Cols(columns ...string) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.Cols(columns...)
+	})
+}
+
+// Omit only not use the parameters as select or update columns
+func (ss *ShardedSession) Omit(columns ...string) *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.Omit(columns...)
+	})
+}
+
+// Unscoped always disable struct tag "deleted"
+func (ss *ShardedSession) Unscoped() *ShardedSession {
+	return ss.apply(func(session *Session) *Session {
+		return session.Unscoped()
+	})
+}
+
+// Asc provide asc order by query condition, the merged result is sorted too
+func (ss *ShardedSession) Asc(colNames ...string) *ShardedSession {
+	for _, col := range colNames {
+		ss.orders = append(ss.orders, shardOrder{column: col})
+	}
+	return ss.apply(func(session *Session) *Session {
+		return session.Asc(colNames...)
+	})
+}
+
+// Desc provide desc order by query condition, the merged result is sorted too
+func (ss *ShardedSession) Desc(colNames ...string) *ShardedSession {
+	for _, col := range colNames {
+		ss.orders = append(ss.orders, shardOrder{column: col, desc: true})
+	}
+	return ss.apply(func(session *Session) *Session {
+		return session.Desc(colNames...)
+	})
+}
+
+// OrderBy provide order by query condition, the order should be a list of
+// columns with an optional ASC or DESC so that the merged result can be sorted
+func (ss *ShardedSession) OrderBy(order string) *ShardedSession {
+	for _, part := range strings.Split(order, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		ss.orders = append(ss.orders, shardOrder{
+			column: strings.Trim(fields[0], "`\"[]"),
+			desc:   len(fields) > 1 && strings.EqualFold(fields[1], "DESC"),
+		})
+	}
+	return ss.apply(func(session *Session) *Session {
+		return session.OrderBy(order)
+	})
+}
+
+// Limit provide limit and offset query condition, they are applied after the
+// results of all the shards have been merged
+func (ss *ShardedSession) Limit(limit int, start ...int) *ShardedSession {
+	ss.limit = limit
+	if len(start) > 0 {
+		ss.start = start[0]
+	}
+	return ss
+}
+
+func (ss *ShardedSession) newSession(engine *Engine) *Session {
+	session := engine.NewSession()
+	if ss.ctx != nil {
+		session.Context(ss.ctx)
+	}
+	for _, op := range ss.ops {
+		op(session)
+	}
+	return session
+}
+
+// routeIndex returns the shard index of the explicit key or the beans
+func (ss *ShardedSession) routeIndex(beans ...interface{}) (int, error) {
+	if ss.hasKey {
+		return ss.se.ShardIndex(ss.key)
+	}
+	return ss.se.beanShardIndex(beans...)
+}
+
+// targets returns the shards a read should fan out to
+func (ss *ShardedSession) targets() ([]*Engine, error) {
+	if !ss.hasKey {
+		return ss.se.shards, nil
+	}
+	engine, err := ss.se.ShardForKey(ss.key)
+	if err != nil {
+		return nil, err
+	}
+	return []*Engine{engine}, nil
+}
+
+func (ss *ShardedSession) fanOut(f func(i int, session *Session) error) error {
+	engines, err := ss.targets()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(engines))
+	for i, engine := range engines {
+		wg.Add(1)
+		go func(i int, engine *Engine) {
+			defer wg.Done()
+			session := ss.newSession(engine)
+			defer session.Close()
+			errs[i] = f(i, session)
+		}(i, engine)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert inserts the beans, every bean is routed to its own shard
+func (ss *ShardedSession) Insert(beans ...interface{}) (int64, error) {
+	var groups = make(map[int][]interface{})
+	var order []int
+	add := func(bean interface{}) error {
+		idx, err := ss.routeIndex(bean)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[idx]; !ok {
+			order = append(order, idx)
+		}
+		groups[idx] = append(groups[idx], bean)
+		return nil
+	}
+	for _, bean := range beans {
+		v := reflect.Indirect(reflect.ValueOf(bean))
+		if v.Kind() != reflect.Slice {
+			if err := add(bean); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			if elem.Kind() != reflect.Ptr {
+				elem = elem.Addr()
+			}
+			if err := add(elem.Interface()); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var total int64
+	for _, idx := range order {
+		session := ss.newSession(ss.se.shards[idx])
+		cnt, err := session.Insert(groups[idx]...)
+		session.Close()
+		total += cnt
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Get retrieves one record from the shard of the bean
+func (ss *ShardedSession) Get(bean interface{}) (bool, error) {
+	idx, err := ss.routeIndex(bean)
+	if err != nil {
+		return false, err
+	}
+	session := ss.newSession(ss.se.shards[idx])
+	defer session.Close()
+	return session.Get(bean)
+}
+
+// Exist returns true if the record exists on the shard of the bean
+func (ss *ShardedSession) Exist(bean interface{}) (bool, error) {
+	idx, err := ss.routeIndex(bean)
+	if err != nil {
+		return false, err
+	}
+	session := ss.newSession(ss.se.shards[idx])
+	defer session.Close()
+	return session.Exist(bean)
+}
+
+// Update updates records on the shard of the bean or the condition bean
+func (ss *ShardedSession) Update(bean interface{}, condiBeans ...interface{}) (int64, error) {
+	idx, err := ss.routeIndex(append([]interface{}{bean}, condiBeans...)...)
+	if err != nil {
+		return 0, err
+	}
+	session := ss.newSession(ss.se.shards[idx])
+	defer session.Close()
+	return session.Update(bean, condiBeans...)
+}
+
+// Delete deletes records on the shard of the bean
+func (ss *ShardedSession) Delete(bean interface{}) (int64, error) {
+	idx, err := ss.routeIndex(bean)
+	if err != nil {
+		return 0, err
+	}
+	session := ss.newSession(ss.se.shards[idx])
+	defer session.Close()
+	return session.Delete(bean)
+}
+
+// Count counts the records on all the shards
+func (ss *ShardedSession) Count(bean ...interface{}) (int64, error) {
+	var lock sync.Mutex
+	var total int64
+	err := ss.fanOut(func(i int, session *Session) error {
+		cnt, err := session.Count(bean...)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		total += cnt
+		lock.Unlock()
+		return nil
+	})
+	return total, err
+}
+
+// Sum sums the column on all the shards
+func (ss *ShardedSession) Sum(bean interface{}, colName string) (float64, error) {
+	res, err := ss.Sums(bean, colName)
+	if err != nil {
+		return 0, err
+	}
+	return res[0], nil
+}
+
+// SumInt sums the column on all the shards
+func (ss *ShardedSession) SumInt(bean interface{}, colName string) (int64, error) {
+	var lock sync.Mutex
+	var total int64
+	err := ss.fanOut(func(i int, session *Session) error {
+		res, err := session.SumInt(bean, colName)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		total += res
+		lock.Unlock()
+		return nil
+	})
+	return total, err
+}
+
+// Sums sums the columns on all the shards
+func (ss *ShardedSession) Sums(bean interface{}, colNames ...string) ([]float64, error) {
+	var lock sync.Mutex
+	var total = make([]float64, len(colNames))
+	err := ss.fanOut(func(i int, session *Session) error {
+		res, err := session.Sums(bean, colNames...)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		for j := range res {
+			total[j] += res[j]
+		}
+		lock.Unlock()
+		return nil
+	})
+	return total, err
+}
+
+// Find retrieves records from all the shards, merges them, sorts them
+// according Asc, Desc or OrderBy and then applies the limit
+func (ss *ShardedSession) Find(rowsSlicePtr interface{}, condiBean ...interface{}) error {
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice && sliceValue.Kind() != reflect.Map {
+		return errors.New("needs a pointer to a slice or a map")
+	}
+
+	engines, err := ss.targets()
+	if err != nil {
+		return err
+	}
+	results := make([]reflect.Value, len(engines))
+	err = ss.fanOut(func(i int, session *Session) error {
+		res := reflect.New(sliceValue.Type())
+		if sliceValue.Kind() == reflect.Map {
+			res.Elem().Set(reflect.MakeMap(sliceValue.Type()))
+		}
+		if ss.limit > 0 {
+			session.Limit(ss.limit + ss.start)
+		}
+		if err := session.Find(res.Interface(), condiBean...); err != nil {
+			return err
+		}
+		results[i] = res.Elem()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if sliceValue.Kind() == reflect.Map {
+		if sliceValue.IsNil() {
+			sliceValue.Set(reflect.MakeMap(sliceValue.Type()))
+		}
+		for _, res := range results {
+			iter := res.MapRange()
+			for iter.Next() {
+				sliceValue.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		return nil
+	}
+
+	merged := reflect.MakeSlice(sliceValue.Type(), 0, 0)
+	for _, res := range results {
+		merged = reflect.AppendSlice(merged, res)
+	}
+	if len(ss.orders) > 0 && len(engines) > 1 {
+		if err := ss.sortRows(merged); err != nil {
+			return err
+		}
+	}
+	if ss.limit > 0 {
+		start := ss.start
+		if start > merged.Len() {
+			start = merged.Len()
+		}
+		end := start + ss.limit
+		if end > merged.Len() {
+			end = merged.Len()
+		}
+		merged = merged.Slice(start, end)
+	}
+	sliceValue.Set(reflect.AppendSlice(sliceValue, merged))
+	return nil
+}
+
+func (ss *ShardedSession) sortRows(rows reflect.Value) error {
+	elemType := rows.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot sort merged rows of type %v", elemType)
+	}
+	table, err := ss.se.shards[0].TableInfo(reflect.New(elemType).Interface())
+	if err != nil {
+		return err
+	}
+	var cols = make([]*schemasvr.Column, len(ss.orders))
+	for i, order := range ss.orders {
+		name := order.column
+		if idx := strings.LastIndexByte(name, '.'); idx > -1 {
+			name = name[idx+1:]
+		}
+		cols[i] = table.GetColumn(name)
+		if cols[i] == nil {
+			return fmt.Errorf("order column %s is not a column of %s", order.column, table.Name)
+		}
+	}
+	field := func(i int, col *schemasvr.Column) reflect.Value {
+		v := rows.Index(i)
+		if isPtr {
+			v = v.Elem()
+		}
+		return v.FieldByIndex(col.FieldIndex)
+	}
+	sort.SliceStable(rows.Interface(), func(i, j int) bool {
+		for k, col := range cols {
+			c := compareValues(field(i, col), field(j, col))
+			if c == 0 {
+				continue
+			}
+			if ss.orders[k].desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// compareValues compares two values of the same type, nil is less than any other value
+func compareValues(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		return compareValues(a.Elem(), b.Elem())
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		tb := b.Interface().(time.Time)
+		return compareOrdered(ta.Before(tb), ta.After(tb))
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// ShardedTx represents a transaction on a sharded engine, sessions are begun
+// lazily on the shards which are touched
+type ShardedTx struct {
+	se       *ShardedEngine
+	sessions map[int]*Session
+	order    []int
+}
+
+// SessionForKey returns the transaction session of the shard of the key
+func (tx *ShardedTx) SessionForKey(key interface{}) (*Session, error) {
+	idx, err := tx.se.ShardIndex(key)
+	if err != nil {
+		return nil, err
+	}
+	return tx.session(idx)
+}
+
+// Session returns the transaction session of the shard of the bean
+func (tx *ShardedTx) Session(bean interface{}) (*Session, error) {
+	idx, err := tx.se.beanShardIndex(bean)
+	if err != nil {
+		return nil, err
+	}
+	return tx.session(idx)
+}
+
+func (tx *ShardedTx) session(idx int) (*Session, error) {
+	if session, ok := tx.sessions[idx]; ok {
+		return session, nil
+	}
+	if len(tx.sessions) > 0 && !tx.se.allowCrossShardTx {
+		return nil, ErrCrossShardTransaction
+	}
+	session := tx.se.shards[idx].NewSession()
+	if err := session.Begin(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	tx.sessions[idx] = session
+	tx.order = append(tx.order, idx)
+	return session, nil
+}
+
+// Insert inserts the bean in the transaction of its shard
+func (tx *ShardedTx) Insert(bean interface{}) (int64, error) {
+	session, err := tx.Session(bean)
+	if err != nil {
+		return 0, err
+	}
+	return session.Insert(bean)
+}
+
+// Get retrieves the bean in the transaction of its shard
+func (tx *ShardedTx) Get(bean interface{}) (bool, error) {
+	session, err := tx.Session(bean)
+	if err != nil {
+		return false, err
+	}
+	return session.Get(bean)
+}
+
+// Update updates the bean in the transaction of its shard
+func (tx *ShardedTx) Update(bean interface{}, condiBeans ...interface{}) (int64, error) {
+	idx, err := tx.se.beanShardIndex(append([]interface{}{bean}, condiBeans...)...)
+	if err != nil {
+		return 0, err
+	}
+	session, err := tx.session(idx)
+	if err != nil {
+		return 0, err
+	}
+	return session.Update(bean, condiBeans...)
+}
+
+// Delete deletes the bean in the transaction of its shard
+func (tx *ShardedTx) Delete(bean interface{}) (int64, error) {
+	session, err := tx.Session(bean)
+	if err != nil {
+		return 0, err
+	}
+	return session.Delete(bean)
+}
+
+func (tx *ShardedTx) commit() error {
+	for i, idx := range tx.order {
+		if err := tx.sessions[idx].Commit(); err != nil {
+			for _, rest := range tx.order[i+1:] {
+				_ = tx.sessions[rest].Rollback()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *ShardedTx) rollback() {
+	for _, idx := range tx.order {
+		_ = tx.sessions[idx].Rollback()
+	}
+}
+
+func (tx *ShardedTx) close() {
+	for _, session := range tx.sessions {
+		session.Close()
+	}
+}
@@ -39,4 +39,12 @@ var (
 	ErrCacheFailed = errors.New("Cache failed")
 	// ErrConditionType condition type unsupported
 	ErrConditionType = errors.New("Unsupported condition type")
+	// ErrNotInTransaction the session has no open transaction
+	ErrNotInTransaction = errors.New("Not in a transaction")
+	// ErrSavepointUnsupported the database has no savepoints
+	ErrSavepointUnsupported = errors.New("Savepoints are not supported")
+	// ErrNoDialect the condition can only be rendered by a session which knows the dialect
+	ErrNoDialect = errors.New("Condition needs the dialect of a session")
+	// ErrNotTracked the bean was not loaded by a tracked session
+	ErrNotTracked = errors.New("Bean is not tracked")
 )
@@ -0,0 +1,234 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// EventType is the type of the change of a row
+type EventType int
+
+// enumerates all the event types
+const (
+	EventCreated EventType = iota + 1
+	EventUpdated
+	EventDeleted
+)
+
+// String returns the name of the event type
+func (tp EventType) String() string {
+	switch tp {
+	case EventCreated:
+		return "created"
+	case EventUpdated:
+		return "updated"
+	case EventDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Event represents a change of the rows of a table, it's published once the
+// change is committed
+type Event struct {
+	Type  EventType
+	Table string
+	// Bean is the inserted or updated bean, or the condition bean of a delete.
+	// It's a map[string]interface{} for the maps inserted by columns.
+	Bean interface{}
+	// Columns are the inserted or updated columns, the deleted column of a
+	// soft delete and nil for a delete
+	Columns []string
+	// Affected is the number of the rows changed by the statement
+	Affected int64
+	// Context is the context of the session which made the change
+	Context context.Context
+}
+
+// HasColumn returns true if the column is in the changed columns of the event
+func (event *Event) HasColumn(name string) bool {
+	for _, col := range event.Columns {
+		if strings.EqualFold(col, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// EventHandler handles the events of the subscribed tables, the handlers are
+// called in the order of subscription by the goroutine which committed the change
+type EventHandler func(*Event)
+
+type eventSubscriber struct {
+	id      int64
+	table   string
+	types   []EventType
+	handler EventHandler
+}
+
+func (sub *eventSubscriber) match(event *Event) bool {
+	if sub.table != "" && sub.table != event.Table {
+		return false
+	}
+	if len(sub.types) == 0 {
+		return true
+	}
+	for _, tp := range sub.types {
+		if tp == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// eventBus dispatches the events of an engine to the subscribers
+type eventBus struct {
+	mutex       sync.RWMutex
+	subscribers []*eventSubscriber
+	lastID      int64
+}
+
+func (bus *eventBus) subscribe(table string, handler EventHandler, types []EventType) func() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.lastID++
+	id := bus.lastID
+	// copy on write, so publish doesn't hold the lock while calling the handlers
+	subscribers := make([]*eventSubscriber, len(bus.subscribers), len(bus.subscribers)+1)
+	copy(subscribers, bus.subscribers)
+	bus.subscribers = append(subscribers, &eventSubscriber{
+		id:      id,
+		table:   table,
+		types:   types,
+		handler: handler,
+	})
+	return func() {
+		bus.unsubscribe(id)
+	}
+}
+
+func (bus *eventBus) unsubscribe(id int64) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	subscribers := make([]*eventSubscriber, 0, len(bus.subscribers))
+	for _, sub := range bus.subscribers {
+		if sub.id != id {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	bus.subscribers = subscribers
+}
+
+func (bus *eventBus) hasSubscribers() bool {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	return len(bus.subscribers) > 0
+}
+
+func (bus *eventBus) publish(events ...*Event) {
+	bus.mutex.RLock()
+	subscribers := bus.subscribers
+	bus.mutex.RUnlock()
+	for _, event := range events {
+		for _, sub := range subscribers {
+			if sub.match(event) {
+				sub.handler(event)
+			}
+		}
+	}
+}
+
+// Subscribe registers the handler for the events of the table, all the tables
+// if table is empty, and of the types, all the types if none is given. The
+// events are published after the commit of the transaction which made the
+// changes, the events of a rolled back transaction are dropped. The returned
+// function cancels the subscription.
+func (engine *Engine) Subscribe(table string, handler EventHandler, types ...EventType) func() {
+	return engine.events.subscribe(table, handler, types)
+}
+
+// emitEvent publishes the event, or keeps it until the commit if the session
+// is in a transaction
+func (session *Session) emitEvent(tp EventType, tableName string, bean interface{}, columns []string, affected int64) {
+	if affected <= 0 || !session.engine.events.hasSubscribers() {
+		return
+	}
+	event := &Event{
+		Type:     tp,
+		Table:    tableName,
+		Bean:     bean,
+		Columns:  columns,
+		Affected: affected,
+		Context:  session.ctx,
+	}
+	if session.isAutoCommit {
+		session.engine.events.publish(event)
+		return
+	}
+	session.txEvents = append(session.txEvents, event)
+}
+
+// publishTxEvents publishes the events of the committed transaction
+func (session *Session) publishTxEvents() {
+	events := session.txEvents
+	session.txEvents = nil
+	session.txEventMarks = nil
+	session.engine.events.publish(events...)
+}
+
+// dropTxEvents drops the events of the rolled back transaction, or only the
+// ones after the savepoint if name is not empty
+func (session *Session) dropTxEvents(name string) {
+	if name == "" {
+		session.txEvents = nil
+		session.txEventMarks = nil
+		return
+	}
+	if mark, ok := session.txEventMarks[name]; ok && mark <= len(session.txEvents) {
+		session.txEvents = session.txEvents[:mark]
+	}
+}
+
+// markTxEvents remembers the events emitted before the savepoint
+func (session *Session) markTxEvents(name string) {
+	if session.txEventMarks == nil {
+		session.txEventMarks = make(map[string]int)
+	}
+	session.txEventMarks[name] = len(session.txEvents)
+}
+
+// updatedColumns returns the names of the columns set by the update expressions
+func (session *Session) updatedColumns(exprs []string) []string {
+	var (
+		quoter  = session.engine.dialect.Quoter()
+		columns = make([]string, 0, len(exprs))
+	)
+	for _, expr := range exprs {
+		if i := strings.Index(expr, "="); i > 0 {
+			expr = expr[:i]
+		}
+		columns = append(columns, quoter.Trim(strings.TrimSpace(expr)))
+	}
+	return columns
+}
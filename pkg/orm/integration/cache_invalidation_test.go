@@ -0,0 +1,164 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm/cache"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+type CacheInvUser struct {
+	Id      int64
+	Name    string
+	GroupId int64
+}
+
+type CacheInvGroup struct {
+	Id     int64
+	Active bool
+}
+
+func prepareCacheInvalidation(t *testing.T) *cache.LRUCacher {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(CacheInvUser), new(CacheInvGroup))
+	cacher := cache.NewLRUCacher2(cache.NewMemoryStore(), time.Hour, 10000)
+	assert.NoError(t, testEngine.MapCacher(new(CacheInvUser), cacher))
+	_, err := testEngine.Insert(&CacheInvGroup{Id: 1, Active: true}, &CacheInvGroup{Id: 2, Active: false})
+	assert.NoError(t, err)
+	_, err = testEngine.Insert(&CacheInvUser{Id: 1, Name: "a", GroupId: 1},
+		&CacheInvUser{Id: 2, Name: "b", GroupId: 2})
+	assert.NoError(t, err)
+	return cacher
+}
+
+func cacheInvID(t *testing.T, id int64) string {
+	pk := schemasvr.PK{id}
+	sid, err := pk.ToString()
+	assert.NoError(t, err)
+	return sid
+}
+
+func TestCacheInvalidationRawExec(t *testing.T) {
+	cacher := prepareCacheInvalidation(t)
+	defer testEngine.MapCacher(new(CacheInvUser), nil)
+
+	var user CacheInvUser
+	has, err := testEngine.ID(1).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.NotNil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 1)))
+
+	_, err = testEngine.Exec("UPDATE "+testEngine.Quote("cache_inv_user")+" SET "+testEngine.Quote("name")+" = ? WHERE "+testEngine.Quote("id")+" = ?", "a2", 1)
+	assert.NoError(t, err)
+	assert.Nil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 1)))
+
+	user = CacheInvUser{}
+	has, err = testEngine.ID(1).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a2", user.Name)
+
+	// SQL() statements through Query are invalidated as well
+	_, err = testEngine.SQL("UPDATE "+testEngine.Quote("cache_inv_user")+" SET "+testEngine.Quote("name")+" = ? WHERE "+testEngine.Quote("id")+" = ?", "a3", 1).Query()
+	assert.NoError(t, err)
+	user = CacheInvUser{}
+	has, err = testEngine.ID(1).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a3", user.Name)
+}
+
+func TestCacheInvalidationUpdatedIDs(t *testing.T) {
+	cacher := prepareCacheInvalidation(t)
+	defer testEngine.MapCacher(new(CacheInvUser), nil)
+
+	var users []CacheInvUser
+	assert.NoError(t, testEngine.Find(&users))
+	assert.EqualValues(t, 2, len(users))
+	assert.NotNil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 1)))
+	assert.NotNil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 2)))
+
+	// only the bean of the updated row is evicted when the table is known
+	_, err := testEngine.Table(new(CacheInvUser)).Exec("UPDATE "+testEngine.Quote("cache_inv_user")+" SET "+testEngine.Quote("name")+" = ? WHERE "+testEngine.Quote("id")+" = ?", "b2", 2)
+	assert.NoError(t, err)
+	assert.NotNil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 1)))
+	assert.Nil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 2)))
+
+	users = nil
+	assert.NoError(t, testEngine.Asc("id").Find(&users))
+	assert.EqualValues(t, 2, len(users))
+	assert.EqualValues(t, "b2", users[1].Name)
+}
+
+func TestCacheInvalidationJoin(t *testing.T) {
+	prepareCacheInvalidation(t)
+	defer testEngine.MapCacher(new(CacheInvUser), nil)
+
+	findActive := func() []CacheInvUser {
+		var users []CacheInvUser
+		assert.NoError(t, testEngine.Join("INNER", "cache_inv_group", "cache_inv_group.id = cache_inv_user.group_id").
+			Where("cache_inv_group.active = ?", true).Asc("cache_inv_user.id").Find(&users))
+		return users
+	}
+	users := findActive()
+	assert.EqualValues(t, 1, len(users))
+	assert.EqualValues(t, 1, users[0].Id)
+	assert.EqualValues(t, "a", users[0].Name)
+
+	// the joined table has no cacher but the cached join query depends on it
+	_, err := testEngine.ID(2).Cols("active").Update(&CacheInvGroup{Active: true})
+	assert.NoError(t, err)
+	users = findActive()
+	assert.EqualValues(t, 2, len(users))
+
+	_, err = testEngine.Exec("UPDATE "+testEngine.Quote("cache_inv_group")+" SET "+testEngine.Quote("active")+" = ? WHERE "+testEngine.Quote("id")+" = ?", false, 1)
+	assert.NoError(t, err)
+	users = findActive()
+	assert.EqualValues(t, 1, len(users))
+	assert.EqualValues(t, 2, users[0].Id)
+}
+
+func TestCacheInvalidationTransaction(t *testing.T) {
+	cacher := prepareCacheInvalidation(t)
+	defer testEngine.MapCacher(new(CacheInvUser), nil)
+
+	var user CacheInvUser
+	has, err := testEngine.ID(1).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	_, err = session.Exec("DELETE FROM "+testEngine.Quote("cache_inv_user")+" WHERE "+testEngine.Quote("id")+" = ?", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+	assert.Nil(t, cacher.GetBean("cache_inv_user", cacheInvID(t, 1)))
+
+	user = CacheInvUser{}
+	has, err = testEngine.ID(1).Get(&user)
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
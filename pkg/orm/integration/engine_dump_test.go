@@ -0,0 +1,257 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/stretchr/testify/assert"
+)
+
+type DumpStreamUser struct {
+	Id      int64
+	Name    string `orm:"varchar(50) index"`
+	Age     int
+	Active  bool
+	Score   float64
+	Avatar  []byte
+	Note    *string
+	Created time.Time
+}
+
+type DumpStreamMember struct {
+	GroupId int64  `orm:"pk"`
+	UserId  int64  `orm:"pk"`
+	Role    string `orm:"varchar(20)"`
+}
+
+type DumpStreamLog struct {
+	Message string
+	Level   int
+}
+
+func newDumpStreamEngine(t *testing.T, name string) *orm.Engine {
+	engine, err := orm.NewEngine("sqlite3", filepath.Join(t.TempDir(), name+".db"))
+	assert.NoError(t, err)
+	return engine
+}
+
+func prepareDumpStreamSource(t *testing.T) *orm.Engine {
+	engine := newDumpStreamEngine(t, "source")
+	assert.NoError(t, engine.Sync2(new(DumpStreamUser), new(DumpStreamMember), new(DumpStreamLog)))
+
+	note := "hello"
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		user := DumpStreamUser{
+			Name:    fmt.Sprintf("user%d", i),
+			Age:     20 + i,
+			Active:  i%2 == 0,
+			Score:   float64(i) + 0.5,
+			Avatar:  []byte{0, byte(i), 0xff},
+			Created: created.Add(time.Duration(i) * time.Hour),
+		}
+		if i%3 == 0 {
+			user.Note = &note
+		}
+		_, err := engine.Insert(&user)
+		assert.NoError(t, err)
+	}
+	for g := int64(1); g <= 2; g++ {
+		for u := int64(1); u <= 3; u++ {
+			_, err := engine.Insert(&DumpStreamMember{GroupId: g, UserId: u, Role: fmt.Sprintf("r%d", g*u)})
+			assert.NoError(t, err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		_, err := engine.Insert(&DumpStreamLog{Message: fmt.Sprintf("log%d", i), Level: i})
+		assert.NoError(t, err)
+	}
+	return engine
+}
+
+func assertDumpStreamEqual(t *testing.T, src, dst *orm.Engine) {
+	var srcUsers, dstUsers []DumpStreamUser
+	assert.NoError(t, src.Asc("id").Find(&srcUsers))
+	assert.NoError(t, dst.Asc("id").Find(&dstUsers))
+	assert.EqualValues(t, len(srcUsers), len(dstUsers))
+	for i := range srcUsers {
+		assert.EqualValues(t, srcUsers[i].Name, dstUsers[i].Name)
+		assert.EqualValues(t, srcUsers[i].Age, dstUsers[i].Age)
+		assert.EqualValues(t, srcUsers[i].Active, dstUsers[i].Active)
+		assert.EqualValues(t, srcUsers[i].Score, dstUsers[i].Score)
+		assert.EqualValues(t, srcUsers[i].Avatar, dstUsers[i].Avatar)
+		assert.EqualValues(t, srcUsers[i].Note, dstUsers[i].Note)
+		assert.True(t, srcUsers[i].Created.Equal(dstUsers[i].Created))
+	}
+
+	var srcMembers, dstMembers []DumpStreamMember
+	assert.NoError(t, src.Asc("group_id", "user_id").Find(&srcMembers))
+	assert.NoError(t, dst.Asc("group_id", "user_id").Find(&dstMembers))
+	assert.EqualValues(t, srcMembers, dstMembers)
+
+	var srcLogs, dstLogs []DumpStreamLog
+	assert.NoError(t, src.Asc("level").Find(&srcLogs))
+	assert.NoError(t, dst.Asc("level").Find(&dstLogs))
+	assert.EqualValues(t, srcLogs, dstLogs)
+}
+
+func TestDumpStreamRestore(t *testing.T) {
+	src := prepareDumpStreamSource(t)
+	defer src.Close()
+
+	var buf bytes.Buffer
+	var progress []orm.DumpPosition
+	err := src.DumpStream(context.Background(), &buf, orm.DumpOptions{
+		BatchSize: 2,
+		Parallel:  3,
+		OnProgress: func(pos orm.DumpPosition) {
+			progress = append(progress, pos)
+		},
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, progress)
+
+	dst := newDumpStreamEngine(t, "target")
+	defer dst.Close()
+	assert.NoError(t, dst.Restore(context.Background(), bytes.NewReader(buf.Bytes()), orm.RestoreOptions{BatchSize: 3}))
+	assertDumpStreamEqual(t, src, dst)
+
+	tables, err := dst.DBMetas()
+	assert.NoError(t, err)
+	var hasIndex bool
+	for _, table := range tables {
+		if strings.EqualFold(table.Name, "dump_stream_user") {
+			assert.EqualValues(t, "id", table.AutoIncrement)
+			hasIndex = len(table.Indexes) > 0
+		}
+	}
+	assert.True(t, hasIndex)
+
+	// the autoincrement continues after the restored rows
+	user := DumpStreamUser{Name: "new"}
+	_, err = dst.Insert(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, user.Id)
+}
+
+func TestDumpStreamChecksum(t *testing.T) {
+	src := prepareDumpStreamSource(t)
+	defer src.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, src.DumpStream(context.Background(), &buf, orm.DumpOptions{Tables: []string{"dump_stream_log"}}))
+	corrupted := strings.Replace(buf.String(), "log3", "log9", 1)
+
+	dst := newDumpStreamEngine(t, "target")
+	defer dst.Close()
+	err := dst.Restore(context.Background(), strings.NewReader(corrupted), orm.RestoreOptions{})
+	assert.True(t, errors.Is(err, orm.ErrDumpChecksum))
+	// the corrupted batch is not committed
+	cnt, err := dst.Count(new(DumpStreamLog))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	// a truncated stream is reported
+	lines := strings.SplitAfter(buf.String(), "\n")
+	err = dst.Restore(context.Background(), strings.NewReader(strings.Join(lines[:len(lines)-2], "")), orm.RestoreOptions{SkipCreate: true})
+	assert.Error(t, err)
+}
+
+func TestDumpStreamResume(t *testing.T) {
+	src := prepareDumpStreamSource(t)
+	defer src.Close()
+
+	// record the position after the first batch
+	var first bytes.Buffer
+	var positions = make(map[string]orm.DumpPosition)
+	err := src.DumpStream(context.Background(), &first, orm.DumpOptions{
+		BatchSize: 2,
+		OnProgress: func(pos orm.DumpPosition) {
+			if _, ok := positions[pos.Table]; !ok {
+				positions[pos.Table] = pos
+			}
+		},
+		Tables: []string{"dump_stream_user"},
+	})
+	assert.NoError(t, err)
+	pos := positions["dump_stream_user"]
+	assert.EqualValues(t, []string{"2"}, pos.LastPK)
+	assert.EqualValues(t, 2, pos.Rows)
+
+	// the resumed dump only contains the rows after the position
+	var resumed bytes.Buffer
+	assert.NoError(t, src.DumpStream(context.Background(), &resumed, orm.DumpOptions{
+		BatchSize: 2,
+		Tables:    []string{"dump_stream_user", "dump_stream_member"},
+		Resume: []orm.DumpPosition{
+			pos,
+			{Table: "dump_stream_member", LastPK: []string{"1", "3"}, Rows: 3},
+		},
+	}))
+	assert.NotContains(t, resumed.String(), `"user1"`)
+	assert.NotContains(t, resumed.String(), `"user2"`)
+	assert.Contains(t, resumed.String(), `"user3"`)
+	assert.NotContains(t, resumed.String(), `"r3"`)
+	assert.Contains(t, resumed.String(), `"r6"`)
+
+	// the rows of a table without primary key have no stable order
+	err = src.DumpStream(context.Background(), &bytes.Buffer{}, orm.DumpOptions{
+		Tables: []string{"dump_stream_log"},
+		Resume: []orm.DumpPosition{{Table: "dump_stream_log", Rows: 2}},
+	})
+	assert.True(t, errors.Is(err, orm.ErrDumpResumeNoPK))
+
+	// restore the first rows, then resume the restore of the full dump
+	var full bytes.Buffer
+	assert.NoError(t, src.DumpStream(context.Background(), &full, orm.DumpOptions{BatchSize: 2}))
+
+	dst := newDumpStreamEngine(t, "target")
+	defer dst.Close()
+	assert.NoError(t, dst.Sync2(new(DumpStreamUser), new(DumpStreamMember), new(DumpStreamLog)))
+	_, err = dst.Insert(&[]DumpStreamLog{{Message: "log0", Level: 0}, {Message: "log1", Level: 1}})
+	assert.NoError(t, err)
+	var members []DumpStreamMember
+	assert.NoError(t, src.Find(&members))
+	_, err = dst.Insert(&members)
+	assert.NoError(t, err)
+
+	var restored []orm.DumpPosition
+	assert.NoError(t, dst.Restore(context.Background(), bytes.NewReader(full.Bytes()), orm.RestoreOptions{
+		Resume: []orm.DumpPosition{
+			{Table: "dump_stream_log", Rows: 2},
+			{Table: "dump_stream_member", Done: true},
+		},
+		OnProgress: func(pos orm.DumpPosition) {
+			restored = append(restored, pos)
+		},
+	}))
+	assertDumpStreamEqual(t, src, dst)
+	assert.NotEmpty(t, restored)
+}
@@ -0,0 +1,210 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSqliteEngineGroup(t *testing.T, slaves int) *orm.EngineGroup {
+	dir := t.TempDir()
+	master, err := orm.NewEngine("sqlite3", filepath.Join(dir, "master.db"))
+	assert.NoError(t, err)
+	var engines []*orm.Engine
+	for i := 0; i < slaves; i++ {
+		slave, err := orm.NewEngine("sqlite3", filepath.Join(dir, fmt.Sprintf("slave%d.db", i)))
+		assert.NoError(t, err)
+		engines = append(engines, slave)
+	}
+	eg, err := orm.NewEngineGroup(master, engines)
+	assert.NoError(t, err)
+	return eg
+}
+
+func TestEngineGroupHealthCheck(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 2)
+	defer eg.Close()
+	slave0, slave1 := eg.Slaves()[0], eg.Slaves()[1]
+
+	var lock sync.Mutex
+	var failing = make(map[*orm.Engine]bool)
+	setFailing := func(slave *orm.Engine, fail bool) {
+		lock.Lock()
+		failing[slave] = fail
+		lock.Unlock()
+	}
+	eg.SetHealthCheckConfig(orm.HealthCheckConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 2,
+		MaxLag:           time.Second,
+		LagProbe: func(ctx context.Context, slave *orm.Engine) (time.Duration, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if failing[slave] {
+				return time.Minute, nil
+			}
+			return 0, nil
+		},
+	})
+
+	eg.CheckHealth(context.Background())
+	for _, status := range eg.SlaveStatuses() {
+		assert.True(t, status.Healthy)
+	}
+
+	setFailing(slave0, true)
+	eg.CheckHealth(context.Background())
+	statuses := eg.SlaveStatuses()
+	assert.False(t, statuses[0].Healthy)
+	assert.Error(t, statuses[0].LastError)
+	assert.EqualValues(t, time.Minute, statuses[0].Lag)
+	assert.True(t, statuses[1].Healthy)
+	for i := 0; i < 10; i++ {
+		assert.True(t, eg.Slave() == slave1)
+	}
+
+	setFailing(slave1, true)
+	eg.CheckHealth(context.Background())
+	assert.True(t, eg.Slave() == eg.Master())
+
+	// needs two successful checks to be admitted again
+	setFailing(slave0, false)
+	eg.CheckHealth(context.Background())
+	assert.True(t, eg.Slave() == eg.Master())
+	eg.CheckHealth(context.Background())
+	assert.True(t, eg.Slave() == slave0)
+}
+
+func TestEngineGroupStartHealthCheck(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 1)
+	defer eg.Close()
+
+	eg.StartHealthCheck(orm.HealthCheckConfig{
+		Interval: time.Millisecond,
+		MaxLag:   time.Second,
+		LagProbe: func(ctx context.Context, slave *orm.Engine) (time.Duration, error) {
+			return 0, errors.New("broken replication")
+		},
+	})
+	assert.Eventually(t, func() bool {
+		return !eg.SlaveStatuses()[0].Healthy
+	}, time.Second, time.Millisecond)
+	eg.StopHealthCheck()
+	assert.True(t, eg.Slave() == eg.Master())
+}
+
+func TestEngineGroupReadYourWrites(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 1)
+	defer eg.Close()
+
+	// the table only exists on the master
+	ctx := orm.ReadYourWrites(context.Background())
+	_, err := eg.Context(ctx).Exec("CREATE TABLE ryw (id INTEGER)")
+	assert.NoError(t, err)
+
+	_, err = eg.Context(context.Background()).QueryString("SELECT * FROM ryw")
+	assert.Error(t, err)
+	_, err = eg.Context(ctx).QueryString("SELECT * FROM ryw")
+	assert.NoError(t, err)
+
+	eg.SetStickyWindow(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, err = eg.Context(ctx).QueryString("SELECT * FROM ryw")
+	assert.Error(t, err)
+}
+
+func TestEngineGroupCTERead(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 1)
+	defer eg.Close()
+
+	// the table only exists on the slave
+	_, err := eg.Slaves()[0].Exec("CREATE TABLE cte_read (id INTEGER)")
+	assert.NoError(t, err)
+
+	// the reads starting with common table expressions go to the slave and
+	// don't stick the context to the master
+	ctx := orm.ReadYourWrites(context.Background())
+	_, err = eg.Context(ctx).QueryString("WITH c AS (SELECT id FROM cte_read) SELECT * FROM c")
+	assert.NoError(t, err)
+	_, err = eg.Context(ctx).With("c", "SELECT id FROM cte_read").Table("c").QueryString()
+	assert.NoError(t, err)
+	_, err = eg.Context(ctx).WithRecursive("n (v)", "SELECT 1 UNION ALL SELECT v + 1 FROM n WHERE v < 3").
+		Table("n").QueryString()
+	assert.NoError(t, err)
+	_, err = eg.Context(ctx).QueryString("SELECT * FROM cte_read")
+	assert.NoError(t, err)
+}
+
+func TestEngineGroupNonWriteQuery(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 1)
+	defer eg.Close()
+
+	// the table only exists on the slave
+	_, err := eg.Slaves()[0].Exec("CREATE TABLE non_write (id INTEGER)")
+	assert.NoError(t, err)
+
+	// the queries which don't write don't stick the context to the master
+	ctx := orm.ReadYourWrites(context.Background())
+	for _, sql := range []string{"PRAGMA user_version", "EXPLAIN SELECT 1", "VALUES (1)"} {
+		_, err = eg.Context(ctx).QueryString(sql)
+		assert.NoError(t, err, sql)
+	}
+	_, err = eg.Context(ctx).QueryString("SELECT * FROM non_write")
+	assert.NoError(t, err)
+}
+
+func TestEngineGroupReadOnlyTransaction(t *testing.T) {
+	eg := newSqliteEngineGroup(t, 1)
+	defer eg.Close()
+
+	// the table only exists on the master
+	_, err := eg.Exec("CREATE TABLE ro (id INTEGER)")
+	assert.NoError(t, err)
+
+	query := func(session *orm.Session) (interface{}, error) {
+		return session.QueryString("SELECT * FROM ro")
+	}
+	_, err = eg.TransactionWithOptions(context.Background(), &sql.TxOptions{ReadOnly: true}, query)
+	assert.Error(t, err)
+	_, err = eg.TransactionWithOptions(context.Background(), &sql.TxOptions{}, query)
+	assert.NoError(t, err)
+	_, err = eg.TransactionWithRetry(context.Background(), &orm.TxRetryOptions{
+		TxOptions: &sql.TxOptions{ReadOnly: true},
+	}, query)
+	assert.Error(t, err)
+
+	session := eg.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}))
+	_, err = session.QueryString("SELECT * FROM ro")
+	assert.Error(t, err)
+	assert.NoError(t, session.Rollback())
+}
@@ -0,0 +1,179 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/stretchr/testify/assert"
+)
+
+type ShardedOrder struct {
+	Id       int64
+	TenantId int64 `orm:"shardkey index"`
+	Amount   int
+	Name     string
+}
+
+func newSqliteShardedEngine(t *testing.T, shards int) *orm.ShardedEngine {
+	dir := t.TempDir()
+	var engines []*orm.Engine
+	for i := 0; i < shards; i++ {
+		engine, err := orm.NewEngine("sqlite3", filepath.Join(dir, fmt.Sprintf("shard%d.db", i)))
+		assert.NoError(t, err)
+		engines = append(engines, engine)
+	}
+	se, err := orm.NewShardedEngine(engines, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, se.Sync2(new(ShardedOrder)))
+	return se
+}
+
+func TestShardedEngineRouting(t *testing.T) {
+	se := newSqliteShardedEngine(t, 2)
+	defer se.Close()
+
+	orders := []ShardedOrder{
+		{TenantId: 1, Amount: 10, Name: "a"},
+		{TenantId: 2, Amount: 20, Name: "b"},
+		{TenantId: 3, Amount: 30, Name: "c"},
+		{TenantId: 4, Amount: 40, Name: "d"},
+	}
+	cnt, err := se.Insert(&orders)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+
+	// tenants 2 and 4 live on shard 0, tenants 1 and 3 on shard 1
+	cnt, err = se.Shard(0).Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = se.Shard(1).Where("tenant_id = ?", 3).Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var order = ShardedOrder{TenantId: 3}
+	has, err := se.Get(&order)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "c", order.Name)
+
+	cnt, err = se.Update(&ShardedOrder{Amount: 33}, &ShardedOrder{TenantId: 3})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var orders2 []ShardedOrder
+	assert.NoError(t, se.ShardKeyValue(3).Where("tenant_id = ?", 3).Find(&orders2))
+	assert.EqualValues(t, 1, len(orders2))
+	assert.EqualValues(t, 33, orders2[0].Amount)
+
+	cnt, err = se.Delete(&ShardedOrder{TenantId: 4})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = se.Get(&ShardedOrder{Name: "a"})
+	assert.True(t, errors.Is(err, orm.ErrNoShardKey))
+}
+
+func TestShardedEngineFanOut(t *testing.T) {
+	se := newSqliteShardedEngine(t, 3)
+	defer se.Close()
+
+	for i := 1; i <= 9; i++ {
+		_, err := se.Insert(&ShardedOrder{TenantId: int64(i), Amount: i * 10, Name: fmt.Sprintf("o%d", i)})
+		assert.NoError(t, err)
+	}
+
+	cnt, err := se.Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 9, cnt)
+
+	cnt, err = se.Where("amount > ?", 40).Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, cnt)
+
+	sum, err := se.SumInt(new(ShardedOrder), "amount")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 450, sum)
+
+	sums, err := se.Where("tenant_id < ?", 4).Sums(new(ShardedOrder), "amount", "tenant_id")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []float64{60, 6}, sums)
+
+	var orders []ShardedOrder
+	assert.NoError(t, se.Desc("amount").Limit(3, 1).Find(&orders))
+	assert.EqualValues(t, 3, len(orders))
+	assert.EqualValues(t, 80, orders[0].Amount)
+	assert.EqualValues(t, 70, orders[1].Amount)
+	assert.EqualValues(t, 60, orders[2].Amount)
+
+	var ptrs []*ShardedOrder
+	assert.NoError(t, se.Where("amount <= ?", 50).OrderBy("name ASC").Find(&ptrs))
+	assert.EqualValues(t, 5, len(ptrs))
+	for i, order := range ptrs {
+		assert.EqualValues(t, fmt.Sprintf("o%d", i+1), order.Name)
+	}
+
+	var byID = make(map[int64]ShardedOrder)
+	assert.NoError(t, se.Find(&byID))
+	assert.EqualValues(t, 3, len(byID))
+}
+
+func TestShardedEngineTransaction(t *testing.T) {
+	se := newSqliteShardedEngine(t, 2)
+	defer se.Close()
+
+	_, err := se.Transaction(func(tx *orm.ShardedTx) (interface{}, error) {
+		if _, err := tx.Insert(&ShardedOrder{TenantId: 2, Amount: 1}); err != nil {
+			return nil, err
+		}
+		return tx.Insert(&ShardedOrder{TenantId: 4, Amount: 2})
+	})
+	assert.NoError(t, err)
+
+	_, err = se.Transaction(func(tx *orm.ShardedTx) (interface{}, error) {
+		if _, err := tx.Insert(&ShardedOrder{TenantId: 2, Amount: 3}); err != nil {
+			return nil, err
+		}
+		return tx.Insert(&ShardedOrder{TenantId: 1, Amount: 4})
+	})
+	assert.True(t, errors.Is(err, orm.ErrCrossShardTransaction))
+
+	cnt, err := se.Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	se.AllowCrossShardTransaction(true)
+	_, err = se.Transaction(func(tx *orm.ShardedTx) (interface{}, error) {
+		if _, err := tx.Insert(&ShardedOrder{TenantId: 2, Amount: 3}); err != nil {
+			return nil, err
+		}
+		return tx.Insert(&ShardedOrder{TenantId: 1, Amount: 4})
+	})
+	assert.NoError(t, err)
+
+	cnt, err = se.Count(new(ShardedOrder))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+}
@@ -21,6 +21,7 @@ package integration
 // THE SOFTWARE.
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -29,6 +30,7 @@ import (
 
 	_ "gitee.com/travelliu/dm"
 	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/bhojpur/dbm/pkg/orm/reverse"
 	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
@@ -258,3 +260,42 @@ func TestGetColumns(t *testing.T) {
 	assert.Equal(t, comment, hasComment)
 	assert.Zero(t, noComment)
 }
+
+func TestReverse(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type ReverseUser struct {
+		Id        int64
+		Email     string    `orm:"varchar(100) notnull unique"`
+		TenantId  int64     `orm:"index(tenant_name)"`
+		Name      string    `orm:"varchar(50) index(tenant_name)"`
+		CreatedAt time.Time `orm:"created"`
+		DeletedAt time.Time `orm:"deleted"`
+	}
+	assertSync(t, new(ReverseUser))
+
+	tables, err := testEngine.DBMetas()
+	assert.NoError(t, err)
+	tableName := testEngine.TableName(new(ReverseUser))
+	var reversed []*schemasvr.Table
+	for _, table := range tables {
+		if table.Name == tableName {
+			reversed = append(reversed, table)
+		}
+	}
+	assert.EqualValues(t, 1, len(reversed))
+
+	var buf bytes.Buffer
+	assert.NoError(t, reverse.Generate(&buf, reversed, &reverse.Options{
+		Package:      "models",
+		TableMapper:  testEngine.GetTableMapper(),
+		ColumnMapper: testEngine.GetColumnMapper(),
+		TagName:      "orm",
+	}))
+	code := buf.String()
+	assert.Contains(t, code, "type ReverseUser struct {")
+	assert.Regexp(t, `Id +int(64)? +`+"`"+`orm:"pk autoincr`, code)
+	assert.Regexp(t, `Email +string +`+"`"+`orm:"[^"]*notnull unique"`, code)
+	assert.Regexp(t, `TenantId +int(64)? +`+"`"+`orm:"[^"]*index\(tenant_name\)"`, code)
+	assert.Regexp(t, `CreatedAt +time.Time +`+"`"+`orm:"[^"]*created"`, code)
+	assert.Regexp(t, `DeletedAt +time.Time +`+"`"+`orm:"[^"]*deleted"`, code)
+}
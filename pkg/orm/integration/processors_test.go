@@ -787,3 +787,79 @@ func TestAfterInsert(t *testing.T) {
 	_, err := testEngine.Insert(&AfterInsertStruct{})
 	assert.NoError(t, err)
 }
+
+func TestEvents(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type EventPost struct {
+		Id      int64
+		Title   string
+		Content string
+		Deleted bool
+	}
+	assertSync(t, new(EventPost))
+	tableName := testEngine.TableName(new(EventPost))
+
+	var events []*orm.Event
+	unsubscribe := testEngine.Subscribe(tableName, func(event *orm.Event) {
+		events = append(events, event)
+	})
+	var deletes int
+	unsubscribeDeletes := testEngine.Subscribe("", func(event *orm.Event) {
+		deletes++
+	}, orm.EventDeleted)
+	defer unsubscribeDeletes()
+
+	// without a transaction the events are published at once
+	var post = EventPost{Title: "a"}
+	_, err := testEngine.Insert(&post)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(events))
+	assert.EqualValues(t, orm.EventCreated, events[0].Type)
+	assert.EqualValues(t, tableName, events[0].Table)
+	assert.True(t, events[0].HasColumn("title"))
+	assert.EqualValues(t, post.Id, events[0].Bean.(*EventPost).Id)
+
+	// the events of a rolled back transaction are dropped
+	session := testEngine.NewSession()
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(&EventPost{Title: "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Rollback())
+	session.Close()
+	assert.EqualValues(t, 1, len(events))
+
+	// the events of a committed transaction are published after the commit,
+	// but not the ones of a nested transaction rolled back to its savepoint
+	events = nil
+	session = testEngine.NewSession()
+	defer session.Close()
+	_, err = session.Transaction(func(session *orm.Session) (interface{}, error) {
+		if _, err := session.ID(post.Id).Cols("content").Update(&EventPost{Content: "x"}); err != nil {
+			return nil, err
+		}
+		_, _ = session.Transaction(func(session *orm.Session) (interface{}, error) {
+			if _, err := session.Insert(&EventPost{Title: "c"}); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("rollback")
+		})
+		if _, err := session.Where("title = ?", "z").Update(&EventPost{Content: "y"}); err != nil {
+			return nil, err
+		}
+		assert.EqualValues(t, 0, len(events))
+		return session.ID(post.Id).Delete(new(EventPost))
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(events))
+	assert.EqualValues(t, orm.EventUpdated, events[0].Type)
+	assert.EqualValues(t, []string{"content"}, events[0].Columns)
+	assert.EqualValues(t, 1, events[0].Affected)
+	assert.EqualValues(t, orm.EventDeleted, events[1].Type)
+	assert.Nil(t, events[1].Columns)
+	assert.EqualValues(t, 1, deletes)
+
+	unsubscribe()
+	_, err = testEngine.Table(new(EventPost)).Insert(map[string]interface{}{"title": "d"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(events))
+}
@@ -0,0 +1,127 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/bhojpur/dbm/pkg/orm/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	engine := testEngine.(*orm.Engine)
+	oldLogger := engine.Logger()
+	defer engine.SetLogger(oldLogger)
+
+	var buf bytes.Buffer
+	logger := log.NewSimpleLogger(&buf)
+	logger.SetLevel(log.LOG_WARNING)
+	engine.SetLogger(logger)
+
+	engine.SetSlowQueryThreshold(time.Nanosecond)
+	_, err := engine.QueryString("SELECT 1")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(buf.String(), "[SLOW SQL] SELECT 1"), buf.String())
+
+	buf.Reset()
+	engine.SetSlowQueryThreshold(0)
+	_, err = engine.QueryString("SELECT 1")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "", buf.String())
+}
+
+func TestQueryStats(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type QueryStatsStruct struct {
+		Id   int64
+		Name string
+	}
+	assert.NoError(t, testEngine.Sync(new(QueryStatsStruct)))
+	engine := testEngine.(*orm.Engine)
+	engine.EnableQueryStats(true)
+	defer engine.EnableQueryStats(false)
+
+	for i := 0; i < 3; i++ {
+		_, err := testEngine.Insert(&QueryStatsStruct{Name: "name"})
+		assert.NoError(t, err)
+	}
+	var stats []QueryStatsStruct
+	assert.NoError(t, testEngine.Where("id = ?", 1).Find(&stats))
+	assert.NoError(t, testEngine.Where("id = ?", 2).Find(&stats))
+	_, err := testEngine.Exec("SELECT * FROM not_exist_table")
+	assert.Error(t, err)
+
+	var insertStat *orm.QueryStat
+	var findStat *orm.QueryStat
+	var errorStat *orm.QueryStat
+	for _, stat := range engine.QueryStats().Snapshot() {
+		stat := stat
+		switch {
+		case strings.HasPrefix(stat.Fingerprint, "insert into"):
+			insertStat = &stat
+		case strings.Contains(stat.Fingerprint, "from query_stats_struct where (id = ?)"):
+			findStat = &stat
+		case strings.Contains(stat.Fingerprint, "not_exist_table"):
+			errorStat = &stat
+		}
+	}
+	if assert.NotNil(t, insertStat) {
+		assert.EqualValues(t, 3, insertStat.Count)
+		assert.EqualValues(t, 3, insertStat.RowsAffected)
+		assert.True(t, insertStat.P50 <= insertStat.P99)
+		assert.True(t, insertStat.P99 <= insertStat.MaxTime)
+	}
+	if assert.NotNil(t, findStat) {
+		assert.EqualValues(t, 2, findStat.Count)
+	}
+	if assert.NotNil(t, errorStat) {
+		assert.EqualValues(t, 1, errorStat.Errors)
+	}
+
+	assert.EqualValues(t, 1, len(engine.QueryStats().Top(1)))
+	engine.QueryStats().Reset()
+	assert.EqualValues(t, 0, len(engine.QueryStats().Snapshot()))
+}
+
+func TestQueryStatsDisabled(t *testing.T) {
+	engine, err := orm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "stats.db"))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	assert.Nil(t, engine.QueryStats())
+	engine.EnableQueryStats(false)
+	engine.SetSlowQueryThreshold(0)
+	assert.Nil(t, engine.QueryStats())
+
+	engine.EnableQueryStats(true)
+	_, err = engine.QueryString("SELECT 1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, engine.QueryStats()) {
+		assert.EqualValues(t, 1, len(engine.QueryStats().Snapshot()))
+	}
+}
@@ -27,6 +27,10 @@ import (
 
 	"github.com/bhojpur/sql/pkg/builder"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/bhojpur/dbm/pkg/orm/internal/statement"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
 )
 
 func TestBuilder(t *testing.T) {
@@ -205,3 +209,120 @@ func TestFindAndCount(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 1, total)
 }
+
+func TestJSONPath(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	if testEngine.Dialect().URI().DBType == schemasvr.SQLITE {
+		if _, err := testEngine.QueryString(`SELECT json_extract('{"a":1}', '$.a')`); err != nil {
+			t.Skip("sqlite3 is built without JSON functions, go-sqlite3 needs the sqlite_json tag")
+		}
+	}
+	type JSONPathUser struct {
+		Id    int64
+		Name  string
+		Attrs map[string]interface{} `orm:"jsonb gin jsonindex($.address.city)"`
+	}
+	assertSync(t, new(JSONPathUser))
+	assert.NoError(t, testEngine.Sync(new(JSONPathUser)))
+
+	_, err := testEngine.Insert([]JSONPathUser{
+		{Name: "a", Attrs: map[string]interface{}{"age": 30, "address": map[string]interface{}{"city": "Pune"}}},
+		{Name: "b", Attrs: map[string]interface{}{"age": 40, "address": map[string]interface{}{"city": "Delhi"}}},
+		{Name: "c", Attrs: map[string]interface{}{"age": 50}},
+	})
+	assert.NoError(t, err)
+
+	var users []JSONPathUser
+	assert.NoError(t, testEngine.Where(orm.JSONPath("attrs", "$.address.city").Eq("Pune")).Find(&users))
+	assert.EqualValues(t, 1, len(users))
+	assert.EqualValues(t, "a", users[0].Name)
+
+	cnt, err := testEngine.Where(orm.JSONPath("attrs", "$.age").Gt(35)).Count(new(JSONPathUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Where(orm.JSONPath("attrs", "$.address.city").In("Pune", "Delhi")).
+		And(orm.JSONPath("attrs", "$.age").Lte(30).Or(builder.Eq{"name": "b"})).
+		Count(new(JSONPathUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	cnt, err = testEngine.Where(orm.JSONPath("attrs", "$.address.city").IsNull()).Count(new(JSONPathUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Where(orm.JSONPath("attrs", "address.city").Eq("Pune")).Count(new(JSONPathUser))
+	assert.Error(t, err)
+}
+
+type ScopedPost struct {
+	Id        int64
+	Title     string
+	Published bool
+	Archived  bool
+}
+
+func (ScopedPost) Scopes() map[string]schemasvr.Scope {
+	return map[string]schemasvr.Scope{
+		"published": func() builder.Cond {
+			return builder.Eq{"published": true}
+		},
+		"live": func() builder.Cond {
+			return builder.Eq{"archived": false}
+		},
+		"titled": func() builder.Cond {
+			return builder.Neq{"title": ""}
+		},
+	}
+}
+
+func (ScopedPost) DefaultScopes() []string {
+	return []string{"live"}
+}
+
+func TestScopes(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(ScopedPost))
+
+	_, err := testEngine.Insert([]ScopedPost{
+		{Title: "a", Published: true},
+		{Title: "b"},
+		{Title: "", Published: true},
+		{Title: "d", Published: true, Archived: true},
+	})
+	assert.NoError(t, err)
+
+	var titles []string
+	assert.NoError(t, testEngine.Table(new(ScopedPost)).Asc("id").Cols("title").Find(&titles))
+	assert.EqualValues(t, []string{"a", "b", ""}, titles)
+
+	titles = nil
+	assert.NoError(t, testEngine.Scopes("published", "titled").Table(new(ScopedPost)).Cols("title").Find(&titles))
+	assert.EqualValues(t, []string{"a"}, titles)
+
+	cnt, err := testEngine.WithoutScopes().Count(new(ScopedPost))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+	cnt, err = testEngine.WithoutScopes("live").Scopes("published").Count(new(ScopedPost))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	var post ScopedPost
+	has, err := testEngine.Where("title = ?", "d").Get(&post)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// the scopes restrict the updated and deleted rows
+	cnt, err = testEngine.Scopes("published").Cols("title").Update(&ScopedPost{Title: "x"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Where("title <> ?", "x").Delete(new(ScopedPost))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	titles = nil
+	assert.NoError(t, testEngine.WithoutScopes().Table(new(ScopedPost)).Asc("id").Cols("title").Find(&titles))
+	assert.EqualValues(t, []string{"x", "x", "d"}, titles)
+
+	_, err = testEngine.Scopes("unknown").Count(new(ScopedPost))
+	assert.True(t, errors.Is(err, statement.ErrUnknownScope))
+}
@@ -73,6 +73,32 @@ func TestDelete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, has)
 }
+func TestDeleteLimit(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	if testEngine.Dialect().URI().DBType == schemasvr.MSSQL {
+		t.Skip()
+		return
+	}
+	type DeleteLimit struct {
+		Id   int64
+		Name string
+	}
+	assertSync(t, new(DeleteLimit))
+	_, err := testEngine.Insert([]DeleteLimit{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}})
+	assert.NoError(t, err)
+
+	cnt, err := testEngine.OrderBy("id").Limit(2).Delete(new(DeleteLimit))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Where("name <> ?", "d").Desc("id").Limit(1).Delete(new(DeleteLimit))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var names []string
+	assert.NoError(t, testEngine.Table(new(DeleteLimit)).Asc("id").Cols("name").Find(&names))
+	assert.EqualValues(t, []string{"d"}, names)
+}
+
 func TestDeleted(t *testing.T) {
 	assert.NoError(t, PrepareEngine())
 	type Deleted struct {
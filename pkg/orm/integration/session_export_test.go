@@ -0,0 +1,199 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type ExportUser struct {
+	Id      int64
+	Name    string `orm:"varchar(50)"`
+	Age     int
+	Active  bool
+	Score   float64
+	Avatar  []byte
+	Nick    *string
+	Tags    []string `orm:"json"`
+	Created time.Time
+}
+
+func prepareExportUsers(t *testing.T) []ExportUser {
+	assertSync(t, new(ExportUser))
+	nick := "bob"
+	users := []ExportUser{
+		{Name: "alice", Age: 20, Active: true, Score: 1.5, Avatar: []byte{1, 2, 255}, Tags: []string{"a", "b"}, Created: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Name: "bob, \"jr\"", Age: 30, Score: 2.25, Nick: &nick, Created: time.Date(2022, 6, 7, 8, 9, 10, 0, time.UTC)},
+		{Name: "carol", Age: 1, Active: true},
+	}
+	for i := range users {
+		_, err := testEngine.Insert(&users[i])
+		assert.NoError(t, err)
+	}
+	return users
+}
+
+func TestExportImportRows(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+
+	for _, format := range []orm.DataFormat{orm.FormatCSV, orm.FormatJSONLines, orm.FormatParquet} {
+		t.Run(format.String(), func(t *testing.T) {
+			users := prepareExportUsers(t)
+
+			var buf bytes.Buffer
+			cnt, err := testEngine.Table(new(ExportUser)).Where("age > ?", 10).Asc("id").Export(&buf, format)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 2, cnt)
+
+			_, err = testEngine.Where("1=1").Delete(new(ExportUser))
+			assert.NoError(t, err)
+
+			cnt, err = testEngine.ImportRows(bytes.NewReader(buf.Bytes()), format, new(ExportUser))
+			assert.NoError(t, err)
+			assert.EqualValues(t, 2, cnt)
+
+			var imported []ExportUser
+			assert.NoError(t, testEngine.Asc("id").Find(&imported))
+			if assert.Len(t, imported, 2) {
+				for i, user := range imported {
+					expected := users[i]
+					assert.EqualValues(t, expected.Id, user.Id)
+					assert.EqualValues(t, expected.Name, user.Name)
+					assert.EqualValues(t, expected.Age, user.Age)
+					assert.EqualValues(t, expected.Active, user.Active)
+					assert.EqualValues(t, expected.Score, user.Score)
+					assert.EqualValues(t, expected.Avatar, user.Avatar)
+					assert.EqualValues(t, expected.Nick, user.Nick)
+					assert.EqualValues(t, expected.Tags, user.Tags)
+					assert.True(t, expected.Created.Equal(user.Created))
+				}
+			}
+		})
+	}
+
+	prepareExportUsers(t)
+	var buf bytes.Buffer
+	cnt, err := testEngine.Export(&buf, orm.FormatCSV, new(ExportUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+	assert.EqualValues(t, 4, strings.Count(buf.String(), "\n"))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	_, err = session.Export(&bytes.Buffer{}, orm.FormatCSV)
+	assert.EqualValues(t, orm.ErrTableNotFound, err)
+}
+
+func TestImportRowsErrors(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(ExportUser))
+
+	_, err := testEngine.Insert(&ExportUser{Id: 5, Name: "existing"})
+	assert.NoError(t, err)
+
+	input := strings.Join([]string{
+		"id,name,age,active",
+		"1,a,10,true",
+		"2,b,abc,false",
+		"3,c",
+		"5,duplicate,1,true",
+		"4,d,,1",
+		"",
+	}, "\n")
+	cnt, err := testEngine.ImportRows(strings.NewReader(input), orm.FormatCSV, new(ExportUser))
+	assert.EqualValues(t, 2, cnt)
+	var importErrs orm.ImportErrors
+	if assert.True(t, errors.As(err, &importErrs)) && assert.Len(t, importErrs, 3) {
+		assert.EqualValues(t, 2, importErrs[0].Row)
+		assert.EqualValues(t, "age", importErrs[0].Column)
+		assert.EqualValues(t, 3, importErrs[1].Row)
+		assert.EqualValues(t, 4, importErrs[2].Row)
+	}
+
+	var user ExportUser
+	has, err := testEngine.ID(4).Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 0, user.Age)
+	assert.True(t, user.Active)
+
+	_, err = testEngine.ImportRows(strings.NewReader("id,unknown\n1,2\n"), orm.FormatCSV, new(ExportUser))
+	assert.Error(t, err)
+
+	cnt, err = testEngine.ImportRows(strings.NewReader("{\"id\": 6, \"name\": \"f\", \"tags\": [\"x\"]}\n{bad\n\n{\"id\": 7, \"nick\": null}\n"), orm.FormatJSONLines, new(ExportUser))
+	assert.EqualValues(t, 2, cnt)
+	if assert.True(t, errors.As(err, &importErrs)) && assert.Len(t, importErrs, 1) {
+		assert.EqualValues(t, 2, importErrs[0].Row)
+	}
+	var user6 ExportUser
+	has, err = testEngine.ID(6).Get(&user6)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, []string{"x"}, user6.Tags)
+}
+
+func TestImportRowsParquet(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(ExportUser))
+
+	// a file of another writer, with gzip pages, required columns and
+	// millisecond timestamps
+	type parquetUser struct {
+		Id      int64   `parquet:"name=id, type=INT64"`
+		Name    string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Age     int32   `parquet:"name=age, type=INT32"`
+		Nick    *string `parquet:"name=nick, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+		Created int64   `parquet:"name=created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	}
+	created := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	nick := "bob"
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(parquetUser), 1)
+	assert.NoError(t, err)
+	pw.CompressionType = parquet.CompressionCodec_GZIP
+	assert.NoError(t, pw.Write(parquetUser{Id: 1, Name: "alice", Age: 20, Created: created.UnixNano() / int64(time.Millisecond)}))
+	assert.NoError(t, pw.Write(parquetUser{Id: 2, Name: "bob", Age: 30, Nick: &nick}))
+	assert.NoError(t, pw.WriteStop())
+
+	cnt, err := testEngine.ImportRows(bytes.NewReader(buf.Bytes()), orm.FormatParquet, new(ExportUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	var users []ExportUser
+	assert.NoError(t, testEngine.Asc("id").Find(&users))
+	if assert.Len(t, users, 2) {
+		assert.EqualValues(t, "alice", users[0].Name)
+		assert.EqualValues(t, 20, users[0].Age)
+		assert.Nil(t, users[0].Nick)
+		assert.True(t, created.Equal(users[0].Created))
+		assert.EqualValues(t, "bob", users[1].Name)
+		assert.EqualValues(t, &nick, users[1].Nick)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/bhojpur/dbm/pkg/orm"
 	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
 	"github.com/bhojpur/dbm/pkg/orm/name"
+	"github.com/bhojpur/sql/pkg/builder"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -946,3 +947,81 @@ func TestFindAnonymousStruct(t *testing.T) {
 	assert.EqualValues(t, 1, findRes[0].Id)
 	assert.EqualValues(t, "xlw", findRes[0].Name)
 }
+
+func TestCompoundFind(t *testing.T) {
+	type CompoundNode struct {
+		Id       int64
+		ParentId int64
+		Name     string
+		Score    int
+	}
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(CompoundNode))
+	_, err := testEngine.Insert([]CompoundNode{
+		{Id: 1, ParentId: 0, Name: "root", Score: 10},
+		{Id: 2, ParentId: 1, Name: "child", Score: 80},
+		{Id: 3, ParentId: 2, Name: "grandchild", Score: 60},
+		{Id: 4, ParentId: 0, Name: "other", Score: 90},
+	})
+	assert.NoError(t, err)
+	tableName := testEngine.Quote(testEngine.TableName(new(CompoundNode), true))
+	ids := func(nodes []CompoundNode) []int64 {
+		var ids []int64
+		for _, node := range nodes {
+			ids = append(ids, node.Id)
+		}
+		return ids
+	}
+
+	var nodes []CompoundNode
+	err = testEngine.WithRecursive("subtree",
+		"SELECT * FROM "+tableName+" WHERE `id` = ? UNION ALL SELECT n.* FROM "+tableName+" n JOIN `subtree` s ON n.`parent_id` = s.`id`", 1).
+		Table("subtree").Asc("id").Find(&nodes)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{1, 2, 3}, ids(nodes))
+
+	nodes = nil
+	err = testEngine.With("high", builder.Select("*").From(testEngine.TableName(new(CompoundNode), true)).Where(builder.Gt{"score": 50})).
+		Table("high").Where("`parent_id` > ?", 0).Asc("id").Find(&nodes)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{2, 3}, ids(nodes))
+
+	type CompoundRank struct {
+		Id       int64
+		ParentId int64
+		Name     string
+		Score    int
+		Pos      int `orm:"<-"`
+	}
+	var ranks []CompoundRank
+	err = testEngine.Table(new(CompoundNode)).Over("pos", "ROW_NUMBER()", "`parent_id`", "`score` DESC").
+		Asc("id").Find(&ranks)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, len(ranks))
+	var positions []int
+	for _, rank := range ranks {
+		positions = append(positions, rank.Pos)
+	}
+	assert.EqualValues(t, []int{2, 1, 1, 1}, positions)
+
+	highScores := builder.Select("id", "parent_id", "name", "score").
+		From(testEngine.TableName(new(CompoundNode), true)).Where(builder.Gt{"score": 50})
+	nodes = nil
+	err = testEngine.Where("`parent_id` = ?", 0).Union(highScores).Asc("id").Find(&nodes)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{1, 2, 3, 4}, ids(nodes))
+
+	cnt, err := testEngine.Table(new(CompoundNode)).Where("`parent_id` = ?", 0).UnionAll(highScores).Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, cnt)
+
+	nodes = nil
+	err = testEngine.Where("`parent_id` = ?", 0).Intersect(highScores).Find(&nodes)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{4}, ids(nodes))
+
+	nodes = nil
+	err = testEngine.Where("`parent_id` = ?", 0).Except(highScores).Find(&nodes)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []int64{1}, ids(nodes))
+}
@@ -0,0 +1,186 @@
+package integration
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/internal/statement"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditHistory(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type AuditedUser struct {
+		Id        int64 `orm:"pk autoincr audit"`
+		Name      string
+		Age       int
+		DeletedAt time.Time `orm:"deleted"`
+	}
+	assertSync(t, new(AuditedUser))
+	// sync again, the history table is only altered
+	assertSync(t, new(AuditedUser))
+	exist, err := testEngine.IsTableExist("audited_user_history")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	ctx := ctxsvr.WithActor(context.Background(), "alice")
+	user := AuditedUser{Name: "a", Age: 20}
+	cnt, err := testEngine.Context(ctx).Insert(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	inserted := time.Now()
+
+	// the history has a second resolution
+	time.Sleep(time.Second)
+	cnt, err = testEngine.Context(ctx).ID(user.Id).Update(&AuditedUser{Name: "b"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	// nothing changed, nothing recorded
+	cnt, err = testEngine.ID(user.Id).Update(&AuditedUser{Name: "b"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	updated := time.Now()
+
+	time.Sleep(time.Second)
+	cnt, err = testEngine.ID(user.Id).Delete(new(AuditedUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	softDeleted := time.Now()
+
+	time.Sleep(time.Second)
+	cnt, err = testEngine.ID(user.Id).Unscoped().Delete(new(AuditedUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	results, err := testEngine.Table("audited_user_history").
+		Asc(schemasvr.HistoryID).QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, len(results))
+	var ops []string
+	for _, result := range results {
+		ops = append(ops, result[schemasvr.HistoryOp])
+	}
+	assert.EqualValues(t, []string{schemasvr.HistoryInsert, schemasvr.HistoryUpdate,
+		schemasvr.HistorySoftDelete, schemasvr.HistoryDelete}, ops)
+	assert.EqualValues(t, "alice", results[0][schemasvr.HistoryActor])
+	assert.EqualValues(t, "", results[0][schemasvr.HistoryBefore])
+	assert.EqualValues(t, "b", results[1]["name"])
+	assert.Contains(t, results[1][schemasvr.HistoryBefore], `"name":"a"`)
+	assert.EqualValues(t, "", results[2][schemasvr.HistoryActor])
+	assert.NotEmpty(t, results[2]["deleted_at"])
+
+	var u AuditedUser
+	has, err := testEngine.AsOf(inserted).ID(user.Id).Get(&u)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "a", u.Name)
+	assert.EqualValues(t, 20, u.Age)
+
+	var users []AuditedUser
+	assert.NoError(t, testEngine.AsOf(updated).Find(&users))
+	assert.EqualValues(t, 1, len(users))
+	assert.EqualValues(t, "b", users[0].Name)
+
+	cnt, err = testEngine.AsOf(softDeleted).Count(new(AuditedUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.AsOf(softDeleted).Unscoped().Count(new(AuditedUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.AsOf(time.Now()).Unscoped().Count(new(AuditedUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	type NotAuditedUser struct {
+		Id   int64
+		Name string
+	}
+	assertSync(t, new(NotAuditedUser))
+	_, err = testEngine.AsOf(time.Now()).Get(new(NotAuditedUser))
+	assert.ErrorIs(t, err, statement.ErrTableNotAudited)
+}
+
+func TestAuditHistoryTransaction(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type AuditedTx struct {
+		Id   int64 `orm:"pk autoincr history(audited_tx_log)"`
+		Name string
+	}
+	assertSync(t, new(AuditedTx))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	_, err := session.Insert(&[]AuditedTx{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+	_, err = session.Table(new(AuditedTx)).Where("name = ?", "a").Update(map[string]interface{}{"name": "c"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Rollback())
+
+	cnt, err := testEngine.Table("audited_tx_log").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	_, err = testEngine.Insert(&[]AuditedTx{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+	// the maps are audited when the table is given by the bean
+	_, err = testEngine.Table(new(AuditedTx)).Insert(map[string]interface{}{"id": 3, "name": "d"})
+	assert.NoError(t, err)
+	cnt, err = testEngine.Table(new(AuditedTx)).Where("name = ?", "a").Update(map[string]interface{}{"name": "c"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Table("audited_tx_log").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, cnt)
+}
+
+func TestAuditHistoryCompositeKey(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type AuditedPair struct {
+		First  string `orm:"pk varchar(10) audit"`
+		Second string `orm:"pk varchar(10)"`
+		Value  int
+	}
+	assertSync(t, new(AuditedPair))
+
+	_, err := testEngine.Insert(&[]AuditedPair{{"a", "bc", 1}, {"ab", "c", 2}})
+	assert.NoError(t, err)
+	// only the first row changed, its before image must not be the one of the
+	// second row whose key has the same characters
+	cnt, err := testEngine.Table(new(AuditedPair)).Where("value > ?", 0).Update(map[string]interface{}{"value": 2})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	results, err := testEngine.Table("audited_pair_history").
+		Where(schemasvr.HistoryOp+" = ?", schemasvr.HistoryUpdate).QueryString()
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(results)) {
+		assert.EqualValues(t, "a", results[0]["first"])
+		assert.EqualValues(t, "bc", results[0]["second"])
+		assert.Contains(t, results[0][schemasvr.HistoryBefore], `"second":"bc"`)
+		assert.Contains(t, results[0][schemasvr.HistoryBefore], `"value":1`)
+	}
+}
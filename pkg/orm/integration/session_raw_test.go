@@ -72,3 +72,11 @@ func TestExecTime(t *testing.T) {
 	assert.True(t, has)
 	assert.EqualValues(t, now.In(testEngine.GetTZLocation()).Format("2006-01-02 15:04:05"), uet.Created.Format("2006-01-02 15:04:05"))
 }
+
+func TestQueryShortSQL(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assert.NotPanics(t, func() {
+		_, err := testEngine.QueryString("SEL")
+		assert.Error(t, err)
+	})
+}
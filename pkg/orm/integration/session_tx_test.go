@@ -21,10 +21,16 @@ package integration
 // THE SOFTWARE.
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/bhojpur/dbm/pkg/orm"
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
 	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
 	"github.com/bhojpur/dbm/pkg/orm/name"
 	"github.com/stretchr/testify/assert"
@@ -157,3 +163,166 @@ func TestMultipleTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 0, len(ms))
 }
+
+type txAttemptHook struct {
+	mutex    sync.Mutex
+	attempts map[int]int
+}
+
+func (h *txAttemptHook) BeforeProcess(c *ctxsvr.ContextHook) (context.Context, error) {
+	return c.Ctx, nil
+}
+
+func (h *txAttemptHook) AfterProcess(c *ctxsvr.ContextHook) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if c.TxAttempt > 0 {
+		h.attempts[c.TxAttempt]++
+	}
+	return nil
+}
+
+func TestTransactionWithRetry(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(Userinfo))
+	engine := testEngine.(*orm.Engine)
+	hook := &txAttemptHook{attempts: make(map[int]int)}
+	engine.AddHook(hook)
+
+	errConflict := errors.New("conflict")
+	var calls int
+	var retried []int
+	opts := &orm.TxRetryOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Retryable:  func(err error) bool { return errors.Is(err, errConflict) },
+		OnRetry:    func(attempt int, err error) { retried = append(retried, attempt) },
+	}
+	res, err := engine.TransactionWithRetry(context.Background(), opts, func(session *orm.Session) (interface{}, error) {
+		calls++
+		if _, err := session.Insert(&Userinfo{Username: "retried"}); err != nil {
+			return nil, err
+		}
+		if calls < 3 {
+			return nil, fmt.Errorf("insert: %w", errConflict)
+		}
+		return calls, nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, res)
+	assert.EqualValues(t, []int{1, 2}, retried)
+
+	// the inserts of the failed attempts were rolled back
+	cnt, err := testEngine.Where("`username` = ?", "retried").Count(new(Userinfo))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	hook.mutex.Lock()
+	for attempt := 1; attempt <= 3; attempt++ {
+		assert.True(t, hook.attempts[attempt] > 0, "attempt %d", attempt)
+	}
+	hook.mutex.Unlock()
+
+	// the attempts are bounded
+	calls = 0
+	opts.MaxAttempts = 2
+	_, err = engine.TransactionWithRetry(context.Background(), opts, func(session *orm.Session) (interface{}, error) {
+		calls++
+		return nil, errConflict
+	})
+	assert.True(t, errors.Is(err, errConflict))
+	assert.EqualValues(t, 2, calls)
+
+	// the other errors are returned at once
+	calls = 0
+	errOther := errors.New("other")
+	_, err = engine.TransactionWithRetry(context.Background(), opts, func(session *orm.Session) (interface{}, error) {
+		calls++
+		return nil, errOther
+	})
+	assert.Equal(t, errOther, err)
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestSavepoint(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(Userinfo))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.Equal(t, orm.ErrNotInTransaction, session.Savepoint("sp1"))
+
+	assert.NoError(t, session.Begin())
+	_, err := session.Insert(&Userinfo{Username: "savepoint1"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Savepoint("sp1"))
+	_, err = session.Insert(&Userinfo{Username: "savepoint2"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.RollbackTo("sp1"))
+	assert.NoError(t, session.Release("sp1"))
+	assert.Error(t, session.Savepoint("sp1; DROP TABLE userinfo"))
+	assert.NoError(t, session.Commit())
+
+	cnt, err := testEngine.In("`username`", "savepoint1", "savepoint2").Count(new(Userinfo))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+}
+
+func TestNestedTransaction(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(Userinfo))
+
+	errInner := errors.New("inner")
+	// addUser is library code, it runs in its own transaction or nested in
+	// the transaction of the caller
+	addUser := func(db orm.Interface, name string, fail bool) error {
+		_, err := db.Transaction(func(session *orm.Session) (interface{}, error) {
+			if _, err := session.Insert(&Userinfo{Username: name}); err != nil {
+				return nil, err
+			}
+			if fail {
+				return nil, errInner
+			}
+			return nil, nil
+		})
+		return err
+	}
+
+	_, err := testEngine.Transaction(func(session *orm.Session) (interface{}, error) {
+		assert.NoError(t, addUser(session, "nested1", false))
+		assert.Equal(t, errInner, addUser(session, "nested2", true))
+		assert.True(t, session.IsInTx())
+		return nil, addUser(session, "nested3", false)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, errInner, addUser(testEngine, "nested4", true))
+
+	var users []Userinfo
+	assert.NoError(t, testEngine.In("`username`", "nested1", "nested2", "nested3", "nested4").
+		Asc("username").Find(&users))
+	if assert.Len(t, users, 2) {
+		assert.EqualValues(t, "nested1", users[0].Username)
+		assert.EqualValues(t, "nested3", users[1].Username)
+	}
+}
+
+func TestTransactionWithOptions(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	assertSync(t, new(Userinfo))
+
+	engine := testEngine.(*orm.Engine)
+	opts := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	_, err := engine.TransactionWithOptions(context.Background(), opts, func(session *orm.Session) (interface{}, error) {
+		assert.True(t, session.IsInTx())
+		return session.Insert(&Userinfo{Username: "isolated"})
+	})
+	assert.NoError(t, err)
+
+	session := testEngine.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.BeginTx(context.Background(), opts))
+	cnt, err := session.Where("`username` = ?", "isolated").Count(new(Userinfo))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.NoError(t, session.Commit())
+}
@@ -21,12 +21,14 @@ package integration
 // THE SOFTWARE.
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm"
+	"github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/internal/statement"
 	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
 	"github.com/bhojpur/dbm/pkg/orm/name"
@@ -228,6 +230,39 @@ func TestForUpdate(t *testing.T) {
 	assert.NoError(t, err)
 	wg.Wait()
 }
+
+func TestForUpdateSkipLocked(t *testing.T) {
+	if *ignoreSelectUpdate {
+		return
+	}
+	assert.NoError(t, setupForUpdate(testEngine))
+
+	session1 := testEngine.NewSession()
+	defer session1.Close()
+	assert.NoError(t, session1.Begin())
+	var locked ForUpdate
+	has, err := session1.Where("`id` = ?", 1).ForUpdate().Get(&locked)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	session2 := testEngine.NewSession()
+	defer session2.Close()
+	assert.NoError(t, session2.Begin())
+	var free []ForUpdate
+	err = session2.In("`id`", 1, 2).Asc("id").SkipLocked().Find(&free)
+	if errors.Is(err, dialect.ErrUnsupportedLockMode) {
+		// sqlite locks the whole database and could not skip rows
+		assert.NoError(t, session2.Rollback())
+		assert.NoError(t, session1.Rollback())
+		return
+	}
+	assert.NoError(t, err)
+	if assert.Len(t, free, 1) {
+		assert.EqualValues(t, 2, free[0].Id)
+	}
+	assert.NoError(t, session2.Rollback())
+	assert.NoError(t, session1.Rollback())
+}
 func TestWithIn(t *testing.T) {
 	type temp3 struct {
 		Id   int64  `orm:"Id pk autoincr"`
@@ -1238,3 +1273,79 @@ func TestNilFromDB(t *testing.T) {
 	assert.NotNil(t, tt4.Field1)
 	assert.NotNil(t, tt4.Field1.cb)
 }
+
+func TestTrackedUpdate(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type TrackedUser struct {
+		Id      int64
+		Name    string
+		Age     int
+		Active  bool
+		Version int `orm:"version"`
+	}
+	assertSync(t, new(TrackedUser))
+	_, err := testEngine.Insert([]TrackedUser{
+		{Name: "a", Age: 30, Active: true},
+		{Name: "b", Age: 40, Active: true},
+	})
+	assert.NoError(t, err)
+
+	session := testEngine.NewSession().Tracked()
+	defer session.Close()
+
+	var user TrackedUser
+	has, err := session.Where("name = ?", "a").Get(&user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	changes, err := session.Changes(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(changes))
+	cnt, err := session.Update(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	// the zero values are written too, and only the row of the bean
+	user.Age = 0
+	user.Active = false
+	changes, err = session.Changes(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(changes))
+	assert.EqualValues(t, "age", changes[0].Column)
+	assert.EqualValues(t, 30, changes[0].Old)
+	assert.EqualValues(t, 0, changes[0].New)
+	assert.EqualValues(t, "active", changes[1].Column)
+	cnt, err = session.Update(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 2, user.Version)
+	changes, err = session.Changes(&user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, len(changes))
+
+	var users []TrackedUser
+	assert.NoError(t, testEngine.Asc("id").Find(&users))
+	assert.EqualValues(t, 2, len(users))
+	assert.EqualValues(t, 0, users[0].Age)
+	assert.False(t, users[0].Active)
+	assert.EqualValues(t, 40, users[1].Age)
+	assert.True(t, users[1].Active)
+
+	// the beans of Find are tracked too
+	users = nil
+	assert.NoError(t, session.Asc("id").Find(&users))
+	users[1].Name = ""
+	cnt, err = session.Update(&users[1])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	var reloaded TrackedUser
+	has, err = testEngine.ID(users[1].Id).Get(&reloaded)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "", reloaded.Name)
+
+	_, err = testEngine.NewSession().Changes(&user)
+	assert.True(t, errors.Is(err, orm.ErrNotTracked))
+	_, err = session.Changes(&TrackedUser{Id: 100})
+	assert.True(t, errors.Is(err, orm.ErrNotTracked))
+}
@@ -21,12 +21,16 @@ package integration
 // THE SOFTWARE.
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
+	"github.com/bhojpur/dbm/pkg/orm/internal/statement"
 	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
 	"github.com/bhojpur/dbm/pkg/orm/name"
 	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
@@ -1188,3 +1192,228 @@ func TestIndexes(t *testing.T) {
 	assert.EqualValues(t, slice1, slice2)
 	assert.EqualValues(t, 3, len(tables[0].Indexes))
 }
+func TestTagEncrypted(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type TagEncrypted struct {
+		Id         int64
+		Token      string            `orm:"encrypted"`
+		NationalId string            `orm:"encrypted(deterministic) index"`
+		Age        int               `orm:"encrypted"`
+		Attrs      map[string]string `orm:"json encrypted"`
+	}
+	keys := map[string][]byte{"k1": []byte("0123456789abcdef")}
+	ring, err := convert.NewKeyRing("k1", keys)
+	assert.NoError(t, err)
+	testEngine.SetKeyProvider(ring)
+	defer testEngine.SetKeyProvider(nil)
+	assertSync(t, new(TagEncrypted))
+
+	cnt, err := testEngine.Insert(&TagEncrypted{
+		Token:      "token1",
+		NationalId: "A123",
+		Age:        42,
+		Attrs:      map[string]string{"city": "Pune"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the database sees the ciphertexts with the key IDs
+	results, err := testEngine.Table(new(TagEncrypted)).QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(results))
+	for _, col := range []string{"token", "national_id", "age", "attrs"} {
+		assert.True(t, strings.HasPrefix(results[0][col], "k1:"), col)
+	}
+	assert.NotContains(t, results[0]["attrs"], "Pune")
+
+	var s TagEncrypted
+	has, err := testEngine.ID(1).Get(&s)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "token1", s.Token)
+	assert.EqualValues(t, "A123", s.NationalId)
+	assert.EqualValues(t, 42, s.Age)
+	assert.EqualValues(t, "Pune", s.Attrs["city"])
+
+	cnt, err = testEngine.ID(1).Update(&TagEncrypted{Token: "token2"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// rotate the key, the rows encrypted with k1 are still read and matched
+	keys["k2"] = []byte("fedcba9876543210")
+	ring, err = convert.NewKeyRing("k2", keys)
+	assert.NoError(t, err)
+	testEngine.SetKeyProvider(ring)
+	cnt, err = testEngine.Insert(&TagEncrypted{Token: "token3", NationalId: "A123"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var ss []TagEncrypted
+	assert.NoError(t, testEngine.Asc("id").Find(&ss, &TagEncrypted{NationalId: "A123"}))
+	assert.EqualValues(t, 2, len(ss))
+	assert.EqualValues(t, "token2", ss[0].Token)
+	assert.EqualValues(t, "token3", ss[1].Token)
+
+	cnt, err = testEngine.Table(new(TagEncrypted)).EncryptedEq("national_id", "A123").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Table(new(TagEncrypted)).EncryptedEq("national_id", "B456").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	// the randomized ciphertexts cannot be compared
+	_, err = testEngine.Get(&TagEncrypted{Token: "token2"})
+	assert.ErrorIs(t, err, statement.ErrEncryptedCond)
+
+	// the values of the maps are encrypted when the table is given by the bean
+	cnt, err = testEngine.Table(new(TagEncrypted)).ID(1).Update(map[string]interface{}{"token": "token4"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Table(new(TagEncrypted)).Insert(map[string]interface{}{"id": 3, "token": "token5", "national_id": "B456"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Table(new(TagEncrypted)).Insert([]map[string]interface{}{
+		{"id": 4, "token": "token6", "national_id": "B456"},
+		{"id": 5, "token": "token7", "national_id": "C789"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	results, err = testEngine.Table(new(TagEncrypted)).Where("id IN (1, 3, 4, 5)").QueryString()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, len(results))
+	for _, result := range results {
+		assert.True(t, strings.HasPrefix(result["token"], "k2:"), result["token"])
+	}
+	ss = nil
+	assert.NoError(t, testEngine.In("id", 1, 3, 4, 5).Asc("id").Find(&ss))
+	if assert.EqualValues(t, 4, len(ss)) {
+		assert.EqualValues(t, "token4", ss[0].Token)
+		assert.EqualValues(t, "token5", ss[1].Token)
+		assert.EqualValues(t, "B456", ss[1].NationalId)
+		assert.EqualValues(t, "token6", ss[2].Token)
+		assert.EqualValues(t, "C789", ss[3].NationalId)
+	}
+	cnt, err = testEngine.Table(new(TagEncrypted)).EncryptedEq("national_id", "B456").Count()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	testEngine.SetKeyProvider(nil)
+	_, err = testEngine.ID(1).Get(new(TagEncrypted))
+	assert.ErrorIs(t, err, statement.ErrNoKeyProvider)
+}
+func TestTagTenant(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type TagTenant struct {
+		Id       int64
+		TenantId int64 `orm:"tenant index"`
+		Name     string
+	}
+	assertSync(t, new(TagTenant))
+
+	ctx1 := ctxsvr.WithTenant(context.Background(), int64(1))
+	ctx2 := ctxsvr.WithTenant(context.Background(), int64(2))
+
+	// the tenant is not forgotten
+	_, err := testEngine.Insert(&TagTenant{Name: "a"})
+	assert.ErrorIs(t, err, statement.ErrNoTenant)
+	_, err = testEngine.Count(new(TagTenant))
+	assert.ErrorIs(t, err, statement.ErrNoTenant)
+
+	var a = TagTenant{Name: "a"}
+	cnt, err := testEngine.Context(ctx1).Insert(&a)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, 1, a.TenantId)
+	cnt, err = testEngine.Context(ctx2).Insert([]TagTenant{{Name: "b"}, {Name: "c"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Context(ctx2).Table(new(TagTenant)).Insert(map[string]interface{}{"name": "d"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	_, err = testEngine.Context(ctx1).Insert(&TagTenant{Name: "e", TenantId: 2})
+	assert.ErrorIs(t, err, statement.ErrTenantMismatch)
+
+	var names []string
+	assert.NoError(t, testEngine.Context(ctx2).Table(new(TagTenant)).Asc("id").Cols("name").Find(&names))
+	assert.EqualValues(t, []string{"b", "c", "d"}, names)
+	has, err := testEngine.Context(ctx2).ID(a.Id).Get(new(TagTenant))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// the rows of the other tenants are neither updated nor deleted
+	cnt, err = testEngine.Context(ctx1).Where("name = ?", "b").Update(&TagTenant{Name: "x"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Context(ctx2).Where("name = ?", "b").Update(&TagTenant{Name: "x", TenantId: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	cnt, err = testEngine.Context(ctx1).Where("name = ?", "c").Delete(new(TagTenant))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+	cnt, err = testEngine.Context(ctx2).Where("name = ?", "c").Delete(new(TagTenant))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the admin jobs see all the tenants
+	var all []TagTenant
+	assert.NoError(t, testEngine.AllTenants().Asc("id").Find(&all))
+	assert.EqualValues(t, 3, len(all))
+	assert.EqualValues(t, 1, all[0].TenantId)
+	assert.EqualValues(t, "x", all[1].Name)
+	assert.EqualValues(t, 2, all[1].TenantId)
+	cnt, err = testEngine.AllTenants().Where("tenant_id = ?", 2).Count(new(TagTenant))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
+
+func TestTagTenantJoin(t *testing.T) {
+	assert.NoError(t, PrepareEngine())
+	type TagTenantOwner struct {
+		Id       int64
+		TenantId int64 `orm:"tenant index"`
+		Name     string
+	}
+	type TagTenantItem struct {
+		Id      int64
+		OwnerId int64
+	}
+	assertSync(t, new(TagTenantOwner), new(TagTenantItem))
+
+	_, err := testEngine.AllTenants().Insert([]TagTenantOwner{{Id: 1, TenantId: 1, Name: "a"}, {Id: 2, TenantId: 2, Name: "b"}})
+	assert.NoError(t, err)
+	_, err = testEngine.Insert([]TagTenantItem{{OwnerId: 1}, {OwnerId: 2}, {OwnerId: 3}})
+	assert.NoError(t, err)
+
+	ctx1 := ctxsvr.WithTenant(context.Background(), int64(1))
+	cnt, err := testEngine.Context(ctx1).
+		Join("INNER", new(TagTenantOwner), "tag_tenant_item.owner_id = tag_tenant_owner.id").
+		Count(new(TagTenantItem))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the tables joined by name are scoped too
+	cnt, err = testEngine.Context(ctx1).
+		Join("INNER", "tag_tenant_owner", "tag_tenant_item.owner_id = tag_tenant_owner.id").
+		Count(new(TagTenantItem))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	// the items without an owner are kept by an outer join
+	var items []TagTenantItem
+	assert.NoError(t, testEngine.Context(ctx1).
+		Join("LEFT", []interface{}{new(TagTenantOwner), "o"}, "tag_tenant_item.owner_id = o.id").
+		Asc("tag_tenant_item.id").Cols("tag_tenant_item.id", "tag_tenant_item.owner_id").Find(&items))
+	if assert.Len(t, items, 2) {
+		assert.EqualValues(t, 1, items[0].OwnerId)
+		assert.EqualValues(t, 3, items[1].OwnerId)
+	}
+
+	_, err = testEngine.Join("INNER", new(TagTenantOwner), "tag_tenant_item.owner_id = tag_tenant_owner.id").
+		Count(new(TagTenantItem))
+	assert.ErrorIs(t, err, statement.ErrNoTenant)
+	cnt, err = testEngine.AllTenants().
+		Join("INNER", new(TagTenantOwner), "tag_tenant_item.owner_id = tag_tenant_owner.id").
+		Count(new(TagTenantItem))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
@@ -23,11 +23,13 @@ package orm
 import (
 	"context"
 	"database/sql"
+	"io"
 	"reflect"
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm/cache"
 	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
 	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/log"
 	"github.com/bhojpur/dbm/pkg/orm/name"
@@ -38,6 +40,8 @@ import (
 type Interface interface {
 	AllCols() *Session
 	Alias(alias string) *Session
+	AllTenants() *Session
+	AsOf(t time.Time) *Session
 	Asc(colNames ...string) *Session
 	BufferSize(size int) *Session
 	Cols(columns ...string) *Session
@@ -50,15 +54,18 @@ type Interface interface {
 	Distinct(columns ...string) *Session
 	DropIndexes(bean interface{}) error
 	Exec(sqlOrArgs ...interface{}) (sql.Result, error)
+	Except(query interface{}, args ...interface{}) *Session
 	Exist(bean ...interface{}) (bool, error)
 	Find(interface{}, ...interface{}) error
 	FindAndCount(interface{}, ...interface{}) (int64, error)
 	Get(...interface{}) (bool, error)
 	GroupBy(keys string) *Session
 	ID(interface{}) *Session
+	ImportRows(r io.Reader, format DataFormat, bean interface{}) (int64, error)
 	In(string, ...interface{}) *Session
 	Incr(column string, arg ...interface{}) *Session
 	Insert(...interface{}) (int64, error)
+	Intersect(query interface{}, args ...interface{}) *Session
 	InsertOne(interface{}) (int64, error)
 	IsTableEmpty(bean interface{}) (bool, error)
 	IsTableExist(beanOrTableName interface{}) (bool, error)
@@ -71,11 +78,13 @@ type Interface interface {
 	Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *Session
 	Omit(columns ...string) *Session
 	OrderBy(order string) *Session
+	Over(column, function, partitionBy, orderBy string) *Session
 	Ping() error
 	Query(sqlOrArgs ...interface{}) (resultsSlice []map[string][]byte, err error)
 	QueryInterface(sqlOrArgs ...interface{}) ([]map[string]interface{}, error)
 	QueryString(sqlOrArgs ...interface{}) ([]map[string]string, error)
 	Rows(bean interface{}) (*Rows, error)
+	Scopes(names ...string) *Session
 	SetExpr(string, interface{}) *Session
 	Select(string) *Session
 	SQL(interface{}, ...interface{}) *Session
@@ -84,10 +93,16 @@ type Interface interface {
 	Sums(bean interface{}, colNames ...string) ([]float64, error)
 	SumsInt(bean interface{}, colNames ...string) ([]int64, error)
 	Table(tableNameOrBean interface{}) *Session
+	Transaction(f func(*Session) (interface{}, error)) (interface{}, error)
+	Union(query interface{}, args ...interface{}) *Session
+	UnionAll(query interface{}, args ...interface{}) *Session
 	Unscoped() *Session
 	Update(bean interface{}, condiBeans ...interface{}) (int64, error)
 	UseBool(...string) *Session
 	Where(interface{}, ...interface{}) *Session
+	With(name string, query interface{}, args ...interface{}) *Session
+	WithRecursive(name string, query interface{}, args ...interface{}) *Session
+	WithoutScopes(names ...string) *Session
 }
 
 // EngineInterface defines the interface which Engine, EngineGroup will implementate.
@@ -104,6 +119,7 @@ type EngineInterface interface {
 	DriverName() string
 	DropTables(...interface{}) error
 	DumpAllToFile(fp string, tp ...schemasvr.DBType) error
+	Export(w io.Writer, format DataFormat, bean interface{}) (int64, error)
 	GetCacher(string) cache.Cacher
 	GetColumnMapper() name.Mapper
 	GetDefaultCacher() cache.Cacher
@@ -121,6 +137,7 @@ type EngineInterface interface {
 	SetColumnMapper(name.Mapper)
 	SetTagIdentifier(string)
 	SetDefaultCacher(cache.Cacher)
+	SetKeyProvider(convert.KeyProvider)
 	SetLogger(logger interface{})
 	SetLogLevel(log.LogLevel)
 	SetMapper(name.Mapper)
@@ -136,6 +153,7 @@ type EngineInterface interface {
 	Sync(...interface{}) error
 	Sync2(...interface{}) error
 	StoreEngine(storeEngine string) *Session
+	Subscribe(table string, handler EventHandler, types ...EventType) func()
 	TableInfo(bean interface{}) (*schemasvr.Table, error)
 	TableName(interface{}, ...bool) string
 	UnMapType(reflect.Type)
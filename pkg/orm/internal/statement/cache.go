@@ -36,6 +36,18 @@ func (statement *Statement) ConvertIDSQL(sqlStr string) string {
 			return ""
 		}
 		colstrs := statement.joinColumns(cols, false)
+		if statement.JoinStr != "" {
+			// qualify the primary keys since the joined tables may have the same columns
+			var prefix = statement.TableName()
+			if statement.TableAlias != "" {
+				prefix = statement.TableAlias
+			}
+			var colnames = make([]string, len(cols))
+			for i, col := range cols {
+				colnames[i] = statement.quote(prefix) + "." + statement.quote(col.Name)
+			}
+			colstrs = strings.Join(colnames, ", ")
+		}
 		sqls := utils.SplitNNoCase(sqlStr, " from ", 2)
 		if len(sqls) != 2 {
 			return ""
@@ -68,7 +80,7 @@ func (statement *Statement) ConvertUpdateSQL(sqlStr string) (string, string) {
 	var whereStr = sqls[1]
 	// TODO: for postgres only, if any other database?
 	var paraStr string
-	if statement.dialect.URI().DBType == schema.POSTGRES {
+	if statement.dialect.URI().DBType.IsPostgres() {
 		paraStr = "$"
 	} else if statement.dialect.URI().DBType == schema.MSSQL {
 		paraStr = ":"
@@ -0,0 +1,243 @@
+package statement
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+type cte struct {
+	name      string
+	sql       string
+	args      []interface{}
+	recursive bool
+}
+
+type setOp struct {
+	op   string
+	sql  string
+	args []interface{}
+}
+
+type overColumn struct {
+	name string
+	expr string
+}
+
+// With adds a common table expression to the query, the name could carry the
+// column list as "name (col1, col2)" and the query is a SQL string or a builder
+func (statement *Statement) With(name string, query interface{}, args ...interface{}) *Statement {
+	return statement.addCTE(name, false, query, args...)
+}
+
+// WithRecursive adds a common table expression which could refer to itself
+func (statement *Statement) WithRecursive(name string, query interface{}, args ...interface{}) *Statement {
+	return statement.addCTE(name, true, query, args...)
+}
+
+func (statement *Statement) addCTE(name string, recursive bool, query interface{}, args ...interface{}) *Statement {
+	sqlStr, sqlArgs, err := statement.subQuerySQL(query, args...)
+	if err != nil {
+		statement.LastError = err
+		return statement
+	}
+	statement.ctes = append(statement.ctes, cte{
+		name:      name,
+		sql:       sqlStr,
+		args:      sqlArgs,
+		recursive: recursive,
+	})
+	return statement
+}
+
+// Union combines the rows of the query with the rows of another one without
+// the duplicates
+func (statement *Statement) Union(query interface{}, args ...interface{}) *Statement {
+	return statement.addSetOp("UNION", query, args...)
+}
+
+// UnionAll combines the rows of the query with all the rows of another one
+func (statement *Statement) UnionAll(query interface{}, args ...interface{}) *Statement {
+	return statement.addSetOp("UNION ALL", query, args...)
+}
+
+// Intersect keeps the rows of the query which another one returns too
+func (statement *Statement) Intersect(query interface{}, args ...interface{}) *Statement {
+	return statement.addSetOp("INTERSECT", query, args...)
+}
+
+// Except keeps the rows of the query which another one does not return
+func (statement *Statement) Except(query interface{}, args ...interface{}) *Statement {
+	return statement.addSetOp("EXCEPT", query, args...)
+}
+
+func (statement *Statement) addSetOp(op string, query interface{}, args ...interface{}) *Statement {
+	sqlStr, sqlArgs, err := statement.subQuerySQL(query, args...)
+	if err != nil {
+		statement.LastError = err
+		return statement
+	}
+	statement.setOps = append(statement.setOps, setOp{
+		op:   op,
+		sql:  sqlStr,
+		args: sqlArgs,
+	})
+	return statement
+}
+
+// Over selects the result of a window function as the column, partitionBy and
+// orderBy are the comma separated expressions of the window and could be empty
+func (statement *Statement) Over(column, function, partitionBy, orderBy string) *Statement {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s OVER (", function)
+	if partitionBy != "" {
+		fmt.Fprint(&buf, "PARTITION BY ", partitionBy)
+	}
+	if orderBy != "" {
+		if partitionBy != "" {
+			buf.WriteString(" ")
+		}
+		fmt.Fprint(&buf, "ORDER BY ", orderBy)
+	}
+	buf.WriteString(")")
+	statement.overColumns = append(statement.overColumns, overColumn{
+		name: column,
+		expr: statement.ReplaceQuote(buf.String()),
+	})
+	return statement
+}
+
+// IsCompound returns true if the query has common table expressions, window
+// functions or set operations
+func (statement *Statement) IsCompound() bool {
+	return len(statement.ctes) > 0 || len(statement.setOps) > 0 || len(statement.overColumns) > 0
+}
+
+func (statement *Statement) subQuerySQL(query interface{}, args ...interface{}) (string, []interface{}, error) {
+	switch tp := query.(type) {
+	case string:
+		return statement.ReplaceQuote(tp), args, nil
+	case builder.Builder:
+		sqlStr, sqlArgs, err := tp.ToSQL()
+		if err != nil {
+			return "", nil, err
+		}
+		return statement.ReplaceQuote(sqlStr), sqlArgs, nil
+	case *builder.Builder:
+		sqlStr, sqlArgs, err := tp.ToSQL()
+		if err != nil {
+			return "", nil, err
+		}
+		return statement.ReplaceQuote(sqlStr), sqlArgs, nil
+	default:
+		return "", nil, fmt.Errorf("Unsupported sub query type %T", query)
+	}
+}
+
+// isOverColumn returns true if the column is the result of a window function
+func (statement *Statement) isOverColumn(name string) bool {
+	for _, over := range statement.overColumns {
+		if strings.EqualFold(over.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// genOverColumnStr appends the window function columns to the selected columns
+func (statement *Statement) genOverColumnStr(columnStr string) string {
+	if len(statement.overColumns) == 0 {
+		return columnStr
+	}
+	var buf strings.Builder
+	if columnStr == "*" && statement.dialect.URI().DBType == schemasvr.ORACLE {
+		// oracle does not accept a bare star beside other columns
+		tableName := statement.TableAlias
+		if tableName == "" {
+			tableName = statement.TableName()
+		}
+		columnStr = statement.quote(tableName) + ".*"
+	}
+	buf.WriteString(columnStr)
+	for _, over := range statement.overColumns {
+		if buf.Len() > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%s AS %s", over.expr, statement.quote(over.name))
+	}
+	return buf.String()
+}
+
+// genSetOpSQL writes the set operations after the main query
+func (statement *Statement) genSetOpSQL(buf *strings.Builder) []interface{} {
+	var args []interface{}
+	for _, op := range statement.setOps {
+		keyword := op.op
+		if keyword == "EXCEPT" && statement.dialect.URI().DBType == schemasvr.ORACLE {
+			keyword = "MINUS"
+		}
+		fmt.Fprintf(buf, " %s %s", keyword, op.sql)
+		args = append(args, op.args...)
+	}
+	return args
+}
+
+// genWithSQL prefixes the common table expressions to the query
+func (statement *Statement) genWithSQL(sqlStr string, args []interface{}) (string, []interface{}) {
+	if len(statement.ctes) == 0 {
+		return sqlStr, args
+	}
+	var recursive bool
+	for _, c := range statement.ctes {
+		recursive = recursive || c.recursive
+	}
+	var buf strings.Builder
+	buf.WriteString("WITH ")
+	if recursive {
+		switch statement.dialect.URI().DBType {
+		case schemasvr.MSSQL, schemasvr.ORACLE, schemasvr.DAMENG:
+			// a table expression referring to itself is recursive
+		default:
+			buf.WriteString("RECURSIVE ")
+		}
+	}
+	var withArgs = make([]interface{}, 0, len(args))
+	for i, c := range statement.ctes {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if strings.Contains(c.name, "(") {
+			buf.WriteString(statement.ReplaceQuote(c.name))
+		} else {
+			buf.WriteString(statement.quote(c.name))
+		}
+		fmt.Fprintf(&buf, " AS (%s)", c.sql)
+		withArgs = append(withArgs, c.args...)
+	}
+	buf.WriteString(" ")
+	buf.WriteString(sqlStr)
+	return buf.String(), append(withArgs, args...)
+}
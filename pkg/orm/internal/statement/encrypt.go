@@ -0,0 +1,169 @@
+package statement
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	"github.com/bhojpur/dbm/pkg/orm/convert"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+var (
+	// ErrNoKeyProvider the engine has no key provider for the encrypted columns
+	ErrNoKeyProvider = errors.New("No key provider for the encrypted column")
+	// ErrEncryptedCond the randomized ciphertexts cannot be compared
+	ErrEncryptedCond = errors.New("Encrypted column is not deterministic")
+)
+
+func (statement *Statement) plaintext(col *schemasvr.Column, v interface{}) ([]byte, error) {
+	if statement.Encryptor == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoKeyProvider, col.Name)
+	}
+	if t, ok := v.(time.Time); ok {
+		return []byte(t.Format("2006-01-02 15:04:05.999999999")), nil
+	}
+	if bs, ok := convert.AsBytes(v); ok {
+		return bs, nil
+	}
+	return []byte(convert.AsString(v)), nil
+}
+
+// EncryptValue encrypts the value of the encrypted column for putting into database
+func (statement *Statement) EncryptValue(col *schemasvr.Column, v interface{}) (interface{}, error) {
+	if !col.IsEncrypted || v == nil {
+		return v, nil
+	}
+	plaintext, err := statement.plaintext(col, v)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := statement.Encryptor.Encrypt(col.Name, plaintext, col.IsDeterministic)
+	if err != nil {
+		return nil, err
+	}
+	if col.SQLType.IsBlob() {
+		return []byte(ciphertext), nil
+	}
+	return ciphertext, nil
+}
+
+// EncryptMapValues encrypts the values of the map columns which are encrypted
+// columns of the table, the values are copied rather than changed
+func (statement *Statement) EncryptMapValues(columns []string, values []interface{}) ([]interface{}, error) {
+	if statement.RefTable == nil {
+		return values, nil
+	}
+	var encrypted []interface{}
+	for i, colName := range columns {
+		col := statement.RefTable.GetColumn(colName)
+		if col == nil || !col.IsEncrypted {
+			continue
+		}
+		if encrypted == nil {
+			encrypted = append(make([]interface{}, 0, len(values)), values...)
+		}
+		v, err := statement.EncryptValue(col, values[i])
+		if err != nil {
+			return nil, err
+		}
+		encrypted[i] = v
+	}
+	if encrypted == nil {
+		return values, nil
+	}
+	return encrypted, nil
+}
+
+// DecryptValue decrypts the scanned value of the encrypted column
+func (statement *Statement) DecryptValue(col *schemasvr.Column, v interface{}) (interface{}, error) {
+	if statement.Encryptor == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoKeyProvider, col.Name)
+	}
+	ciphertext, ok := convert.AsBytes(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %#v as bytes", v)
+	}
+	if ciphertext == nil {
+		return nil, nil
+	}
+	plaintext, err := statement.Encryptor.Decrypt(col.Name, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", col.Name, err)
+	}
+	if col.SQLType.IsBlob() {
+		return plaintext, nil
+	}
+	return string(plaintext), nil
+}
+
+// EncryptedCond returns the condition comparing the deterministic encrypted
+// column with the value, which matches the values encrypted with any key
+func (statement *Statement) EncryptedCond(colName string, col *schemasvr.Column, v interface{}) (builder.Cond, error) {
+	if !col.IsDeterministic {
+		return nil, fmt.Errorf("%w: %s", ErrEncryptedCond, col.Name)
+	}
+	if v == nil {
+		return builder.IsNull{colName}, nil
+	}
+	plaintext, err := statement.plaintext(col, v)
+	if err != nil {
+		return nil, err
+	}
+	ciphertexts, err := statement.Encryptor.EncryptAll(col.Name, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	var args = make([]interface{}, 0, len(ciphertexts))
+	for _, ciphertext := range ciphertexts {
+		if col.SQLType.IsBlob() {
+			args = append(args, []byte(ciphertext))
+		} else {
+			args = append(args, ciphertext)
+		}
+	}
+	if len(args) == 1 {
+		return builder.Eq{colName: args[0]}, nil
+	}
+	return builder.In(colName, args...), nil
+}
+
+// EncryptedEq generates the condition column = arg on the column tagged
+// encrypted(deterministic), the column may be prefixed by the table
+func (statement *Statement) EncryptedEq(column string, arg interface{}) *Statement {
+	var name = column
+	if idx := strings.LastIndexByte(name, '.'); idx > -1 {
+		name = name[idx+1:]
+	}
+	col := &schemasvr.Column{Name: name, IsEncrypted: true, IsDeterministic: true}
+	cond, err := statement.EncryptedCond(statement.quote(column), col, arg)
+	if err != nil {
+		statement.LastError = err
+		return statement
+	}
+	statement.cond = statement.cond.And(cond)
+	return statement
+}
@@ -164,7 +164,7 @@ func (statement *Statement) GenInsertSQL(colNames []string, args []interface{})
 			}
 		}
 	}
-	if len(table.AutoIncrement) > 0 && statement.dialect.URI().DBType == schema.POSTGRES {
+	if len(table.AutoIncrement) > 0 && statement.dialect.URI().DBType.IsPostgres() {
 		if _, err := buf.WriteString(" RETURNING "); err != nil {
 			return "", nil, err
 		}
@@ -182,6 +182,10 @@ func (statement *Statement) GenInsertMapSQL(columns []string, args []interface{}
 		exprs     = statement.ExprColumns
 		tableName = statement.TableName()
 	)
+	args, err := statement.EncryptMapValues(columns, args)
+	if err != nil {
+		return "", nil, err
+	}
 	if _, err := buf.WriteString(fmt.Sprintf("INSERT INTO %s (", statement.quote(tableName))); err != nil {
 		return "", nil, err
 	}
@@ -251,6 +255,10 @@ func (statement *Statement) GenInsertMultipleMapSQL(columns []string, argss [][]
 		return "", nil, err
 	}
 	for i, args := range argss {
+		args, err := statement.EncryptMapValues(columns, args)
+		if err != nil {
+			return "", nil, err
+		}
 		if _, err := buf.WriteString("("); err != nil {
 			return "", nil, err
 		}
@@ -26,6 +26,7 @@ import (
 	"reflect"
 	"strings"
 
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/schema"
 	"github.com/bhojpur/sql/pkg/builder"
 )
@@ -65,6 +66,7 @@ func (statement *Statement) GenQuerySQL(sqlOrArgs ...interface{}) (string, []int
 		if columnStr == "" {
 			columnStr = "*"
 		}
+		columnStr = statement.genOverColumnStr(columnStr)
 	}
 	if err := statement.ProcessIDParam(); err != nil {
 		return "", nil, err
@@ -79,6 +81,7 @@ func (statement *Statement) GenQuerySQL(sqlOrArgs ...interface{}) (string, []int
 	if len(args)*2 == qs {
 		args = append(args, args...)
 	}
+	sqlStr, args = statement.genWithSQL(sqlStr, args)
 	return sqlStr, args, nil
 }
 
@@ -107,7 +110,8 @@ func (statement *Statement) GenSumSQL(bean interface{}, columns ...string) (stri
 	if err != nil {
 		return "", nil, err
 	}
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	sqlStr, args := statement.genWithSQL(sqlStr, append(statement.joinArgs, condArgs...))
+	return sqlStr, args, nil
 }
 
 // GenGetSQL generates Get SQL
@@ -146,6 +150,9 @@ func (statement *Statement) GenGetSQL(bean interface{}) (string, []interface{},
 	if len(columnStr) == 0 {
 		columnStr = "*"
 	}
+	if len(statement.SelectStr) == 0 {
+		columnStr = statement.genOverColumnStr(columnStr)
+	}
 	if isStruct {
 		if err := statement.mergeConds(bean); err != nil {
 			return "", nil, err
@@ -159,7 +166,8 @@ func (statement *Statement) GenGetSQL(bean interface{}) (string, []interface{},
 	if err != nil {
 		return "", nil, err
 	}
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	sqlStr, args := statement.genWithSQL(sqlStr, append(statement.joinArgs, condArgs...))
+	return sqlStr, args, nil
 }
 
 // GenCountSQL generates the SQL for counting
@@ -188,7 +196,20 @@ func (statement *Statement) GenCountSQL(beans ...interface{}) (string, []interfa
 		}
 	}
 	var subQuerySelect string
-	if statement.GroupByStr != "" {
+	if len(statement.setOps) > 0 {
+		// the rows of all the combined queries are counted
+		subQuerySelect = statement.SelectStr
+		if subQuerySelect == "" {
+			subQuerySelect = statement.ColumnStr()
+		}
+		if subQuerySelect == "" {
+			subQuerySelect = statement.genColumnStr()
+		}
+		if subQuerySelect == "" {
+			subQuerySelect = "*"
+		}
+		selectSQL = "count(*)"
+	} else if statement.GroupByStr != "" {
 		subQuerySelect = statement.GroupByStr
 	} else {
 		subQuerySelect = selectSQL
@@ -197,28 +218,41 @@ func (statement *Statement) GenCountSQL(beans ...interface{}) (string, []interfa
 	if err != nil {
 		return "", nil, err
 	}
-	if statement.GroupByStr != "" {
+	if statement.GroupByStr != "" || len(statement.setOps) > 0 {
 		sqlStr = fmt.Sprintf("SELECT %s FROM (%s) sub", selectSQL, sqlStr)
 	}
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	sqlStr, args := statement.genWithSQL(sqlStr, append(statement.joinArgs, condArgs...))
+	return sqlStr, args, nil
 }
-func (statement *Statement) fromBuilder() *strings.Builder {
+func (statement *Statement) fromBuilder(lockHint string) *strings.Builder {
 	var builder strings.Builder
 	var quote = statement.quote
 	var dialect = statement.dialect
+	var tableAlias = statement.TableAlias
 	builder.WriteString(" FROM ")
-	if dialect.URI().DBType == schema.MSSQL && strings.Contains(statement.TableName(), "..") {
+	if statement.asOf != nil {
+		// the history table stands in for the table under its name
+		builder.WriteString(quote(statement.RefTable.HistoryName(statement.TableName())))
+		if tableAlias == "" {
+			tableName := statement.TableName()
+			tableAlias = tableName[strings.LastIndex(tableName, ".")+1:]
+		}
+	} else if dialect.URI().DBType == schema.MSSQL && strings.Contains(statement.TableName(), "..") {
 		builder.WriteString(statement.TableName())
 	} else {
 		builder.WriteString(quote(statement.TableName()))
 	}
-	if statement.TableAlias != "" {
+	if tableAlias != "" {
 		if dialect.URI().DBType == schema.ORACLE {
 			builder.WriteString(" ")
 		} else {
 			builder.WriteString(" AS ")
 		}
-		builder.WriteString(quote(statement.TableAlias))
+		builder.WriteString(quote(tableAlias))
+	}
+	if lockHint != "" {
+		builder.WriteString(" ")
+		builder.WriteString(lockHint)
 	}
 	if statement.JoinStr != "" {
 		builder.WriteString(" ")
@@ -230,13 +264,36 @@ func (statement *Statement) genSelectSQL(columnStr string, needLimit, needOrderB
 	var (
 		distinct                  string
 		dialect                   = statement.dialect
-		fromStr                   = statement.fromBuilder().String()
+		lockHint                  string
 		top, mssqlCondi, whereStr string
 	)
+	if hinter, ok := dialect.(dialectsvr.LockHinter); ok && statement.IsForUpdate {
+		var err error
+		if lockHint, err = hinter.LockHint(&statement.forUpdateOpts); err != nil {
+			return "", nil, err
+		}
+	}
+	var cond = statement.cond
+	if statement.asOf != nil {
+		if statement.RefTable == nil || !statement.RefTable.Audited {
+			return "", nil, ErrTableNotAudited
+		}
+		cond = cond.And(statement.asOfCond(statement.RefTable.HistoryName(statement.TableName())))
+	}
+	scopeCond, err := statement.CondScopes(statement.RefTable, len(statement.JoinStr) > 0)
+	if err != nil {
+		return "", nil, err
+	}
+	joinedCond, err := statement.condJoinedTenants()
+	if err != nil {
+		return "", nil, err
+	}
+	cond = cond.And(scopeCond, joinedCond)
+	fromStr := statement.fromBuilder(lockHint).String()
 	if statement.IsDistinct && !strings.HasPrefix(columnStr, "count") {
 		distinct = "DISTINCT "
 	}
-	condSQL, condArgs, err := statement.GenCondSQL(statement.cond)
+	condSQL, condArgs, err := statement.GenCondSQL(cond)
 	if err != nil {
 		return "", nil, err
 	}
@@ -244,7 +301,14 @@ func (statement *Statement) genSelectSQL(columnStr string, needLimit, needOrderB
 		whereStr = fmt.Sprintf(" WHERE %s", condSQL)
 	}
 	pLimitN := statement.LimitN
-	if dialect.URI().DBType == schema.MSSQL {
+	// TOP would limit the first of the combined queries only
+	var wrapTop = dialect.URI().DBType == schema.MSSQL && len(statement.setOps) > 0 &&
+		(pLimitN != nil || statement.Start > 0)
+	if wrapTop {
+		if statement.Start > 0 {
+			return "", nil, errors.New("Unsupported query offset with set operations")
+		}
+	} else if dialect.URI().DBType == schema.MSSQL {
 		if pLimitN != nil {
 			LimitNValue := *pLimitN
 			top = fmt.Sprintf("TOP %d ", LimitNValue)
@@ -301,6 +365,12 @@ func (statement *Statement) genSelectSQL(columnStr string, needLimit, needOrderB
 	if statement.HavingStr != "" {
 		fmt.Fprint(&buf, " ", statement.HavingStr)
 	}
+	condArgs = append(condArgs, statement.genSetOpSQL(&buf)...)
+	if wrapTop {
+		inner := buf.String()
+		buf.Reset()
+		fmt.Fprintf(&buf, "SELECT TOP %d * FROM (%s) sub", *pLimitN, inner)
+	}
 	if needOrderBy && statement.OrderStr != "" {
 		fmt.Fprint(&buf, " ORDER BY ", statement.OrderStr)
 	}
@@ -319,17 +389,22 @@ func (statement *Statement) genSelectSQL(columnStr string, needLimit, needOrderB
 			if pLimitN != nil {
 				oldString := buf.String()
 				buf.Reset()
-				rawColStr := columnStr
-				if rawColStr == "*" {
-					rawColStr = "at.*"
+				rawColStr, outerColStr := columnStr, columnStr
+				if rawColStr == "*" || len(statement.setOps) > 0 || len(statement.overColumns) > 0 {
+					// the combined or computed columns are only selected by the inner query
+					rawColStr, outerColStr = "at.*", "*"
 				}
 				fmt.Fprintf(&buf, "SELECT %v FROM (SELECT %v,ROWNUM RN FROM (%v) at WHERE ROWNUM <= %d) aat WHERE RN > %d",
-					columnStr, rawColStr, oldString, statement.Start+*pLimitN, statement.Start)
+					outerColStr, rawColStr, oldString, statement.Start+*pLimitN, statement.Start)
 			}
 		}
 	}
 	if statement.IsForUpdate {
-		return dialect.ForUpdateSQL(buf.String()), condArgs, nil
+		sqlStr, err := dialect.ForUpdateSQL(buf.String(), &statement.forUpdateOpts)
+		if err != nil {
+			return "", nil, err
+		}
+		return sqlStr, condArgs, nil
 	}
 	return buf.String(), condArgs, nil
 }
@@ -365,8 +440,12 @@ func (statement *Statement) GenExistSQL(bean ...interface{}) (string, []interfac
 		if len(statement.JoinStr) > 0 {
 			joinStr = statement.JoinStr
 		}
-		if statement.Conds().IsValid() {
-			condSQL, condArgs, err := statement.GenCondSQL(statement.Conds())
+		joinedCond, err := statement.condJoinedTenants()
+		if err != nil {
+			return "", nil, err
+		}
+		if cond := statement.Conds().And(joinedCond); cond.IsValid() {
+			condSQL, condArgs, err := statement.GenCondSQL(cond)
 			if err != nil {
 				return "", nil, err
 			}
@@ -433,6 +512,7 @@ func (statement *Statement) GenFindSQL(autoCond builder.Cond) (string, []interfa
 		if columnStr == "" {
 			columnStr = "*"
 		}
+		columnStr = statement.genOverColumnStr(columnStr)
 	}
 	statement.cond = statement.cond.And(autoCond)
 	sqlStr, condArgs, err := statement.genSelectSQL(columnStr, true, true)
@@ -445,5 +525,6 @@ func (statement *Statement) GenFindSQL(autoCond builder.Cond) (string, []interfa
 	if len(args)*2 == qs {
 		args = append(args, args...)
 	}
+	sqlStr, args = statement.genWithSQL(sqlStr, args)
 	return sqlStr, args, nil
 }
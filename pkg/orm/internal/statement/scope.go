@@ -0,0 +1,98 @@
+package statement
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// ErrUnknownScope the table has no scope with the name
+var ErrUnknownScope = errors.New("Unknown scope")
+
+// Scopes applies the named scopes of the table to the statement
+func (statement *Statement) Scopes(names ...string) *Statement {
+	statement.scopes = append(statement.scopes, names...)
+	return statement
+}
+
+// WithoutScopes disables the named default scopes of the table, all of them if
+// no name is given
+func (statement *Statement) WithoutScopes(names ...string) *Statement {
+	if len(names) == 0 {
+		statement.noDefaultScopes = true
+		return statement
+	}
+	if statement.noScopes == nil {
+		statement.noScopes = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		statement.noScopes[name] = true
+	}
+	return statement
+}
+
+// CondScopes returns the conditions of the tenant, the default scopes and the
+// named scopes of the table
+func (statement *Statement) CondScopes(table *schemasvr.Table, addedTableName bool) (builder.Cond, error) {
+	cond, err := statement.CondTenant(table, addedTableName)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		if len(statement.scopes) > 0 {
+			return nil, fmt.Errorf("%w: %s needs a table", ErrUnknownScope, statement.scopes[0])
+		}
+		return cond, nil
+	}
+	var names []string
+	if !statement.noDefaultScopes {
+		for _, name := range table.DefaultScopes() {
+			if !statement.noScopes[name] {
+				names = append(names, name)
+			}
+		}
+	}
+	names = append(names, statement.scopes...)
+	if len(names) == 0 {
+		return cond, nil
+	}
+	var (
+		scopes  = table.Scopes()
+		applied = make(map[string]bool, len(names))
+	)
+	for _, name := range names {
+		if applied[name] {
+			continue
+		}
+		scope, ok := scopes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s of %s", ErrUnknownScope, name, table.Name)
+		}
+		cond = cond.And(scope())
+		applied[name] = true
+	}
+	return cond, nil
+}
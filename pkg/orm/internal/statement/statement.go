@@ -48,6 +48,8 @@ var (
 	ErrUnSupportedType = errors.New("Unsupported type error")
 	// ErrTableNotFound table not found error
 	ErrTableNotFound = errors.New("Table not found")
+	// ErrTableNotAudited the table has no history to query as of a time
+	ErrTableNotAudited = errors.New("Table is not audited")
 )
 
 // Statement save all the sql info for executing SQL
@@ -62,6 +64,7 @@ type Statement struct {
 	OrderStr        string
 	JoinStr         string
 	joinArgs        []interface{}
+	joinedTables    []joinedTable
 	GroupByStr      string
 	HavingStr       string
 	SelectStr       string
@@ -79,10 +82,19 @@ type Statement struct {
 	NoAutoCondition bool
 	IsDistinct      bool
 	IsForUpdate     bool
+	forUpdateOpts   dialectsvr.ForUpdateOptions
+	asOf            interface{}
 	TableAlias      string
 	allUseBool      bool
 	CheckVersion    bool
 	unscoped        bool
+	allTenants      bool
+	scopes          []string
+	noScopes        map[string]bool
+	noDefaultScopes bool
+	ctes            []cte
+	setOps          []setOp
+	overColumns     []overColumn
 	ColumnMap       columnMap
 	OmitColumnMap   columnMap
 	MustColumnMap   map[string]bool
@@ -93,6 +105,8 @@ type Statement struct {
 	cond            builder.Cond
 	BufferSize      int
 	Context         ctxsvr.ContextCache
+	Encryptor       *convert.Encryptor // encrypts the encrypted columns, nil if the engine has no key provider
+	TenantID        interface{}        // the tenant the tables with a tenant column are scoped to
 	LastError       error
 }
 
@@ -119,11 +133,23 @@ func (statement *Statement) GenRawSQL() string {
 
 // GenCondSQL generates condition SQL
 func (statement *Statement) GenCondSQL(condOrBuilder interface{}) (string, []interface{}, error) {
-	condSQL, condArgs, err := builder.ToSQL(condOrBuilder)
-	if err != nil {
+	cond, ok := condOrBuilder.(builder.Cond)
+	if !ok {
+		condSQL, condArgs, err := builder.ToSQL(condOrBuilder)
+		if err != nil {
+			return "", nil, err
+		}
+		return statement.ReplaceQuote(condSQL), condArgs, nil
+	}
+	if cond == nil || !cond.IsValid() {
+		return "", nil, nil
+	}
+	// the writer carries the dialect for the conditions rendered per database
+	w := dialectsvr.NewCondWriter(statement.dialect)
+	if err := cond.WriteTo(w); err != nil {
 		return "", nil, err
 	}
-	return statement.ReplaceQuote(condSQL), condArgs, nil
+	return statement.ReplaceQuote(w.String()), w.Args(), nil
 }
 
 // ReplaceQuote replace sql key words with quote
@@ -149,6 +175,7 @@ func (statement *Statement) Reset() {
 	statement.UseCascade = true
 	statement.JoinStr = ""
 	statement.joinArgs = make([]interface{}, 0)
+	statement.joinedTables = nil
 	statement.GroupByStr = ""
 	statement.HavingStr = ""
 	statement.ColumnMap = columnMap{}
@@ -163,6 +190,8 @@ func (statement *Statement) Reset() {
 	statement.NoAutoCondition = false
 	statement.IsDistinct = false
 	statement.IsForUpdate = false
+	statement.forUpdateOpts = dialectsvr.ForUpdateOptions{}
+	statement.asOf = nil
 	statement.TableAlias = ""
 	statement.SelectStr = ""
 	statement.allUseBool = false
@@ -171,6 +200,13 @@ func (statement *Statement) Reset() {
 	statement.NullableMap = make(map[string]bool)
 	statement.CheckVersion = true
 	statement.unscoped = false
+	statement.allTenants = false
+	statement.scopes = nil
+	statement.noScopes = nil
+	statement.noDefaultScopes = false
+	statement.ctes = nil
+	statement.setOps = nil
+	statement.overColumns = nil
 	statement.IncrColumns = exprParams{}
 	statement.DecrColumns = exprParams{}
 	statement.ExprColumns = exprParams{}
@@ -371,6 +407,52 @@ func (statement *Statement) Distinct(columns ...string) *Statement {
 // ForUpdate generates "SELECT ... FOR UPDATE" statement
 func (statement *Statement) ForUpdate() *Statement {
 	statement.IsForUpdate = true
+	statement.forUpdateOpts.Share = false
+	return statement
+}
+
+// ForShare generates "SELECT ... FOR SHARE" statement
+func (statement *Statement) ForShare() *Statement {
+	statement.IsForUpdate = true
+	statement.forUpdateOpts.Share = true
+	return statement
+}
+
+// NoWait makes the row locks fail at once when a row is locked
+func (statement *Statement) NoWait() *Statement {
+	statement.IsForUpdate = true
+	statement.forUpdateOpts.NoWait = true
+	return statement
+}
+
+// SkipLocked makes the row locks leave out the locked rows
+func (statement *Statement) SkipLocked() *Statement {
+	statement.IsForUpdate = true
+	statement.forUpdateOpts.SkipLocked = true
+	return statement
+}
+
+// AsOf queries the history of the audited table as it was at changedAt, the
+// time formatted for the history table
+func (statement *Statement) AsOf(changedAt interface{}) *Statement {
+	statement.asOf = changedAt
+	return statement
+}
+
+// asOfCond returns the condition selecting the latest images of the rows not
+// deleted at the time of AsOf from the history table
+func (statement *Statement) asOfCond(history string) builder.Cond {
+	quote := statement.quote
+	return builder.Expr(fmt.Sprintf("%s IN (SELECT MAX(%s) FROM %s WHERE %s <= ? GROUP BY %s) AND %s <> ?",
+		quote(schemasvr.HistoryID), quote(schemasvr.HistoryID), quote(history),
+		quote(schemasvr.HistoryChangedAt), strings.Join(statement.dialect.Quoter().Strings(statement.RefTable.PrimaryKeys), ","),
+		quote(schemasvr.HistoryOp)), statement.asOf, schemasvr.HistoryDelete)
+}
+
+// LockOf locks only the rows of the tables of a join
+func (statement *Statement) LockOf(tables ...string) *Statement {
+	statement.IsForUpdate = true
+	statement.forUpdateOpts.Of = append(statement.forUpdateOpts.Of, tables...)
 	return statement
 }
 
@@ -566,6 +648,7 @@ func (statement *Statement) Join(joinOP string, tablename interface{}, condition
 			tbName = statement.ReplaceQuote(tbName)
 		}
 		fmt.Fprintf(&buf, "%s ON %v", tbName, statement.ReplaceQuote(condition))
+		statement.addJoinedTable(joinOP, tablename)
 	}
 	statement.JoinStr = buf.String()
 	statement.joinArgs = append(statement.joinArgs, args...)
@@ -590,6 +673,18 @@ func (statement *Statement) SetUnscoped() *Statement {
 	return statement
 }
 
+// HasColumnRules returns true if the updated columns are chosen by Cols,
+// MustCols, AllCols or Omit
+func (statement *Statement) HasColumnRules() bool {
+	return len(statement.ColumnMap) > 0 || len(statement.OmitColumnMap) > 0 ||
+		len(statement.MustColumnMap) > 0 || statement.useAllCols
+}
+
+// HasConds returns true if the statement has conditions or an ID
+func (statement *Statement) HasConds() bool {
+	return statement.idParam != nil || (statement.cond != nil && statement.cond.IsValid())
+}
+
 // GetUnscoped return true if it's unscoped
 func (statement *Statement) GetUnscoped() bool {
 	return statement.unscoped
@@ -607,7 +702,7 @@ func (statement *Statement) genColumnStr() string {
 		if len(statement.ColumnMap) > 0 && !statement.ColumnMap.Contain(col.Name) {
 			continue
 		}
-		if col.MapType == schemasvr.ONLYTODB {
+		if col.MapType == schemasvr.ONLYTODB || statement.isOverColumn(col.Name) {
 			continue
 		}
 		if buf.Len() != 0 {
@@ -874,6 +969,14 @@ func (statement *Statement) buildConds2(table *schemasvr.Table, bean interface{}
 		if !ok {
 			continue
 		}
+		if col.IsEncrypted {
+			cond, err := statement.EncryptedCond(colName, col, val)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+			continue
+		}
 		conds = append(conds, builder.Eq{colName: val})
 	}
 	return builder.And(conds...), nil
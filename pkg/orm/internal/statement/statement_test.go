@@ -21,6 +21,7 @@ package statement
 // THE SOFTWARE.
 
 import (
+	"errors"
 	"os"
 	"reflect"
 	"strings"
@@ -103,6 +104,20 @@ func TestConvertSQLOrArgs(t *testing.T) {
 	_, _, err = statement.convertSQLOrArgs(args...)
 	assert.NoError(t, err)
 }
+func TestConvertIDSQLJoin(t *testing.T) {
+	statement, err := createTestStatement()
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SELECT `ID` FROM `TestTable` WHERE `ID` > 1",
+		statement.ConvertIDSQL("SELECT * FROM `TestTable` WHERE `ID` > 1"))
+
+	statement.JoinStr = "INNER JOIN `Parent` ON `Parent`.`ID` = `TestTable`.`ParentID`"
+	assert.EqualValues(t, "SELECT `TestTable`.`ID` FROM `TestTable` INNER JOIN `Parent` ON `Parent`.`ID` = `TestTable`.`ParentID`",
+		statement.ConvertIDSQL("SELECT * FROM `TestTable` INNER JOIN `Parent` ON `Parent`.`ID` = `TestTable`.`ParentID`"))
+
+	statement.Alias("t")
+	assert.EqualValues(t, "SELECT `t`.`ID` FROM `TestTable` AS `t` INNER JOIN `Parent` ON `Parent`.`ID` = `t`.`ParentID`",
+		statement.ConvertIDSQL("SELECT * FROM `TestTable` AS `t` INNER JOIN `Parent` ON `Parent`.`ID` = `t`.`ParentID`"))
+}
 func BenchmarkGetFlagForColumnWithICKey_ContainsKey(b *testing.B) {
 	b.StopTimer()
 	mapCols := make(map[string]bool)
@@ -197,3 +212,115 @@ func BenchmarkColumnsStringGeneration(b *testing.B) {
 		}
 	}
 }
+
+func TestForUpdateSQL(t *testing.T) {
+	tests := []struct {
+		dbType   schema.DBType
+		lock     func(*Statement)
+		expected string
+		err      bool
+	}{
+		{schema.POSTGRES, func(s *Statement) { s.ForUpdate() }, `SELECT * FROM "TestTable" FOR UPDATE`, false},
+		{schema.POSTGRES, func(s *Statement) { s.SkipLocked() }, `SELECT * FROM "TestTable" FOR UPDATE SKIP LOCKED`, false},
+		{schema.POSTGRES, func(s *Statement) { s.ForShare().NoWait() }, `SELECT * FROM "TestTable" FOR SHARE NOWAIT`, false},
+		{schema.POSTGRES, func(s *Statement) { s.LockOf("TestTable").SkipLocked() }, `SELECT * FROM "TestTable" FOR UPDATE OF "TestTable" SKIP LOCKED`, false},
+		{schema.POSTGRES, func(s *Statement) { s.NoWait().SkipLocked() }, "", true},
+		{schema.MYSQL, func(s *Statement) { s.ForShare() }, "SELECT * FROM `TestTable` LOCK IN SHARE MODE", false},
+		{schema.MYSQL, func(s *Statement) { s.ForShare().SkipLocked() }, "SELECT * FROM `TestTable` FOR SHARE SKIP LOCKED", false},
+		{schema.MSSQL, func(s *Statement) { s.ForUpdate() }, "SELECT * FROM [TestTable] WITH (UPDLOCK, ROWLOCK)", false},
+		{schema.MSSQL, func(s *Statement) { s.SkipLocked() }, "SELECT * FROM [TestTable] WITH (UPDLOCK, ROWLOCK, READPAST)", false},
+		{schema.MSSQL, func(s *Statement) { s.LockOf("TestTable") }, "", true},
+		{schema.ORACLE, func(s *Statement) { s.NoWait() }, `SELECT * FROM "TestTable" FOR UPDATE NOWAIT`, false},
+		{schema.ORACLE, func(s *Statement) { s.ForShare() }, "", true},
+		{schema.SQLITE, func(s *Statement) { s.ForShare() }, "SELECT * FROM `TestTable`", false},
+		{schema.SQLITE, func(s *Statement) { s.SkipLocked() }, "", true},
+	}
+	for _, test := range tests {
+		dialect := dialectsvr.QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&dialectsvr.URI{DBType: test.dbType}))
+		parser := tags.NewParser("orm", dialect, name.SnakeMapper{}, name.SnakeMapper{}, cache.NewManager())
+		statement := NewStatement(dialect, parser, time.Local)
+		assert.NoError(t, statement.SetRefValue(reflect.ValueOf(TestType{})))
+		test.lock(statement)
+		sqlStr, _, err := statement.genSelectSQL("*", false, false)
+		if test.err {
+			assert.True(t, errors.Is(err, dialectsvr.ErrUnsupportedLockMode), "%s %v", test.dbType, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.EqualValues(t, test.expected, sqlStr)
+	}
+}
+
+func TestLimitRowsCond(t *testing.T) {
+	tests := []struct {
+		dbType   schema.DBType
+		expected string
+	}{
+		{schema.POSTGRES, `ctid IN (SELECT ctid FROM "TestTable" ORDER BY id LIMIT 2)`},
+		{schema.YUGABYTE, `ybctid IN (SELECT ybctid FROM "TestTable" ORDER BY id LIMIT 2)`},
+		{schema.SQLITE, "rowid IN (SELECT rowid FROM `TestTable` ORDER BY id LIMIT 2)"},
+		{schema.COCKROACH, ""},
+		{schema.MYSQL, ""},
+	}
+	for _, test := range tests {
+		dialect := dialectsvr.QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&dialectsvr.URI{DBType: test.dbType}))
+		parser := tags.NewParser("orm", dialect, name.SnakeMapper{}, name.SnakeMapper{}, cache.NewManager())
+		statement := NewStatement(dialect, parser, time.Local)
+		cond, ok := statement.LimitRowsCond(dialect.Quoter().Quote("TestTable"), " ORDER BY id LIMIT 2")
+		assert.EqualValues(t, test.expected != "", ok, test.dbType)
+		assert.EqualValues(t, test.expected, cond, test.dbType)
+	}
+}
+
+func TestCompoundQuerySQL(t *testing.T) {
+	var tests = []struct {
+		dbType   schema.DBType
+		query    func(s *Statement)
+		expected string
+		args     []interface{}
+	}{
+		{schema.SQLITE, func(s *Statement) { s.With("t", "SELECT ID FROM TestTable WHERE Code1 = ?", 1) },
+			"WITH `t` AS (SELECT ID FROM TestTable WHERE Code1 = ?) SELECT `ID` FROM `TestTable` WHERE (Code2 = ?)", []interface{}{1, 2}},
+		{schema.POSTGRES, func(s *Statement) { s.WithRecursive("t (n)", "SELECT 1 UNION ALL SELECT n+1 FROM t WHERE n < ?", 3) },
+			`WITH RECURSIVE t (n) AS (SELECT 1 UNION ALL SELECT n+1 FROM t WHERE n < ?) SELECT "ID" FROM "TestTable" WHERE (Code2 = ?)`, []interface{}{3, 2}},
+		{schema.MSSQL, func(s *Statement) { s.WithRecursive("t (n)", "SELECT 1") },
+			"WITH t (n) AS (SELECT 1) SELECT [ID] FROM [TestTable] WHERE (Code2 = ?)", []interface{}{2}},
+		{schema.SQLITE, func(s *Statement) { s.Union("SELECT ID FROM Other WHERE Code1 = ?", 1).OrderBy("ID") },
+			"SELECT `ID` FROM `TestTable` WHERE (Code2 = ?) UNION SELECT ID FROM Other WHERE Code1 = ? ORDER BY ID", []interface{}{2, 1}},
+		{schema.ORACLE, func(s *Statement) { s.Except("SELECT ID FROM Other") },
+			`SELECT "ID" FROM "TestTable" WHERE (Code2 = ?) MINUS SELECT ID FROM Other`, []interface{}{2}},
+		{schema.SQLITE, func(s *Statement) { s.Over("Rank", "ROW_NUMBER()", "ParentID", "Code1 DESC") },
+			"SELECT `ID`, ROW_NUMBER() OVER (PARTITION BY ParentID ORDER BY Code1 DESC) AS `Rank` FROM `TestTable` WHERE (Code2 = ?)", []interface{}{2}},
+		{schema.MSSQL, func(s *Statement) { s.Union("SELECT ID FROM Other").OrderBy("ID").Limit(5) },
+			"SELECT TOP 5 * FROM (SELECT [ID] FROM [TestTable] WHERE (Code2 = ?) UNION SELECT ID FROM Other) sub ORDER BY ID", []interface{}{2}},
+		{schema.ORACLE, func(s *Statement) { s.Over("Rank", "ROW_NUMBER()", "ParentID", "Code1 DESC").Limit(5, 10) },
+			`SELECT * FROM (SELECT at.*,ROWNUM RN FROM (SELECT "ID", ROW_NUMBER() OVER (PARTITION BY ParentID ORDER BY Code1 DESC) AS "Rank" FROM "TestTable" WHERE (Code2 = ?)) at WHERE ROWNUM <= 15) aat WHERE RN > 10`, []interface{}{2}},
+		{schema.ORACLE, func(s *Statement) { s.Union("SELECT ID FROM Other").Limit(5) },
+			`SELECT * FROM (SELECT at.*,ROWNUM RN FROM (SELECT "ID" FROM "TestTable" WHERE (Code2 = ?) UNION SELECT ID FROM Other) at WHERE ROWNUM <= 5) aat WHERE RN > 0`, []interface{}{2}},
+	}
+	for _, test := range tests {
+		dialect := dialectsvr.QueryDialect(test.dbType)
+		assert.NoError(t, dialect.Init(&dialectsvr.URI{DBType: test.dbType}))
+		parser := tags.NewParser("orm", dialect, name.SnakeMapper{}, name.SnakeMapper{}, cache.NewManager())
+		statement := NewStatement(dialect, parser, time.Local)
+		assert.NoError(t, statement.SetRefValue(reflect.ValueOf(TestType{})))
+		statement.Cols("ID").Where("Code2 = ?", 2)
+		test.query(statement)
+		sqlStr, args, err := statement.GenQuerySQL()
+		assert.NoError(t, err)
+		assert.EqualValues(t, test.expected, sqlStr, test.dbType)
+		assert.EqualValues(t, test.args, args, test.dbType)
+	}
+
+	// SQL Server skips rows of a single table only
+	dialect := dialectsvr.QueryDialect(schema.MSSQL)
+	assert.NoError(t, dialect.Init(&dialectsvr.URI{DBType: schema.MSSQL}))
+	parser := tags.NewParser("orm", dialect, name.SnakeMapper{}, name.SnakeMapper{}, cache.NewManager())
+	statement := NewStatement(dialect, parser, time.Local)
+	assert.NoError(t, statement.SetRefValue(reflect.ValueOf(TestType{})))
+	statement.Cols("ID").Union("SELECT ID FROM Other").Limit(5, 10)
+	_, _, err := statement.GenQuerySQL()
+	assert.Error(t, err)
+}
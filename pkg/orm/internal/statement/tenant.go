@@ -0,0 +1,223 @@
+package statement
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	"github.com/bhojpur/dbm/pkg/orm/convert"
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+var (
+	// ErrNoTenant the table is scoped to a tenant but the context has none
+	ErrNoTenant = errors.New("No tenant in the context")
+	// ErrTenantMismatch the row belongs to another tenant than the one of the context
+	ErrTenantMismatch = errors.New("Row belongs to another tenant")
+)
+
+// AllTenants disables the tenant scoping of the statement, for the jobs working
+// on the rows of all the tenants
+func (statement *Statement) AllTenants() *Statement {
+	statement.allTenants = true
+	return statement
+}
+
+// GetAllTenants returns true if the tenant scoping is disabled
+func (statement *Statement) GetAllTenants() bool {
+	return statement.allTenants
+}
+
+// tenantColumn returns the tenant column of the table if the statement is scoped
+func (statement *Statement) tenantColumn(table *schemasvr.Table) (*schemasvr.Column, error) {
+	if table == nil || statement.allTenants {
+		return nil, nil
+	}
+	col := table.TenantColumn()
+	if col == nil {
+		return nil, nil
+	}
+	if statement.TenantID == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoTenant, table.Name)
+	}
+	return col, nil
+}
+
+// CondTenant returns the condition scoping the table to the tenant, the column
+// is prefixed by the table or its alias if addedTableName
+func (statement *Statement) CondTenant(table *schemasvr.Table, addedTableName bool) (builder.Cond, error) {
+	col, err := statement.tenantColumn(table)
+	if err != nil || col == nil {
+		return builder.NewCond(), err
+	}
+	colName := statement.quote(col.Name)
+	if addedTableName {
+		var nm = statement.TableName()
+		if len(statement.TableAlias) > 0 {
+			nm = statement.TableAlias
+		}
+		colName = statement.quote(nm) + "." + colName
+	}
+	return builder.Eq{colName: statement.TenantID}, nil
+}
+
+// joinedTable is a table scoped to a tenant joined by Join
+type joinedTable struct {
+	table *schemasvr.Table
+	name  string // the alias or the name of the table prefixing its columns
+	outer bool
+}
+
+// addJoinedTable records the joined table if it is scoped to a tenant, the
+// tables joined by name are looked up in the parsed structs
+func (statement *Statement) addJoinedTable(joinOP string, tablename interface{}) {
+	var (
+		bean        = tablename
+		name, alias string
+	)
+	switch t := tablename.(type) {
+	case string:
+		fields := strings.Fields(t)
+		if len(fields) == 0 || utils.IsSubQuery(t) {
+			return
+		}
+		name, bean = fields[0], nil
+		if len(fields) > 1 {
+			alias = fields[len(fields)-1]
+		}
+	case []string:
+		if len(t) == 0 {
+			return
+		}
+		name, bean = t[0], nil
+		if len(t) > 1 {
+			alias = t[1]
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			return
+		}
+		bean = t[0]
+		if s, ok := t[0].(string); ok {
+			name, bean = s, nil
+		}
+		if len(t) > 1 {
+			alias = fmt.Sprint(t[1])
+		}
+	}
+	var table *schemasvr.Table
+	if bean != nil {
+		v := reflect.Indirect(reflect.ValueOf(bean))
+		if v.Kind() != reflect.Struct {
+			return
+		}
+		var err error
+		if table, err = statement.tagParser.ParseWithCache(v); err != nil {
+			return
+		}
+		name = dialectsvr.FullTableName(statement.dialect, statement.tagParser.GetTableMapper(), bean, true)
+	} else {
+		name = schemasvr.CommonQuoter.Trim(statement.dialect.Quoter().Trim(name))
+		fields := strings.Split(name, ".")
+		table = statement.tagParser.CachedTable(fields[len(fields)-1])
+	}
+	if table == nil || table.TenantColumn() == nil {
+		return
+	}
+	if alias != "" {
+		name = schemasvr.CommonQuoter.Trim(statement.dialect.Quoter().Trim(alias))
+	}
+	op := strings.ToUpper(joinOP)
+	statement.joinedTables = append(statement.joinedTables, joinedTable{
+		table: table,
+		name:  name,
+		outer: strings.Contains(op, "LEFT") || strings.Contains(op, "FULL"),
+	})
+}
+
+// condJoinedTenants returns the conditions scoping the joined tables to the
+// tenant, the rows an outer join has no match for are kept
+func (statement *Statement) condJoinedTenants() (builder.Cond, error) {
+	cond := builder.NewCond()
+	for _, joined := range statement.joinedTables {
+		col, err := statement.tenantColumn(joined.table)
+		if err != nil || col == nil {
+			return builder.NewCond(), err
+		}
+		colName := statement.quote(joined.name) + "." + statement.quote(col.Name)
+		if joined.outer {
+			cond = cond.And(builder.Or(builder.Eq{colName: statement.TenantID}, builder.IsNull{colName}))
+		} else {
+			cond = cond.And(builder.Eq{colName: statement.TenantID})
+		}
+	}
+	return cond, nil
+}
+
+// SetTenantValue sets the tenant column of the bean to be inserted to the
+// tenant, the beans of another tenant are refused
+func (statement *Statement) SetTenantValue(col *schemasvr.Column, fieldValue reflect.Value) error {
+	if !col.IsTenant {
+		return nil
+	}
+	if _, err := statement.tenantColumn(statement.RefTable); err != nil || statement.allTenants {
+		return err
+	}
+	if utils.IsValueZero(fieldValue) {
+		return convert.AssignValue(fieldValue.Addr(), statement.TenantID)
+	}
+	if fmt.Sprint(fieldValue.Interface()) != fmt.Sprint(statement.TenantID) {
+		return fmt.Errorf("%w: %v", ErrTenantMismatch, fieldValue.Interface())
+	}
+	return nil
+}
+
+// TenantMapValues adds the tenant to the rows of a map insert, the rows of
+// another tenant are refused
+func (statement *Statement) TenantMapValues(columns []string, argss [][]interface{}) ([]string, [][]interface{}, error) {
+	col, err := statement.tenantColumn(statement.RefTable)
+	if err != nil || col == nil {
+		return columns, argss, err
+	}
+	for i, column := range columns {
+		if !strings.EqualFold(column, col.Name) {
+			continue
+		}
+		for _, args := range argss {
+			if fmt.Sprint(args[i]) != fmt.Sprint(statement.TenantID) {
+				return nil, nil, fmt.Errorf("%w: %v", ErrTenantMismatch, args[i])
+			}
+		}
+		return columns, argss, nil
+	}
+	var tenantArgss = make([][]interface{}, 0, len(argss))
+	for _, args := range argss {
+		tenantArgss = append(tenantArgss, append(args[:len(args):len(args)], statement.TenantID))
+	}
+	return append(columns[:len(columns):len(columns)], col.Name), tenantArgss, nil
+}
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm/convert"
@@ -54,6 +55,10 @@ func (statement *Statement) ifAddColUpdate(col *schemasvr.Column, includeVersion
 	if col.IsDeleted && !unscoped {
 		return false, nil
 	}
+	// the rows cannot be moved to another tenant
+	if col.IsTenant && !statement.allTenants {
+		return false, nil
+	}
 	if omitColumnMap.Contain(col.Name) {
 		return false, nil
 	}
@@ -298,8 +303,33 @@ func (statement *Statement) BuildUpdates(tableValue reflect.Value,
 			val = fieldValue.Interface()
 		}
 	APPEND:
+		if val, err = statement.EncryptValue(col, val); err != nil {
+			return nil, nil, err
+		}
 		args = append(args, val)
 		colNames = append(colNames, fmt.Sprintf("%v = ?", statement.quote(col.Name)))
 	}
 	return colNames, args, nil
 }
+
+// LimitRowsCond returns the condition limiting a DELETE or an UPDATE to the rows
+// of the table chosen by clauses, the WHERE, ORDER BY and LIMIT of a SELECT. ok
+// is false if the database limits the statements itself or has no row IDs.
+func (statement *Statement) LimitRowsCond(tableName, clauses string) (cond string, ok bool) {
+	var rowID string
+	switch dbType := statement.dialect.URI().DBType; {
+	case dbType == schemasvr.SQLITE:
+		rowID = "rowid"
+	case dbType == schemasvr.COCKROACH:
+		// cockroach has no ctid, it orders and limits the DELETE and the UPDATE
+		return "", false
+	case dbType == schemasvr.YUGABYTE:
+		// yugabyte has no ctid, ybctid identifies the rows of its tables
+		rowID = "ybctid"
+	case dbType.IsPostgres():
+		rowID = "ctid"
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%s IN (SELECT %s FROM %s %s)", rowID, rowID, tableName, strings.TrimSpace(clauses)), true
+}
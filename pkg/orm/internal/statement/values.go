@@ -41,6 +41,24 @@ var (
 
 // Value2Interface convert a field value of a struct to interface for putting into database
 func (statement *Statement) Value2Interface(col *schemasvr.Column, fieldValue reflect.Value) (interface{}, error) {
+	v, err := statement.value2Interface(col, fieldValue)
+	if err != nil {
+		return nil, err
+	}
+	return statement.EncryptValue(col, v)
+}
+
+// PlainValue converts a field value of a struct to the value put into the
+// database before it's encrypted, the bytes are copied so the value could be kept
+func (statement *Statement) PlainValue(col *schemasvr.Column, fieldValue reflect.Value) (interface{}, error) {
+	v, err := statement.value2Interface(col, fieldValue)
+	if bs, ok := v.([]byte); ok && bs != nil {
+		v = append([]byte{}, bs...)
+	}
+	return v, err
+}
+
+func (statement *Statement) value2Interface(col *schemasvr.Column, fieldValue reflect.Value) (interface{}, error) {
 	if fieldValue.CanAddr() {
 		if fieldConvert, ok := fieldValue.Addr().Interface().(convert.Conversion); ok {
 			data, err := fieldConvert.ToDB()
@@ -0,0 +1,395 @@
+package utils
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"strings"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlIdent
+	sqlString
+	sqlNumber
+	sqlParam
+	sqlPunct
+)
+
+type sqlToken struct {
+	kind       sqlTokenKind
+	text       string
+	start, end int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$' || c == '#'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tokenizeSQL splits a SQL statement into tokens, dropping whitespace and
+// comments. It is deliberately lenient: it is used for logging, tracing and
+// cache invalidation, never to validate SQL. A backslash escapes the next
+// character of a string literal only if backslashEscapes is true, as in MySQL,
+// standard SQL only doubles the quotes.
+func tokenizeSQL(s string, backslashEscapes bool) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(s) && s[i+1] == '-':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += end + 4
+			}
+		case c == '\'':
+			j := i + 1
+			for j < len(s) {
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				if s[j] == '\\' && backslashEscapes {
+					j++
+				}
+				j++
+			}
+			if j < len(s) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlString, s[i:j], i, j})
+			i = j
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := strings.IndexByte(s[i+1:], closing)
+			if j < 0 {
+				j = len(s)
+			} else {
+				j += i + 1
+			}
+			tokens = append(tokens, sqlToken{sqlIdent, s[i+1 : j], i, j + 1})
+			i = j + 1
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			j := i
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.' || s[j] == 'e' || s[j] == 'E' || s[j] == 'x' || s[j] == 'X' ||
+				(s[j] >= 'a' && s[j] <= 'f') || (s[j] >= 'A' && s[j] <= 'F')) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlNumber, s[i:j], i, j})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && isIdentPart(s[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlWord, s[i:j], i, j})
+			i = j
+		case c == '?':
+			tokens = append(tokens, sqlToken{sqlParam, "?", i, i + 1})
+			i++
+		case (c == '$' || c == ':' || c == '@') && i+1 < len(s) && isIdentPart(s[i+1]):
+			j := i + 1
+			for j < len(s) && isIdentPart(s[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlParam, s[i:j], i, j})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{sqlPunct, s[i : i+1], i, i + 1})
+			i++
+		}
+	}
+	return tokens
+}
+
+func (t sqlToken) is(keyword string) bool {
+	return t.kind == sqlWord && strings.EqualFold(t.text, keyword)
+}
+
+// RedactSQL replaces every string and numeric literal in the SQL with a
+// placeholder, so that the statement could be exported without leaking
+// the values embedded in it. backslashEscapes tells whether the dialect
+// escapes the quotes in string literals with a backslash.
+func RedactSQL(sqlStr string, backslashEscapes bool) string {
+	var buf strings.Builder
+	var last int
+	for _, t := range tokenizeSQL(sqlStr, backslashEscapes) {
+		if t.kind != sqlString && t.kind != sqlNumber {
+			continue
+		}
+		buf.WriteString(sqlStr[last:t.start])
+		buf.WriteByte('?')
+		last = t.end
+	}
+	if last == 0 {
+		return sqlStr
+	}
+	buf.WriteString(sqlStr[last:])
+	return buf.String()
+}
+
+var sqlVerbs = map[string]bool{
+	"SELECT": true,
+	"INSERT": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"MERGE":  true,
+	"UPSERT": true,
+}
+
+var sqlWriteOperations = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"UPSERT":   true,
+	"REPLACE":  true,
+	"CREATE":   true,
+	"ALTER":    true,
+	"DROP":     true,
+	"TRUNCATE": true,
+	"RENAME":   true,
+}
+
+// IsWriteOperation tells whether the operation returned by SQLOperation
+// changes the rows or the schema of the database
+func IsWriteOperation(op string) bool {
+	return sqlWriteOperations[op]
+}
+
+// SQLOperation returns the upper-cased verb of the SQL, i.e. SELECT, INSERT,
+// CREATE. For a statement starting with common table expressions, the verb
+// of the main statement is returned.
+func SQLOperation(sqlStr string, backslashEscapes bool) string {
+	tokens := tokenizeSQL(sqlStr, backslashEscapes)
+	var first string
+	var depth int
+	for _, t := range tokens {
+		switch {
+		case t.kind == sqlPunct && t.text == "(":
+			depth++
+		case t.kind == sqlPunct && t.text == ")":
+			depth--
+		case t.kind == sqlWord:
+			word := strings.ToUpper(t.text)
+			if first == "" {
+				first = word
+				if first != "WITH" {
+					return first
+				}
+			} else if depth == 0 && sqlVerbs[word] {
+				return word
+			}
+		}
+	}
+	return first
+}
+
+var sqlClauseWords = map[string]bool{
+	"WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "OUTER": true, "CROSS": true, "NATURAL": true, "ON": true,
+	"USING": true, "GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true,
+	"OFFSET": true, "FETCH": true, "FOR": true, "SET": true, "VALUES": true,
+	"SELECT": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"WINDOW": true, "RETURNING": true, "OUTPUT": true, "WITH": true,
+	"DEFAULT": true, "LOCK": true,
+}
+
+// readTableName reads a possibly schema qualified table name starting at
+// tokens[i], returns the name and the position after it.
+func readTableName(tokens []sqlToken, i int) (string, int) {
+	if i >= len(tokens) || (tokens[i].kind != sqlWord && tokens[i].kind != sqlIdent) {
+		return "", i
+	}
+	if tokens[i].kind == sqlWord && sqlClauseWords[strings.ToUpper(tokens[i].text)] {
+		return "", i
+	}
+	name := tokens[i].text
+	i++
+	for i+1 < len(tokens) && tokens[i].kind == sqlPunct && tokens[i].text == "." &&
+		(tokens[i+1].kind == sqlWord || tokens[i+1].kind == sqlIdent) {
+		name += "." + tokens[i+1].text
+		i += 2
+	}
+	return name, i
+}
+
+// skipAlias skips an optional table alias, with or without AS
+func skipAlias(tokens []sqlToken, i int) int {
+	if i < len(tokens) && tokens[i].is("AS") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].kind == sqlIdent ||
+		(tokens[i].kind == sqlWord && !sqlClauseWords[strings.ToUpper(tokens[i].text)])) {
+		i++
+	}
+	return i
+}
+
+// SQLTables returns the names of all the tables which the SQL reads from or
+// writes to, in order of appearance and without duplicates. Quotes are
+// removed from the names but schema prefixes are kept.
+func SQLTables(sqlStr string, backslashEscapes bool) []string {
+	tokens := tokenizeSQL(sqlStr, backslashEscapes)
+	var tables []string
+	var seen = make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tables = append(tables, name)
+		}
+	}
+	var sawIndex bool
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != sqlWord {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "INDEX":
+			sawIndex = true
+		case "ON":
+			if sawIndex {
+				name, _ := readTableName(tokens, i+1)
+				add(name)
+				sawIndex = false
+			}
+		case "UPDATE":
+			// FOR UPDATE, ON DUPLICATE KEY UPDATE and DO UPDATE don't name a table
+			if i > 0 && (tokens[i-1].is("FOR") || tokens[i-1].is("KEY") || tokens[i-1].is("DO")) {
+				continue
+			}
+			name, _ := readTableName(tokens, i+1)
+			add(name)
+		case "INTO", "JOIN":
+			name, _ := readTableName(tokens, i+1)
+			add(name)
+		case "TABLE", "TRUNCATE":
+			j := i + 1
+			if j < len(tokens) && tokens[j].is("TABLE") {
+				j++
+			}
+			for j < len(tokens) && (tokens[j].is("IF") || tokens[j].is("NOT") || tokens[j].is("EXISTS") || tokens[j].is("ONLY")) {
+				j++
+			}
+			name, _ := readTableName(tokens, j)
+			add(name)
+		case "FROM":
+			j := i + 1
+			for {
+				name, next := readTableName(tokens, j)
+				if name == "" {
+					break
+				}
+				add(name)
+				j = skipAlias(tokens, next)
+				if j >= len(tokens) || tokens[j].kind != sqlPunct || tokens[j].text != "," {
+					break
+				}
+				j++
+			}
+		}
+	}
+	return tables
+}
+
+// FingerprintSQL normalises the SQL so that statements which only differ in
+// their parameters share the same fingerprint: literals and placeholders are
+// replaced with ?, lists of them with ?+, words are lower-cased, quotes,
+// comments and redundant whitespaces are removed.
+func FingerprintSQL(sqlStr string, backslashEscapes bool) string {
+	tokens := tokenizeSQL(sqlStr, backslashEscapes)
+	parts := make([]string, 0, len(tokens))
+	// function calls keep their parenthesis glued to the name
+	glued := make([]bool, 0, len(tokens))
+	isValue := func(t sqlToken) bool {
+		return t.kind == sqlString || t.kind == sqlNumber || t.kind == sqlParam
+	}
+	for i := 0; i < len(tokens); i++ {
+		t, prev := tokens[i], i-1
+		part := t.text
+		switch t.kind {
+		case sqlString, sqlNumber, sqlParam:
+			part = "?"
+		case sqlWord:
+			part = strings.ToLower(t.text)
+		case sqlPunct:
+			if t.text != "(" {
+				break
+			}
+			// collapse (?, ?, ...) into (?+)
+			j := i + 1
+			for j < len(tokens) && isValue(tokens[j]) {
+				if j+1 < len(tokens) && tokens[j+1].text == "," && j+2 < len(tokens) && isValue(tokens[j+2]) {
+					j += 2
+					continue
+				}
+				j++
+				break
+			}
+			if j == i+1 || j >= len(tokens) || tokens[j].kind != sqlPunct || tokens[j].text != ")" {
+				break
+			}
+			i = j
+			// multiple rows of values share one fingerprint too
+			if n := len(parts); n >= 2 && parts[n-1] == "," && parts[n-2] == "(?+)" {
+				parts, glued = parts[:n-1], glued[:n-1]
+				continue
+			}
+			part = "(?+)"
+		}
+		glue := part == "," || part == ")" || part == "." ||
+			(len(parts) > 0 && (parts[len(parts)-1] == "(" || parts[len(parts)-1] == ".")) ||
+			(part[0] == '(' && prev >= 0 && tokens[prev].kind == sqlWord && tokens[prev].end == t.start)
+		parts = append(parts, part)
+		glued = append(glued, glue)
+	}
+	var buf strings.Builder
+	for i, part := range parts {
+		if i > 0 && !glued[i] {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(part)
+	}
+	return buf.String()
+}
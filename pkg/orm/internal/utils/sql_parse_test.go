@@ -0,0 +1,122 @@
+package utils
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactSQL(t *testing.T) {
+	var kases = []struct {
+		sql              string
+		backslashEscapes bool
+		expect           string
+	}{
+		{"SELECT * FROM `user` WHERE id = ?", false, "SELECT * FROM `user` WHERE id = ?"},
+		{"SELECT * FROM user WHERE name = 'it''s me' AND age > 18", false, "SELECT * FROM user WHERE name = ? AND age > ?"},
+		{`UPDATE "t1" SET "v"=3.14 WHERE "id"=$1`, false, `UPDATE "t1" SET "v"=? WHERE "id"=$1`},
+		{"INSERT INTO t (a, b) VALUES (1, 'x') -- trailing 'comment'", false, "INSERT INTO t (a, b) VALUES (?, ?) -- trailing 'comment'"},
+		{`SELECT * FROM f WHERE path = 'C:\' AND secret = 'token'`, false, `SELECT * FROM f WHERE path = ? AND secret = ?`},
+		{`SELECT * FROM f WHERE name = 'it\'s' AND secret = 'token'`, true, `SELECT * FROM f WHERE name = ? AND secret = ?`},
+	}
+	for _, kase := range kases {
+		assert.EqualValues(t, kase.expect, RedactSQL(kase.sql, kase.backslashEscapes), kase.sql)
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	var kases = []struct {
+		sql    string
+		expect string
+	}{
+		{"select * from user", "SELECT"},
+		{"  /* hint */ INSERT INTO t VALUES (?)", "INSERT"},
+		{"WITH a AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM a)", "DELETE"},
+		{"BEGIN TRANSACTION", "BEGIN"},
+		{"", ""},
+	}
+	for _, kase := range kases {
+		assert.EqualValues(t, kase.expect, SQLOperation(kase.sql, false))
+	}
+}
+
+func TestIsWriteOperation(t *testing.T) {
+	for _, sql := range []string{
+		"INSERT INTO t VALUES (?) RETURNING id",
+		"WITH a AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM a)",
+		"create table t (id int)",
+	} {
+		assert.True(t, IsWriteOperation(SQLOperation(sql, false)), sql)
+	}
+	for _, sql := range []string{
+		"SELECT * FROM t",
+		"PRAGMA table_info(t)",
+		"SHOW TABLES",
+		"EXPLAIN SELECT * FROM t",
+		"VALUES (1), (2)",
+	} {
+		assert.False(t, IsWriteOperation(SQLOperation(sql, false)), sql)
+	}
+}
+
+func TestSQLTables(t *testing.T) {
+	var kases = []struct {
+		sql    string
+		expect []string
+	}{
+		{"SELECT * FROM `user` WHERE id = ?", []string{"user"}},
+		{`SELECT a.id FROM "s"."a" AS a LEFT JOIN b ON a.id = b.aid`, []string{"s.a", "b"}},
+		{"SELECT * FROM a, b x, [c] WHERE a.id = x.id", []string{"a", "b", "c"}},
+		{"UPDATE t SET v = v + 1 WHERE id IN (SELECT tid FROM u)", []string{"t", "u"}},
+		{"INSERT INTO t (a) VALUES (?) ON DUPLICATE KEY UPDATE a = ?", []string{"t"}},
+		{"SELECT * FROM t WHERE id = ? FOR UPDATE", []string{"t"}},
+		{"DELETE FROM t WHERE id = ?", []string{"t"}},
+		{"CREATE TABLE IF NOT EXISTS `t` (`id` INTEGER)", []string{"t"}},
+		{"CREATE UNIQUE INDEX UQE_t_name ON t (name)", []string{"t"}},
+		{"SELECT count(*) FROM (SELECT id FROM t) sub", []string{"t"}},
+		{"SELECT 1", nil},
+	}
+	for _, kase := range kases {
+		assert.EqualValues(t, kase.expect, SQLTables(kase.sql, false), kase.sql)
+	}
+}
+
+func TestFingerprintSQL(t *testing.T) {
+	var kases = []struct {
+		sql    string
+		expect string
+	}{
+		{"SELECT * FROM `user` WHERE id = ?", "select * from user where id = ?"},
+		{"select *   from user\n where id=1 -- by id", "select * from user where id = ?"},
+		{`SELECT "id" FROM "user" WHERE "name" = $1 AND "age" > $2`, "select id from user where name = ? and age > ?"},
+		{"SELECT * FROM user WHERE id IN (1, 2, 3)", "select * from user where id in (?+)"},
+		{"SELECT * FROM user WHERE id IN (?)", "select * from user where id in (?+)"},
+		{"INSERT INTO user (a, b) VALUES (?, ?), (?, ?), (?, ?)", "insert into user (a, b) values (?+)"},
+		{"SELECT count(*) FROM user", "select count(*) from user"},
+		{"SELECT coalesce(a, 0) FROM user WHERE b IN(?, ?)", "select coalesce(a, ?) from user where b in(?+)"},
+		{"SELECT u.id FROM user u", "select u.id from user u"},
+	}
+	for _, kase := range kases {
+		assert.EqualValues(t, kase.expect, FingerprintSQL(kase.sql, false), kase.sql)
+	}
+}
@@ -0,0 +1,177 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
+)
+
+// JSONPathExpr represents the scalar value at a JSON path of a column, its
+// methods build the conditions for Where, And and Or, i.e.
+//
+//	engine.Where(orm.JSONPath("attrs", "$.address.city").Eq("Pune")).Find(&users)
+//
+// renders attrs #>> '{address,city}' on postgres, JSON_EXTRACT on mysql and sqlite and
+// JSON_VALUE on mssql and oracle
+type JSONPathExpr struct {
+	col  string
+	path string
+}
+
+// JSONPath returns the value at the JSON path like $.a.b[0] of the column
+func JSONPath(col, path string) JSONPathExpr {
+	return JSONPathExpr{col: col, path: path}
+}
+
+func (expr JSONPathExpr) cond(op string, args ...interface{}) builder.Cond {
+	return jsonPathCond{expr: expr, op: op, args: args}
+}
+
+// Eq returns a condition that the value equals v
+func (expr JSONPathExpr) Eq(v interface{}) builder.Cond {
+	return expr.cond("=", v)
+}
+
+// Neq returns a condition that the value does not equal v
+func (expr JSONPathExpr) Neq(v interface{}) builder.Cond {
+	return expr.cond("<>", v)
+}
+
+// Gt returns a condition that the value is greater than v
+func (expr JSONPathExpr) Gt(v interface{}) builder.Cond {
+	return expr.cond(">", v)
+}
+
+// Gte returns a condition that the value is greater than or equals v
+func (expr JSONPathExpr) Gte(v interface{}) builder.Cond {
+	return expr.cond(">=", v)
+}
+
+// Lt returns a condition that the value is less than v
+func (expr JSONPathExpr) Lt(v interface{}) builder.Cond {
+	return expr.cond("<", v)
+}
+
+// Lte returns a condition that the value is less than or equals v
+func (expr JSONPathExpr) Lte(v interface{}) builder.Cond {
+	return expr.cond("<=", v)
+}
+
+// Like returns a condition that the value contains s
+func (expr JSONPathExpr) Like(s string) builder.Cond {
+	return expr.cond("LIKE", "%"+s+"%")
+}
+
+// In returns a condition that the value is one of vs
+func (expr JSONPathExpr) In(vs ...interface{}) builder.Cond {
+	return expr.cond("IN", vs...)
+}
+
+// NotIn returns a condition that the value is none of vs
+func (expr JSONPathExpr) NotIn(vs ...interface{}) builder.Cond {
+	return expr.cond("NOT IN", vs...)
+}
+
+// IsNull returns a condition that the path has no value
+func (expr JSONPathExpr) IsNull() builder.Cond {
+	return expr.cond("IS NULL")
+}
+
+// NotNull returns a condition that the path has a value
+func (expr JSONPathExpr) NotNull() builder.Cond {
+	return expr.cond("IS NOT NULL")
+}
+
+// jsonPathCond is a condition on a JSON path, it is rendered by the dialect of
+// the writer
+type jsonPathCond struct {
+	expr JSONPathExpr
+	op   string
+	args []interface{}
+}
+
+var _ builder.Cond = jsonPathCond{}
+
+// castType returns the SQL type to compare the text of the JSON value with the
+// args on postgres which has no implicit conversion from text
+func (cond jsonPathCond) castType() string {
+	if len(cond.args) == 0 {
+		return ""
+	}
+	switch cond.args[0].(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return "NUMERIC"
+	case bool:
+		return "BOOLEAN"
+	}
+	return ""
+}
+
+func (cond jsonPathCond) WriteTo(w builder.Writer) error {
+	dw, ok := w.(interface{ Dialect() dialectsvr.Dialect })
+	if !ok {
+		return ErrNoDialect
+	}
+	dialect := dw.Dialect()
+	expr, err := dialectsvr.JSONPathSQL(dialect, cond.expr.col, cond.expr.path)
+	if err != nil {
+		return err
+	}
+	if castType := cond.castType(); castType != "" && dialect.URI().DBType.IsPostgres() {
+		expr = fmt.Sprintf("CAST((%s) AS %s)", expr, castType)
+	}
+	switch cond.op {
+	case "IS NULL", "IS NOT NULL":
+		_, err = fmt.Fprintf(w, "%s %s", expr, cond.op)
+		return err
+	case "IN", "NOT IN":
+		if len(cond.args) == 0 {
+			if cond.op == "IN" {
+				_, err = fmt.Fprint(w, "0=1")
+			} else {
+				_, err = fmt.Fprint(w, "0=0")
+			}
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s %s (%s)", expr, cond.op, strings.TrimSuffix(strings.Repeat("?,", len(cond.args)), ","))
+	default:
+		_, err = fmt.Fprintf(w, "%s %s ?", expr, cond.op)
+	}
+	w.Append(cond.args...)
+	return err
+}
+
+func (cond jsonPathCond) And(conds ...builder.Cond) builder.Cond {
+	return builder.And(cond, builder.And(conds...))
+}
+
+func (cond jsonPathCond) Or(conds ...builder.Cond) builder.Cond {
+	return builder.Or(cond, builder.Or(conds...))
+}
+
+func (cond jsonPathCond) IsValid() bool {
+	return true
+}
@@ -48,8 +48,16 @@ type ContextLogger interface {
 	IsShowSQL() bool
 }
 
+// SlowSQLLogger could be implemented by a ContextLogger which wants to handle
+// the SQLs exceeding the slow query threshold itself, otherwise they are logged
+// via Warnf.
+type SlowSQLLogger interface {
+	SlowSQL(context LogContext)
+}
+
 var (
 	_ ContextLogger = &LoggerAdapter{}
+	_ SlowSQLLogger = &LoggerAdapter{}
 )
 
 // enumerate all the context keys
@@ -88,6 +96,16 @@ func (l *LoggerAdapter) AfterSQL(ctx LogContext) {
 	}
 }
 
+// SlowSQL implements SlowSQLLogger
+func (l *LoggerAdapter) SlowSQL(ctx LogContext) {
+	var sessionPart string
+	v := ctx.Ctx.Value(SessionIDKey)
+	if key, ok := v.(string); ok {
+		sessionPart = fmt.Sprintf(" [%s]", key)
+	}
+	l.logger.Warnf("[SLOW SQL]%s %s %v - %v", sessionPart, ctx.SQL, ctx.Args, ctx.ExecuteTime)
+}
+
 // Debugf implements ContextLogger
 func (l *LoggerAdapter) Debugf(format string, v ...interface{}) {
 	l.logger.Debugf(format, v...)
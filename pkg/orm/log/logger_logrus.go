@@ -0,0 +1,137 @@
+package log
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	_ ContextLogger = &LogrusLogger{}
+	_ SlowSQLLogger = &LogrusLogger{}
+)
+
+// LogrusLogger is a ContextLogger emitting the SQLs as logrus entries with
+// fields
+type LogrusLogger struct {
+	logger  logrus.FieldLogger
+	level   LogLevel
+	showSQL bool
+}
+
+// NewLogrusLogger creates a ContextLogger writing to a *logrus.Logger or a
+// *logrus.Entry, if logger is nil, the logrus standard logger will be used
+func NewLogrusLogger(logger logrus.FieldLogger) *LogrusLogger {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &LogrusLogger{
+		logger: logger,
+		level:  LOG_INFO,
+	}
+}
+
+func (l *LogrusLogger) sqlEntry(ctx LogContext) *logrus.Entry {
+	fields := make(logrus.Fields)
+	for _, field := range SQLFields(ctx) {
+		fields[field.Key] = field.Value
+	}
+	entry := l.logger.WithFields(fields)
+	if ctx.Ctx != nil {
+		entry = entry.WithContext(ctx.Ctx)
+	}
+	return entry
+}
+
+// BeforeSQL implements ContextLogger
+func (l *LogrusLogger) BeforeSQL(ctx LogContext) {}
+
+// AfterSQL implements ContextLogger
+func (l *LogrusLogger) AfterSQL(ctx LogContext) {
+	if ctx.Err != nil {
+		if l.level <= LOG_ERR {
+			l.sqlEntry(ctx).Error("SQL")
+		}
+		return
+	}
+	if l.level <= LOG_INFO {
+		l.sqlEntry(ctx).Info("SQL")
+	}
+}
+
+// SlowSQL implements SlowSQLLogger
+func (l *LogrusLogger) SlowSQL(ctx LogContext) {
+	if l.level <= LOG_WARNING {
+		l.sqlEntry(ctx).WithField(FieldSlow, true).Warn("slow SQL")
+	}
+}
+
+// Debugf implements ContextLogger
+func (l *LogrusLogger) Debugf(format string, v ...interface{}) {
+	if l.level <= LOG_DEBUG {
+		l.logger.Debugf(format, v...)
+	}
+}
+
+// Errorf implements ContextLogger
+func (l *LogrusLogger) Errorf(format string, v ...interface{}) {
+	if l.level <= LOG_ERR {
+		l.logger.Errorf(format, v...)
+	}
+}
+
+// Infof implements ContextLogger
+func (l *LogrusLogger) Infof(format string, v ...interface{}) {
+	if l.level <= LOG_INFO {
+		l.logger.Infof(format, v...)
+	}
+}
+
+// Warnf implements ContextLogger
+func (l *LogrusLogger) Warnf(format string, v ...interface{}) {
+	if l.level <= LOG_WARNING {
+		l.logger.Warnf(format, v...)
+	}
+}
+
+// Level implements ContextLogger
+func (l *LogrusLogger) Level() LogLevel {
+	return l.level
+}
+
+// SetLevel implements ContextLogger
+func (l *LogrusLogger) SetLevel(lv LogLevel) {
+	l.level = lv
+}
+
+// ShowSQL implements ContextLogger
+func (l *LogrusLogger) ShowSQL(show ...bool) {
+	if len(show) == 0 {
+		l.showSQL = true
+		return
+	}
+	l.showSQL = show[0]
+}
+
+// IsShowSQL implements ContextLogger
+func (l *LogrusLogger) IsShowSQL() bool {
+	return l.showSQL
+}
@@ -0,0 +1,80 @@
+package log
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogrusLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.SetOutput(&buf)
+	logrusLogger.SetFormatter(&logrus.JSONFormatter{})
+	logger := NewLogrusLogger(logrusLogger)
+
+	ctx := context.WithValue(context.Background(), SessionIDKey, "abcdef")
+	logger.AfterSQL(LogContext{
+		Ctx:         ctx,
+		SQL:         "SELECT * FROM user WHERE id = ?",
+		Args:        []interface{}{1},
+		ExecuteTime: time.Millisecond,
+	})
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.EqualValues(t, "info", entry["level"])
+	assert.EqualValues(t, "SQL", entry["msg"])
+	assert.EqualValues(t, "SELECT * FROM user WHERE id = ?", entry[FieldSQL])
+	assert.EqualValues(t, []interface{}{float64(1)}, entry[FieldArgs])
+	assert.EqualValues(t, time.Millisecond, entry[FieldDuration])
+	assert.EqualValues(t, "abcdef", entry[FieldSessionID])
+
+	buf.Reset()
+	logger.AfterSQL(LogContext{
+		Ctx: context.Background(),
+		SQL: "SELECT * FROM not_exist",
+		Err: errors.New("no such table"),
+	})
+	entry = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.EqualValues(t, "error", entry["level"])
+	assert.EqualValues(t, "no such table", entry[FieldError])
+	_, ok := entry[FieldSessionID]
+	assert.False(t, ok)
+
+	buf.Reset()
+	logger.SetLevel(LOG_WARNING)
+	logger.AfterSQL(LogContext{Ctx: context.Background(), SQL: "SELECT 1"})
+	assert.EqualValues(t, 0, buf.Len())
+	logger.SlowSQL(LogContext{Ctx: context.Background(), SQL: "SELECT 1", ExecuteTime: time.Second})
+	entry = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.EqualValues(t, "warning", entry["level"])
+	assert.EqualValues(t, true, entry[FieldSlow])
+}
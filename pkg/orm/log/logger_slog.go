@@ -0,0 +1,152 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var (
+	_ ContextLogger = &SlogLogger{}
+	_ SlowSQLLogger = &SlogLogger{}
+)
+
+// SlogLogger is a ContextLogger emitting the SQLs as structured records of
+// log/slog, it is only built with Go 1.21 and later which have log/slog
+type SlogLogger struct {
+	logger  *slog.Logger
+	level   LogLevel
+	showSQL bool
+}
+
+// NewSlogLogger creates a ContextLogger writing to the slog logger, if logger
+// is nil, slog.Default() will be used
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{
+		logger: logger,
+		level:  LOG_INFO,
+	}
+}
+
+func (l *SlogLogger) logSQL(ctx LogContext, level slog.Level, msg string, extra ...slog.Attr) {
+	fields := SQLFields(ctx)
+	attrs := make([]slog.Attr, 0, len(fields)+len(extra))
+	for _, field := range fields {
+		switch v := field.Value.(type) {
+		case time.Duration:
+			attrs = append(attrs, slog.Duration(field.Key, v))
+		case error:
+			attrs = append(attrs, slog.String(field.Key, v.Error()))
+		default:
+			attrs = append(attrs, slog.Any(field.Key, v))
+		}
+	}
+	attrs = append(attrs, extra...)
+	c := ctx.Ctx
+	if c == nil {
+		c = context.Background()
+	}
+	l.logger.LogAttrs(c, level, msg, attrs...)
+}
+
+// BeforeSQL implements ContextLogger
+func (l *SlogLogger) BeforeSQL(ctx LogContext) {}
+
+// AfterSQL implements ContextLogger
+func (l *SlogLogger) AfterSQL(ctx LogContext) {
+	if ctx.Err != nil {
+		if l.level <= LOG_ERR {
+			l.logSQL(ctx, slog.LevelError, "SQL")
+		}
+		return
+	}
+	if l.level <= LOG_INFO {
+		l.logSQL(ctx, slog.LevelInfo, "SQL")
+	}
+}
+
+// SlowSQL implements SlowSQLLogger
+func (l *SlogLogger) SlowSQL(ctx LogContext) {
+	if l.level <= LOG_WARNING {
+		l.logSQL(ctx, slog.LevelWarn, "slow SQL", slog.Bool(FieldSlow, true))
+	}
+}
+
+// Debugf implements ContextLogger
+func (l *SlogLogger) Debugf(format string, v ...interface{}) {
+	if l.level <= LOG_DEBUG {
+		l.logger.Debug(fmt.Sprintf(format, v...))
+	}
+}
+
+// Errorf implements ContextLogger
+func (l *SlogLogger) Errorf(format string, v ...interface{}) {
+	if l.level <= LOG_ERR {
+		l.logger.Error(fmt.Sprintf(format, v...))
+	}
+}
+
+// Infof implements ContextLogger
+func (l *SlogLogger) Infof(format string, v ...interface{}) {
+	if l.level <= LOG_INFO {
+		l.logger.Info(fmt.Sprintf(format, v...))
+	}
+}
+
+// Warnf implements ContextLogger
+func (l *SlogLogger) Warnf(format string, v ...interface{}) {
+	if l.level <= LOG_WARNING {
+		l.logger.Warn(fmt.Sprintf(format, v...))
+	}
+}
+
+// Level implements ContextLogger
+func (l *SlogLogger) Level() LogLevel {
+	return l.level
+}
+
+// SetLevel implements ContextLogger
+func (l *SlogLogger) SetLevel(lv LogLevel) {
+	l.level = lv
+}
+
+// ShowSQL implements ContextLogger
+func (l *SlogLogger) ShowSQL(show ...bool) {
+	if len(show) == 0 {
+		l.showSQL = true
+		return
+	}
+	l.showSQL = show[0]
+}
+
+// IsShowSQL implements ContextLogger
+func (l *SlogLogger) IsShowSQL() bool {
+	return l.showSQL
+}
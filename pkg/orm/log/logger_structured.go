@@ -0,0 +1,65 @@
+package log
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// the keys of the fields emitted by the structured loggers
+const (
+	FieldSQL          = "sql"
+	FieldArgs         = "args"
+	FieldDuration     = "duration"
+	FieldSessionID    = "session_id"
+	FieldRowsAffected = "rows_affected"
+	FieldError        = "error"
+	FieldSlow         = "slow"
+)
+
+// Field is a key value pair of a structured log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// SQLFields returns the structured fields describing an executed SQL, the
+// session id is included only when Engine.EnableSessionID is on
+func SQLFields(ctx LogContext) []Field {
+	fields := make([]Field, 0, 6)
+	fields = append(fields, Field{FieldSQL, ctx.SQL})
+	if len(ctx.Args) > 0 {
+		fields = append(fields, Field{FieldArgs, ctx.Args})
+	}
+	if ctx.ExecuteTime > 0 {
+		fields = append(fields, Field{FieldDuration, ctx.ExecuteTime})
+	}
+	if ctx.Ctx != nil {
+		if sessionID, ok := ctx.Ctx.Value(SessionIDKey).(string); ok {
+			fields = append(fields, Field{FieldSessionID, sessionID})
+		}
+	}
+	if ctx.Result != nil {
+		if affected, err := ctx.Result.RowsAffected(); err == nil {
+			fields = append(fields, Field{FieldRowsAffected, affected})
+		}
+	}
+	if ctx.Err != nil {
+		fields = append(fields, Field{FieldError, ctx.Err})
+	}
+	return fields
+}
@@ -0,0 +1,295 @@
+package outbox
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
+)
+
+// Status is the delivery status of a message
+type Status int
+
+// enumerates all the delivery statuses
+const (
+	StatusPending Status = iota
+	StatusDelivered
+	StatusFailed
+)
+
+// Message represents an event stored in the outbox table until it's delivered
+type Message struct {
+	Id          int64             `orm:"pk autoincr"`
+	Topic       string            `orm:"varchar(255) notnull"`
+	Key         string            `orm:"'partition_key' varchar(255)"`
+	Payload     []byte            `orm:"blob"`
+	Headers     map[string]string `orm:"json"`
+	Status      Status            `orm:"notnull index(pending)"`
+	Attempts    int               `orm:"notnull"`
+	LastError   string            `orm:"text"`
+	AvailableAt time.Time         `orm:"notnull index(pending)"`
+	CreatedAt   time.Time         `orm:"created"`
+	DeliveredAt time.Time
+}
+
+// Publisher delivers the messages to a broker, an error makes the relay retry
+// the message later
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// PublisherFunc is a func implementing Publisher
+type PublisherFunc func(ctx context.Context, msg *Message) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// BackoffFunc returns the delay before the next attempt of a message failed attempts times
+type BackoffFunc func(attempts int) time.Duration
+
+// ExponentialBackoff doubles the delay from base for every failed attempt, up to max
+func ExponentialBackoff(base, max time.Duration) BackoffFunc {
+	return func(attempts int) time.Duration {
+		delay := base
+		for i := 1; i < attempts && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			return max
+		}
+		return delay
+	}
+}
+
+// Options define options for the outbox.
+type Options struct {
+	// TableName is the outbox table.
+	TableName string
+	// BatchSize is the max number of messages delivered in a transaction.
+	BatchSize int
+	// PollInterval is the delay between the polls of a relay once the outbox is drained.
+	PollInterval time.Duration
+	// MaxAttempts is the number of attempts before a message is marked
+	// failed, 0 means the messages are retried forever.
+	MaxAttempts int
+	// Backoff returns the delay before the next attempt of a message.
+	Backoff BackoffFunc
+}
+
+var (
+	// DefaultOptions can be used if you don't want to think about options.
+	DefaultOptions = &Options{
+		TableName:    "outbox",
+		BatchSize:    100,
+		PollInterval: time.Second,
+		MaxAttempts:  10,
+		Backoff:      ExponentialBackoff(time.Second, time.Hour),
+	}
+	// ErrNoTopic is returned when a message has no topic
+	ErrNoTopic = errors.New("Missing topic in message")
+)
+
+// Outbox represents the outbox table of a database
+type Outbox struct {
+	db      *orm.Engine
+	options *Options
+}
+
+// New returns a new Outbox, the options left zero, or all of them if options
+// is nil, are taken from DefaultOptions except MaxAttempts.
+func New(db *orm.Engine, options *Options) *Outbox {
+	opts := *DefaultOptions
+	if options != nil {
+		opts.MaxAttempts = options.MaxAttempts
+		if options.TableName != "" {
+			opts.TableName = options.TableName
+		}
+		if options.BatchSize > 0 {
+			opts.BatchSize = options.BatchSize
+		}
+		if options.PollInterval > 0 {
+			opts.PollInterval = options.PollInterval
+		}
+		if options.Backoff != nil {
+			opts.Backoff = options.Backoff
+		}
+	}
+	return &Outbox{
+		db:      db,
+		options: &opts,
+	}
+}
+
+// Sync creates or updates the outbox table
+func (o *Outbox) Sync() error {
+	return o.db.Table(o.options.TableName).Sync(new(Message))
+}
+
+// Add inserts the messages in the transaction of the session, so they are
+// delivered only if the transaction is committed
+func (o *Outbox) Add(session *orm.Session, msgs ...*Message) error {
+	if !session.IsInTx() {
+		return orm.ErrNotInTransaction
+	}
+	now := time.Now()
+	for _, msg := range msgs {
+		if msg.Topic == "" {
+			return ErrNoTopic
+		}
+		msg.Status = StatusPending
+		msg.Attempts = 0
+		if msg.AvailableAt.IsZero() {
+			msg.AvailableAt = now
+		}
+		if _, err := session.Table(o.options.TableName).Insert(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Relay returns a relay delivering the messages of the outbox to the publisher
+func (o *Outbox) Relay(publisher Publisher) *Relay {
+	return &Relay{
+		outbox:    o,
+		publisher: publisher,
+	}
+}
+
+// formatTime returns t as the value of the column of the outbox table
+func (o *Outbox) formatTime(colName string, t time.Time) (interface{}, error) {
+	table, err := o.db.TableInfo(new(Message))
+	if err != nil {
+		return nil, err
+	}
+	return dialectsvr.FormatColumnTime(o.db.Dialect(), o.db.DatabaseTZ, table.GetColumn(colName), t)
+}
+
+// Relay polls the outbox and delivers the pending messages, the relays lock
+// the messages with FOR UPDATE SKIP LOCKED so several of them can share an
+// outbox. A relay must be run by one goroutine.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	// the dialect could not skip the locked rows, FOR UPDATE waits for them
+	noSkipLocked bool
+}
+
+// Run delivers the messages until the context is done
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if n >= r.outbox.options.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.outbox.options.PollInterval):
+		}
+	}
+}
+
+// RunOnce delivers a batch of the pending messages in a transaction, it returns
+// the number of the messages attempted
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	session := r.outbox.db.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	msgs, err := r.lock(session, now)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := r.deliver(ctx, session, msg, now); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := session.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// lock selects and locks the messages available at now
+func (r *Relay) lock(session *orm.Session, now time.Time) ([]*Message, error) {
+	availableAt, err := r.outbox.formatTime("available_at", now)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*Message
+	for {
+		session.Table(r.outbox.options.TableName).
+			Where(builder.Eq{"status": StatusPending}.And(builder.Lte{"available_at": availableAt})).
+			Asc("id").
+			Limit(r.outbox.options.BatchSize).
+			ForUpdate()
+		if !r.noSkipLocked {
+			session.SkipLocked()
+		}
+		err = session.Find(&msgs)
+		if !r.noSkipLocked && errors.Is(err, dialectsvr.ErrUnsupportedLockMode) {
+			r.noSkipLocked = true
+			continue
+		}
+		return msgs, err
+	}
+}
+
+// deliver publishes the message and records the result of the attempt
+func (r *Relay) deliver(ctx context.Context, session *orm.Session, msg *Message, now time.Time) error {
+	var cols []string
+	if err := r.publisher.Publish(ctx, msg); err != nil {
+		msg.Attempts++
+		msg.LastError = err.Error()
+		if max := r.outbox.options.MaxAttempts; max > 0 && msg.Attempts >= max {
+			msg.Status = StatusFailed
+		} else if r.outbox.options.Backoff != nil {
+			msg.AvailableAt = now.Add(r.outbox.options.Backoff(msg.Attempts))
+		}
+		cols = []string{"status", "attempts", "last_error", "available_at"}
+	} else {
+		msg.Status = StatusDelivered
+		msg.DeliveredAt = now
+		cols = []string{"status", "delivered_at"}
+	}
+	_, err := session.Table(r.outbox.options.TableName).ID(msg.Id).Cols(cols...).Update(msg)
+	return err
+}
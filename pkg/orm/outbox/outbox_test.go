@@ -0,0 +1,164 @@
+package outbox
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	dbName = "testdb.sqlite3"
+)
+
+func newOutbox(t *testing.T, options *Options) (*orm.Engine, *Outbox) {
+	_ = os.Remove(dbName)
+	db, err := orm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	o := New(db, options)
+	assert.NoError(t, o.Sync())
+	exists, err := db.IsTableExist(options.TableName)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	return db, o
+}
+
+func addMessages(t *testing.T, db *orm.Engine, o *Outbox, commit bool, msgs ...*Message) {
+	session := db.NewSession()
+	defer session.Close()
+	assert.NoError(t, session.Begin())
+	assert.NoError(t, o.Add(session, msgs...))
+	if commit {
+		assert.NoError(t, session.Commit())
+	} else {
+		assert.NoError(t, session.Rollback())
+	}
+}
+
+func TestRelay(t *testing.T) {
+	db, o := newOutbox(t, DefaultOptions)
+	defer db.Close()
+
+	assert.ErrorIs(t, o.Add(db.NewSession(), &Message{Topic: "a"}), orm.ErrNotInTransaction)
+
+	addMessages(t, db, o, false, &Message{Topic: "orders", Payload: []byte("rolled back")})
+	addMessages(t, db, o, true,
+		&Message{Topic: "orders", Key: "1", Payload: []byte("created"), Headers: map[string]string{"v": "1"}},
+		&Message{Topic: "orders", Key: "1", Payload: []byte("paid")},
+	)
+
+	var published []string
+	relay := o.Relay(PublisherFunc(func(ctx context.Context, msg *Message) error {
+		published = append(published, string(msg.Payload))
+		return nil
+	}))
+	n, err := relay.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	assert.EqualValues(t, []string{"created", "paid"}, published)
+
+	var msgs []Message
+	assert.NoError(t, db.Table(DefaultOptions.TableName).Asc("id").Find(&msgs))
+	assert.EqualValues(t, 2, len(msgs))
+	assert.EqualValues(t, StatusDelivered, msgs[0].Status)
+	assert.False(t, msgs[0].DeliveredAt.IsZero())
+	assert.EqualValues(t, "1", msgs[0].Headers["v"])
+
+	n, err = relay.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestRelayRetry(t *testing.T) {
+	db, o := newOutbox(t, &Options{
+		TableName:   "events_outbox",
+		BatchSize:   10,
+		MaxAttempts: 2,
+		Backoff:     ExponentialBackoff(time.Hour, time.Hour),
+	})
+	defer db.Close()
+	addMessages(t, db, o, true, &Message{Topic: "orders"})
+
+	var attempts int
+	relay := o.Relay(PublisherFunc(func(ctx context.Context, msg *Message) error {
+		attempts++
+		return errors.New("broker is down")
+	}))
+	n, err := relay.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	// the message waits for the backoff
+	n, err = relay.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	var msg Message
+	has, err := db.Table("events_outbox").Get(&msg)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, StatusPending, msg.Status)
+	assert.EqualValues(t, 1, msg.Attempts)
+	assert.EqualValues(t, "broker is down", msg.LastError)
+	assert.True(t, msg.AvailableAt.After(time.Now().Add(30*time.Minute)))
+
+	// the last attempt marks the message failed
+	_, err = db.Table("events_outbox").ID(msg.Id).Cols("available_at").Update(&Message{AvailableAt: time.Now().Add(-time.Minute)})
+	assert.NoError(t, err)
+	n, err = relay.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	var failed Message
+	has, err = db.Table("events_outbox").ID(msg.Id).Get(&failed)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, StatusFailed, failed.Status)
+	assert.EqualValues(t, 2, attempts)
+}
+
+func TestNewDefaultOptions(t *testing.T) {
+	o := New(nil, nil)
+	assert.EqualValues(t, DefaultOptions.TableName, o.options.TableName)
+	assert.EqualValues(t, DefaultOptions.BatchSize, o.options.BatchSize)
+	assert.EqualValues(t, DefaultOptions.MaxAttempts, o.options.MaxAttempts)
+
+	o = New(nil, &Options{TableName: "events_outbox"})
+	assert.EqualValues(t, "events_outbox", o.options.TableName)
+	assert.EqualValues(t, DefaultOptions.BatchSize, o.options.BatchSize)
+	assert.EqualValues(t, DefaultOptions.PollInterval, o.options.PollInterval)
+	assert.NotNil(t, o.options.Backoff)
+	assert.EqualValues(t, 0, o.options.MaxAttempts)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	assert.EqualValues(t, time.Second, backoff(1))
+	assert.EqualValues(t, 2*time.Second, backoff(2))
+	assert.EqualValues(t, 4*time.Second, backoff(3))
+	assert.EqualValues(t, 5*time.Second, backoff(4))
+}
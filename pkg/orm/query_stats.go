@@ -0,0 +1,304 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	"github.com/bhojpur/dbm/pkg/orm/log"
+)
+
+const (
+	// DefaultQueryStatsSamples is the number of latencies kept per fingerprint
+	// to compute the percentiles
+	DefaultQueryStatsSamples = 1024
+	// DefaultQueryStatsFingerprints is the max number of fingerprints tracked,
+	// the statements beyond are accounted to OtherFingerprint
+	DefaultQueryStatsFingerprints = 1000
+	// OtherFingerprint collects the statements once the registry is full
+	OtherFingerprint = "<other>"
+)
+
+// QueryStat represents the statistics of the statements sharing a fingerprint
+type QueryStat struct {
+	Fingerprint  string
+	Count        int64
+	Errors       int64
+	RowsAffected int64 // only for the statements returning a sql.Result
+	TotalTime    time.Duration
+	MaxTime      time.Duration
+	P50          time.Duration
+	P99          time.Duration
+}
+
+// AvgTime returns the average execution time
+func (stat QueryStat) AvgTime() time.Duration {
+	if stat.Count == 0 {
+		return 0
+	}
+	return stat.TotalTime / time.Duration(stat.Count)
+}
+
+type queryStatEntry struct {
+	QueryStat
+	samples []time.Duration
+	next    int
+}
+
+// QueryStats is an in-memory registry of the executed statements grouped by
+// their fingerprints, see Engine.EnableQueryStats
+type QueryStats struct {
+	mutex           sync.Mutex
+	entries         map[string]*queryStatEntry
+	maxSamples      int
+	maxFingerprints int
+	// the SQLs escape the quotes of their string literals with a backslash
+	backslashEscapes bool
+}
+
+// NewQueryStats creates a statistics registry
+func NewQueryStats() *QueryStats {
+	return &QueryStats{
+		entries:         make(map[string]*queryStatEntry),
+		maxSamples:      DefaultQueryStatsSamples,
+		maxFingerprints: DefaultQueryStatsFingerprints,
+	}
+}
+
+// SetMaxFingerprints sets the max number of tracked fingerprints
+func (s *QueryStats) SetMaxFingerprints(max int) {
+	s.mutex.Lock()
+	s.maxFingerprints = max
+	s.mutex.Unlock()
+}
+
+// Record accounts an executed SQL
+func (s *QueryStats) Record(sqlStr string, executeTime time.Duration, rowsAffected int64, err error) {
+	s.mutex.Lock()
+	fingerprint := utils.FingerprintSQL(sqlStr, s.backslashEscapes)
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[fingerprint]
+	if !ok {
+		if len(s.entries) >= s.maxFingerprints {
+			fingerprint = OtherFingerprint
+			entry = s.entries[fingerprint]
+		}
+		if entry == nil {
+			entry = &queryStatEntry{
+				QueryStat: QueryStat{Fingerprint: fingerprint},
+			}
+			s.entries[fingerprint] = entry
+		}
+	}
+	entry.Count++
+	if err != nil {
+		entry.Errors++
+	}
+	entry.RowsAffected += rowsAffected
+	entry.TotalTime += executeTime
+	if executeTime > entry.MaxTime {
+		entry.MaxTime = executeTime
+	}
+	if len(entry.samples) < s.maxSamples {
+		entry.samples = append(entry.samples, executeTime)
+	} else {
+		entry.samples[entry.next] = executeTime
+		entry.next = (entry.next + 1) % s.maxSamples
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// Snapshot returns the statistics of all the fingerprints, the most
+// time consuming ones first
+func (s *QueryStats) Snapshot() []QueryStat {
+	s.mutex.Lock()
+	stats := make([]QueryStat, 0, len(s.entries))
+	for _, entry := range s.entries {
+		stat := entry.QueryStat
+		samples := make([]time.Duration, len(entry.samples))
+		copy(samples, entry.samples)
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i] < samples[j]
+		})
+		stat.P50 = percentile(samples, 0.5)
+		stat.P99 = percentile(samples, 0.99)
+		stats = append(stats, stat)
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalTime == stats[j].TotalTime {
+			return stats[i].Fingerprint < stats[j].Fingerprint
+		}
+		return stats[i].TotalTime > stats[j].TotalTime
+	})
+	return stats
+}
+
+// Top returns the n most time consuming fingerprints
+func (s *QueryStats) Top(n int) []QueryStat {
+	stats := s.Snapshot()
+	if n >= 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// Get returns the statistics of the fingerprint of the SQL
+func (s *QueryStats) Get(sqlStr string) (QueryStat, bool) {
+	s.mutex.Lock()
+	fingerprint := utils.FingerprintSQL(sqlStr, s.backslashEscapes)
+	s.mutex.Unlock()
+	for _, stat := range s.Snapshot() {
+		if stat.Fingerprint == fingerprint {
+			return stat, true
+		}
+	}
+	return QueryStat{}, false
+}
+
+// Reset clears all the statistics
+func (s *QueryStats) Reset() {
+	s.mutex.Lock()
+	s.entries = make(map[string]*queryStatEntry)
+	s.mutex.Unlock()
+}
+
+// queryStatsHook is added to the engine the first time the slow query log
+// or the statistics are enabled
+type queryStatsHook struct {
+	engine        *Engine
+	mutex         sync.RWMutex
+	slowThreshold time.Duration
+	stats         *QueryStats
+}
+
+var (
+	_ ctxsvr.Hook = &queryStatsHook{}
+)
+
+func (h *queryStatsHook) BeforeProcess(c *ctxsvr.ContextHook) (context.Context, error) {
+	return c.Ctx, nil
+}
+
+func (h *queryStatsHook) AfterProcess(c *ctxsvr.ContextHook) error {
+	h.mutex.RLock()
+	slowThreshold, stats := h.slowThreshold, h.stats
+	h.mutex.RUnlock()
+
+	if slowThreshold > 0 && c.ExecuteTime >= slowThreshold {
+		logger := h.engine.logger
+		if slowLogger, ok := logger.(log.SlowSQLLogger); ok {
+			slowLogger.SlowSQL(log.LogContext(*c))
+		} else {
+			logger.Warnf("[SLOW SQL] %s %v - %v", c.SQL, c.Args, c.ExecuteTime)
+		}
+	}
+	if stats != nil {
+		var rowsAffected int64
+		if c.Result != nil {
+			rowsAffected, _ = c.Result.RowsAffected()
+		}
+		stats.Record(c.SQL, c.ExecuteTime, rowsAffected, c.Err)
+	}
+	return nil
+}
+
+// getQueryStatsHook returns the hook of the engine, it is added to the engine
+// first if install is true, otherwise nil is returned if there is none.
+func (engine *Engine) getQueryStatsHook(install bool) *queryStatsHook {
+	engine.queryStatsLock.Lock()
+	defer engine.queryStatsLock.Unlock()
+	if engine.queryStatsHook == nil && install {
+		engine.queryStatsHook = &queryStatsHook{engine: engine}
+		engine.AddHook(engine.queryStatsHook)
+	}
+	return engine.queryStatsHook
+}
+
+// SetSlowQueryThreshold logs the SQLs which take longer than threshold as
+// warnings whether ShowSQL is enabled or not, 0 disables the slow query log.
+func (engine *Engine) SetSlowQueryThreshold(threshold time.Duration) {
+	hook := engine.getQueryStatsHook(threshold > 0)
+	if hook == nil {
+		return
+	}
+	hook.mutex.Lock()
+	hook.slowThreshold = threshold
+	hook.mutex.Unlock()
+}
+
+// SetQueryStats sets the registry which collects the statistics of the
+// executed statements, nil stops collecting.
+func (engine *Engine) SetQueryStats(stats *QueryStats) {
+	if stats != nil {
+		stats.mutex.Lock()
+		stats.backslashEscapes = engine.backslashEscapes()
+		stats.mutex.Unlock()
+	}
+	hook := engine.getQueryStatsHook(stats != nil)
+	if hook == nil {
+		return
+	}
+	hook.mutex.Lock()
+	hook.stats = stats
+	hook.mutex.Unlock()
+}
+
+// EnableQueryStats starts or stops collecting the statistics of the
+// executed statements in a new registry
+func (engine *Engine) EnableQueryStats(enable bool) {
+	if enable {
+		if engine.QueryStats() == nil {
+			engine.SetQueryStats(NewQueryStats())
+		}
+		return
+	}
+	engine.SetQueryStats(nil)
+}
+
+// QueryStats returns the statistics registry, nil if not enabled
+func (engine *Engine) QueryStats() *QueryStats {
+	hook := engine.getQueryStatsHook(false)
+	if hook == nil {
+		return nil
+	}
+	hook.mutex.RLock()
+	defer hook.mutex.RUnlock()
+	return hook.stats
+}
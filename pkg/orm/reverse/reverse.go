@@ -0,0 +1,264 @@
+package reverse
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/bhojpur/dbm/pkg/orm/name"
+	"github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// DefaultTemplate is the template of the generated file, it's executed with a *File
+const DefaultTemplate = `// Code generated by dbm orm reverse.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{end}}
+{{- range .Models}}
+{{if .Comment}}// {{.Name}} {{.Comment}}
+{{end -}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+{{if .TableName}}
+// TableName returns the name of the table
+func ({{.Name}}) TableName() string {
+	return "{{.TableName}}"
+}
+{{end}}
+{{- end}}
+`
+
+// Options define options for the generated code.
+type Options struct {
+	// Package is the name of the package of the generated file.
+	Package string
+	// TableMapper maps the table names to the struct names.
+	TableMapper name.Mapper
+	// ColumnMapper maps the column names to the field names.
+	ColumnMapper name.Mapper
+	// TagName is the key of the struct tags.
+	TagName string
+	// Template generates the file, nil means DefaultTemplate.
+	Template *template.Template
+}
+
+// DefaultOptions can be used if you don't want to think about options.
+var DefaultOptions = &Options{
+	Package:      "models",
+	TableMapper:  name.SnakeMapper{},
+	ColumnMapper: name.SnakeMapper{},
+	TagName:      "orm",
+}
+
+// File represents the generated file
+type File struct {
+	Package string
+	Imports []string
+	Models  []*Model
+}
+
+// Model represents the struct of a table
+type Model struct {
+	Name    string
+	Comment string
+	// TableName is the name of the table if the table mapper maps the struct
+	// name to another one, the struct needs a TableName method then
+	TableName string
+	Fields    []*Field
+}
+
+// Field represents the field of a column
+type Field struct {
+	Name    string
+	Type    string
+	Tag     string
+	Comment string
+}
+
+var (
+	createdNames = map[string]bool{"created": true, "created_at": true, "create_time": true, "created_time": true, "gmt_create": true}
+	updatedNames = map[string]bool{"updated": true, "updated_at": true, "update_time": true, "updated_time": true, "modified": true, "modified_at": true, "gmt_modified": true}
+	deletedNames = map[string]bool{"deleted": true, "deleted_at": true, "delete_time": true, "deleted_time": true}
+)
+
+// Generate writes the Go structs of the tables to w
+func Generate(w io.Writer, tables []*schema.Table, options *Options) error {
+	tmpl := options.Template
+	if tmpl == nil {
+		var err error
+		if tmpl, err = template.New("reverse").Parse(DefaultTemplate); err != nil {
+			return err
+		}
+	}
+	file := NewFile(tables, options)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, file); err != nil {
+		return err
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated code is invalid: %w", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// NewFile returns the models of the tables, sorted by the names of the tables
+func NewFile(tables []*schema.Table, options *Options) *File {
+	file := &File{
+		Package: options.Package,
+	}
+	imports := make(map[string]bool)
+	tables = append([]*schema.Table{}, tables...)
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	for _, table := range tables {
+		file.Models = append(file.Models, NewModel(table, options, imports))
+	}
+	for imp := range imports {
+		file.Imports = append(file.Imports, imp)
+	}
+	sort.Strings(file.Imports)
+	return file
+}
+
+// NewModel returns the model of the table, the packages of the field types are
+// added to imports
+func NewModel(table *schema.Table, options *Options, imports map[string]bool) *Model {
+	model := &Model{
+		Name:    options.TableMapper.Table2Obj(table.Name),
+		Comment: sanitize(table.Comment),
+	}
+	if options.TableMapper.Obj2Table(model.Name) != table.Name {
+		model.TableName = table.Name
+	}
+	for _, col := range table.Columns() {
+		tp := schema.SQLType2Type(col.SQLType)
+		if tp.PkgPath() != "" {
+			imports[tp.PkgPath()] = true
+		}
+		typeName := tp.String()
+		if tp == schema.BytesType {
+			typeName = "[]byte"
+		}
+		field := &Field{
+			Name:    options.ColumnMapper.Table2Obj(col.Name),
+			Type:    typeName,
+			Comment: sanitize(col.Comment),
+		}
+		field.Tag = fmt.Sprintf(`%s:"%s"`, options.TagName, strings.Join(columnTags(table, col, field.Name, options), " "))
+		model.Fields = append(model.Fields, field)
+	}
+	return model
+}
+
+// columnTags returns the tags of the field of the column
+func columnTags(table *schema.Table, col *schema.Column, fieldName string, options *Options) []string {
+	var tags []string
+	if options.ColumnMapper.Obj2Table(fieldName) != col.Name {
+		tags = append(tags, "'"+col.Name+"'")
+	}
+	if col.IsPrimaryKey {
+		tags = append(tags, "pk")
+	}
+	if col.IsAutoIncrement {
+		tags = append(tags, "autoincr")
+	}
+	tags = append(tags, sqlTypeTag(col))
+	if !col.Nullable && !col.IsPrimaryKey {
+		tags = append(tags, "notnull")
+	}
+	if !col.DefaultIsEmpty && col.Default != "" && !strings.ContainsAny(col.Default, "\"`()") {
+		tags = append(tags, "default("+col.Default+")")
+	}
+	tags = append(tags, indexTags(table, col)...)
+	if col.SQLType.IsTime() {
+		switch lName := strings.ToLower(col.Name); {
+		case createdNames[lName]:
+			tags = append(tags, "created")
+		case updatedNames[lName]:
+			tags = append(tags, "updated")
+		case deletedNames[lName]:
+			tags = append(tags, "deleted")
+		}
+	}
+	if comment := sanitize(col.Comment); comment != "" {
+		tags = append(tags, "comment('"+comment+"')")
+	}
+	return tags
+}
+
+// sqlTypeTag returns the type of the column, varchar(255) or decimal(10,2)
+func sqlTypeTag(col *schema.Column) string {
+	tp := strings.ToLower(col.SQLType.Name)
+	switch {
+	case col.Length2 > 0:
+		return fmt.Sprintf("%s(%d,%d)", tp, col.Length, col.Length2)
+	case col.Length > 0:
+		return fmt.Sprintf("%s(%d)", tp, col.Length)
+	}
+	return tp
+}
+
+// indexTags returns the index and unique tags of the column, the index named
+// like the column is the one of the index tag without name
+func indexTags(table *schema.Table, col *schema.Column) []string {
+	names := make([]string, 0, len(col.Indexes))
+	for name := range col.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var tags []string
+	for _, name := range names {
+		tag := "index"
+		if col.Indexes[name] == schema.UniqueType {
+			tag = "unique"
+		}
+		if index, ok := table.Indexes[name]; ok && name == col.Name && len(index.Cols) == 1 {
+			tags = append(tags, tag)
+		} else {
+			tags = append(tags, tag+"("+name+")")
+		}
+	}
+	return tags
+}
+
+// sanitize removes the characters which could not be in a tag or a comment
+func sanitize(s string) string {
+	s = strings.NewReplacer("'", "", "`", "", `"`, "", "\r", " ", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,115 @@
+package reverse
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/bhojpur/dbm/pkg/orm/name"
+	"github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTable(name string) *schema.Table {
+	table := schema.NewEmptyTable()
+	table.Name = name
+	table.Comment = "holds the users"
+	id := schema.NewColumn("id", "", schema.SQLType{Name: schema.BigInt}, 0, 0, false)
+	id.IsPrimaryKey = true
+	id.IsAutoIncrement = true
+	table.AddColumn(id)
+	table.PrimaryKeys = []string{"id"}
+	email := schema.NewColumn("email", "", schema.SQLType{Name: schema.Varchar}, 255, 0, false)
+	email.Indexes["email"] = schema.UniqueType
+	email.Comment = "the user's login"
+	table.AddColumn(email)
+	tenant := schema.NewColumn("tenant_id", "", schema.SQLType{Name: schema.Int}, 0, 0, false)
+	tenant.Indexes["tenant_name"] = schema.IndexType
+	tenant.Default = "0"
+	tenant.DefaultIsEmpty = false
+	table.AddColumn(tenant)
+	balance := schema.NewColumn("balance", "", schema.SQLType{Name: schema.Decimal}, 10, 2, true)
+	table.AddColumn(balance)
+	avatar := schema.NewColumn("avatar", "", schema.SQLType{Name: schema.Blob}, 0, 0, true)
+	table.AddColumn(avatar)
+	created := schema.NewColumn("created_at", "", schema.SQLType{Name: schema.DateTime}, 0, 0, true)
+	table.AddColumn(created)
+	deleted := schema.NewColumn("deleted_at", "", schema.SQLType{Name: schema.DateTime}, 0, 0, true)
+	table.AddColumn(deleted)
+	table.AddIndex(&schema.Index{Name: "email", Type: schema.UniqueType, Cols: []string{"email"}, IsRegular: true})
+	table.AddIndex(&schema.Index{Name: "tenant_name", Type: schema.IndexType, Cols: []string{"tenant_id", "name"}, IsRegular: true})
+	return table
+}
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Generate(&buf, []*schema.Table{newTable("user_info")}, DefaultOptions))
+	assert.EqualValues(t, `// Code generated by dbm orm reverse.
+
+package models
+
+import (
+	"time"
+)
+
+// UserInfo holds the users
+type UserInfo struct {
+	Id        int64     `+"`"+`orm:"pk autoincr bigint"`+"`"+`
+	Email     string    `+"`"+`orm:"varchar(255) notnull unique comment('the users login')"`+"`"+` // the users login
+	TenantId  int       `+"`"+`orm:"int notnull default(0) index(tenant_name)"`+"`"+`
+	Balance   string    `+"`"+`orm:"decimal(10,2)"`+"`"+`
+	Avatar    []byte    `+"`"+`orm:"blob"`+"`"+`
+	CreatedAt time.Time `+"`"+`orm:"datetime created"`+"`"+`
+	DeletedAt time.Time `+"`"+`orm:"datetime deleted"`+"`"+`
+}
+`, buf.String())
+}
+
+func TestGenerateMappers(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Generate(&buf, []*schema.Table{newTable("tb_user_info")}, &Options{
+		Package:      "db",
+		TableMapper:  name.NewPrefixMapper(name.SnakeMapper{}, "tb_"),
+		ColumnMapper: name.GonicMapper{},
+		TagName:      "dbm",
+		Template: template.Must(template.New("names").Parse(`package {{.Package}}
+{{range .Models}}
+var _ = "{{.Name}}{{.TableName}}{{range .Fields}} {{.Name}}{{end}}"
+{{end}}`)),
+	}))
+	assert.EqualValues(t, `package db
+
+var _ = "UserInfo Id Email TenantId Balance Avatar CreatedAt DeletedAt"
+`, buf.String())
+}
+
+func TestGenerateTableName(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Generate(&buf, []*schema.Table{newTable("UserInfo")}, DefaultOptions))
+	assert.Contains(t, buf.String(), `
+// TableName returns the name of the table
+func (Userinfo) TableName() string {
+	return "UserInfo"
+}
+`)
+}
@@ -55,6 +55,10 @@ type Column struct {
 	IsDeleted       bool
 	IsCascade       bool
 	IsVersion       bool
+	IsShardKey      bool
+	IsTenant        bool
+	IsEncrypted     bool // the values are encrypted by the key provider of the engine
+	IsDeterministic bool // the equal values are encrypted to the equal ciphertexts
 	DefaultIsEmpty  bool // false means column has no default set, but not default value is empty
 	EnumOptions     map[string]int
 	SetOptions      map[string]int
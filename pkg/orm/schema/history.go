@@ -0,0 +1,96 @@
+package schema
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// the bookkeeping columns of the history tables recording the changes of the
+// audited tables, the other columns are the ones of the audited table
+const (
+	HistoryID        = "orm_history_id"
+	HistoryOp        = "orm_op"
+	HistoryActor     = "orm_actor"
+	HistoryChangedAt = "orm_changed_at"
+	HistoryBefore    = "orm_before"
+)
+
+// the operations recorded in the history tables
+const (
+	HistoryInsert     = "I"
+	HistoryUpdate     = "U"
+	HistoryDelete     = "D"
+	HistorySoftDelete = "S"
+)
+
+// HistoryName returns the name of the history table of the table named tableName
+func (table *Table) HistoryName(tableName string) string {
+	if table.History != "" {
+		return table.History
+	}
+	return tableName + "_history"
+}
+
+// NewHistoryChangedAtColumn returns the column of the history tables holding the
+// time of the change
+func NewHistoryChangedAtColumn() *Column {
+	return NewColumn(HistoryChangedAt, "", SQLType{Name: DateTime}, 0, 0, false)
+}
+
+// HistoryTable returns the history table of the table named tableName, every
+// row holds the image of a row after an insert or update, or before a delete,
+// with the image before the change as JSON
+func (table *Table) HistoryTable(tableName string) *Table {
+	history := NewEmptyTable()
+	history.Name = table.HistoryName(tableName)
+	history.StoreEngine = table.StoreEngine
+	history.Charset = table.Charset
+
+	id := NewColumn(HistoryID, "", SQLType{Name: BigInt}, 0, 0, false)
+	id.IsPrimaryKey = true
+	id.IsAutoIncrement = true
+	history.AddColumn(id)
+	history.AddColumn(NewColumn(HistoryOp, "", SQLType{Name: Varchar}, 1, 0, false))
+	history.AddColumn(NewColumn(HistoryActor, "", SQLType{Name: Varchar}, 255, 0, true))
+	history.AddColumn(NewHistoryChangedAtColumn())
+	history.AddColumn(NewColumn(HistoryBefore, "", SQLType{Name: Text}, 0, 0, true))
+
+	index := NewIndex("pk", IndexType)
+	for _, col := range table.Columns() {
+		sqlType := col.SQLType
+		switch sqlType.Name {
+		case Serial:
+			sqlType.Name = Int
+		case BigSerial:
+			sqlType.Name = BigInt
+		}
+		c := NewColumn(col.Name, col.FieldName, sqlType, col.Length, col.Length2, true)
+		c.EnumOptions = col.EnumOptions
+		c.SetOptions = col.SetOptions
+		c.Comment = col.Comment
+		history.AddColumn(c)
+		if col.IsPrimaryKey {
+			index.AddColumn(col.Name)
+			c.Indexes[index.Name] = IndexType
+		}
+	}
+	if len(index.Cols) > 0 {
+		history.AddIndex(index)
+	}
+	return history
+}
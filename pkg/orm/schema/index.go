@@ -37,11 +37,16 @@ type Index struct {
 	Name      string
 	Type      int
 	Cols      []string
+	// Method is the index access method, i.e. GIN on postgres, empty is the default one
+	Method string
+	// Paths holds the JSON paths indexed instead of the columns at the same
+	// positions of Cols, an empty path indexes the column itself
+	Paths []string
 }
 
 // NewIndex new an index object
 func NewIndex(name string, indexType int) *Index {
-	return &Index{true, name, indexType, make([]string, 0)}
+	return &Index{IsRegular: true, Name: name, Type: indexType, Cols: make([]string, 0)}
 }
 
 // XName returns the special index name for the table
@@ -63,11 +68,44 @@ func (index *Index) AddColumn(cols ...string) {
 	index.Cols = append(index.Cols, cols...)
 }
 
+// AddJSONPath add an index on the value at the JSON path of the column
+func (index *Index) AddJSONPath(col, path string) {
+	for len(index.Paths) < len(index.Cols) {
+		index.Paths = append(index.Paths, "")
+	}
+	index.Cols = append(index.Cols, col)
+	index.Paths = append(index.Paths, path)
+}
+
+// IsExpr returns true if the index has a JSON path expression
+func (index *Index) IsExpr() bool {
+	for _, path := range index.Paths {
+		if path != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the JSON path indexed at the position of Cols, empty if the
+// column itself is indexed
+func (index *Index) Path(i int) string {
+	if i < len(index.Paths) {
+		return index.Paths[i]
+	}
+	return ""
+}
+
 // Equal return true if the two Index is equal
 func (index *Index) Equal(dst *Index) bool {
 	if index.Type != dst.Type {
 		return false
 	}
+	// the expressions read back from the database are not comparable with the
+	// JSON paths, so the expression indexes are compared by name
+	if index.IsExpr() || dst.IsExpr() {
+		return index.Name == dst.Name
+	}
 	if len(index.Cols) != len(dst.Cols) {
 		return false
 	}
@@ -0,0 +1,75 @@
+package schema
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"reflect"
+
+	"github.com/bhojpur/sql/pkg/builder"
+)
+
+// Scope returns the conditions of a named scope, it's called for every query
+type Scope func() builder.Cond
+
+// Scoper is implemented by the beans having named scopes, e.g. active or published
+type Scoper interface {
+	Scopes() map[string]Scope
+}
+
+// DefaultScoper is implemented by the beans having scopes applied to all the queries
+type DefaultScoper interface {
+	DefaultScopes() []string
+}
+
+var (
+	tpScoper        = reflect.TypeOf((*Scoper)(nil)).Elem()
+	tpDefaultScoper = reflect.TypeOf((*DefaultScoper)(nil)).Elem()
+)
+
+// beanOf returns a new bean of the table implementing tp
+func (table *Table) beanOf(tp reflect.Type) interface{} {
+	if table.Type == nil {
+		return nil
+	}
+	if table.Type.Implements(tp) {
+		return reflect.New(table.Type).Elem().Interface()
+	}
+	if reflect.PtrTo(table.Type).Implements(tp) {
+		return reflect.New(table.Type).Interface()
+	}
+	return nil
+}
+
+// Scopes returns the named scopes of the table
+func (table *Table) Scopes() map[string]Scope {
+	if bean := table.beanOf(tpScoper); bean != nil {
+		return bean.(Scoper).Scopes()
+	}
+	return nil
+}
+
+// DefaultScopes returns the names of the scopes applied to all the queries on the table
+func (table *Table) DefaultScopes() []string {
+	if bean := table.beanOf(tpDefaultScoper); bean != nil {
+		return bean.(DefaultScoper).DefaultScopes()
+	}
+	return nil
+}
@@ -40,9 +40,15 @@ type Table struct {
 	Updated       string
 	Deleted       string
 	Version       string
+	ShardKey      string
+	Tenant        string
 	StoreEngine   string
 	Charset       string
 	Comment       string
+	// Audited tables record their changes in a history table named History,
+	// or the table name with a _history suffix if it's empty
+	Audited bool
+	History string
 }
 
 // NewEmptyTable creates an empty table
@@ -128,6 +134,16 @@ func (table *Table) DeletedColumn() *Column {
 	return table.GetColumn(table.Deleted)
 }
 
+// ShardKeyColumn returns shard key column's information
+func (table *Table) ShardKeyColumn() *Column {
+	return table.GetColumn(table.ShardKey)
+}
+
+// TenantColumn returns tenant column's information
+func (table *Table) TenantColumn() *Column {
+	return table.GetColumn(table.Tenant)
+}
+
 // AddColumn adds a column to table
 func (table *Table) AddColumn(col *Column) {
 	table.columnsSeq = append(table.columnsSeq, col.Name)
@@ -156,6 +172,12 @@ func (table *Table) AddColumn(col *Column) {
 	if col.IsVersion {
 		table.Version = col.Name
 	}
+	if col.IsShardKey {
+		table.ShardKey = col.Name
+	}
+	if col.IsTenant {
+		table.Tenant = col.Name
+	}
 }
 
 // AddIndex adds an index or an unique to table
@@ -33,14 +33,23 @@ type DBType string
 
 // enumerates all database types
 const (
-	POSTGRES DBType = "postgres"
-	SQLITE   DBType = "sqlite3"
-	MYSQL    DBType = "mysql"
-	MSSQL    DBType = "mssql"
-	ORACLE   DBType = "oracle"
-	DAMENG   DBType = "dameng"
+	POSTGRES   DBType = "postgres"
+	SQLITE     DBType = "sqlite3"
+	MYSQL      DBType = "mysql"
+	MSSQL      DBType = "mssql"
+	ORACLE     DBType = "oracle"
+	DAMENG     DBType = "dameng"
+	CLICKHOUSE DBType = "clickhouse"
+	COCKROACH  DBType = "cockroach"
+	YUGABYTE   DBType = "yugabyte"
 )
 
+// IsPostgres returns true if the database is postgres or speaks its dialect,
+// like cockroach and yugabyte
+func (dbType DBType) IsPostgres() bool {
+	return dbType == POSTGRES || dbType == COCKROACH || dbType == YUGABYTE
+}
+
 // SQLType represents SQL types
 type SQLType struct {
 	Name           string
@@ -168,6 +177,8 @@ var (
 	Jsonb             = "JSONB"
 	XML               = "XML"
 	Array             = "ARRAY"
+	LowCardinality    = "LOWCARDINALITY"
+	DateTime64        = "DATETIME64"
 	SqlTypes          = map[string]int{
 		Bit:               NUMERIC_TYPE,
 		UnsignedBit:       NUMERIC_TYPE,
@@ -229,6 +240,8 @@ var (
 		BigSerial:         NUMERIC_TYPE,
 		"INT8":            NUMERIC_TYPE,
 		Array:             ARRAY_TYPE,
+		LowCardinality:    TEXT_TYPE,
+		DateTime64:        TIME_TYPE,
 	}
 )
 
@@ -329,13 +342,13 @@ func SQLType2Type(st SQLType) reflect.Type {
 		return Float32Type
 	case Double:
 		return Float64Type
-	case Char, NChar, Varchar, NVarchar, TinyText, Text, NText, MediumText, LongText, Enum, Set, Uuid, Clob, SysName:
+	case Char, NChar, Varchar, NVarchar, TinyText, Text, NText, MediumText, LongText, Enum, Set, Uuid, Clob, SysName, LowCardinality:
 		return StringType
 	case TinyBlob, Blob, LongBlob, Bytea, Binary, MediumBlob, VarBinary, UniqueIdentifier:
 		return BytesType
 	case Bool:
 		return BoolType
-	case DateTime, Date, Time, TimeStamp, TimeStampz, SmallDateTime, Year:
+	case DateTime, Date, Time, TimeStamp, TimeStampz, SmallDateTime, Year, DateTime64:
 		return TimeType
 	case Decimal, Numeric, Money, SmallMoney:
 		return StringType
@@ -98,6 +98,15 @@ type Session struct {
 	lastSQLArgs     []interface{}
 	ctx             context.Context
 	sessionType     sessionType
+	// tables changed by raw SQL in the transaction, cleared again on commit
+	txCacheTables map[string]bool
+	// the number of nested transactions running in savepoints
+	txDepth int
+	// events published after the commit, and their counts at the savepoints
+	txEvents     []*Event
+	txEventMarks map[string]int
+	// the values of the beans loaded in tracked mode by their keys
+	tracked map[string]map[string]interface{}
 }
 
 func newSessionID() string {
@@ -144,6 +153,8 @@ func newSession(engine *Engine) *Session {
 		lastSQLArgs:            make([]interface{}, 0),
 		sessionType:            engineSession,
 	}
+	session.statement.Encryptor = engine.encryptor
+	session.statement.TenantID = ctxsvr.Tenant(ctx)
 	if engine.logSessionID {
 		session.ctx = context.WithValue(session.ctx, log.SessionKey, session)
 	}
@@ -258,7 +269,32 @@ func (session *Session) NoCascade() *Session {
 
 // ForUpdate Set Read/Write locking for UPDATE
 func (session *Session) ForUpdate() *Session {
-	session.statement.IsForUpdate = true
+	session.statement.ForUpdate()
+	return session
+}
+
+// ForShare locks the selected rows in share mode, SELECT ... FOR SHARE
+func (session *Session) ForShare() *Session {
+	session.statement.ForShare()
+	return session
+}
+
+// NoWait makes the locking SELECT fail at once when a row is locked, it implies ForUpdate
+func (session *Session) NoWait() *Session {
+	session.statement.NoWait()
+	return session
+}
+
+// SkipLocked makes the locking SELECT leave out the locked rows, it implies ForUpdate.
+// The workers of a job queue use it to pick different jobs.
+func (session *Session) SkipLocked() *Session {
+	session.statement.SkipLocked()
+	return session
+}
+
+// LockOf locks only the rows of the tables of a join, it implies ForUpdate
+func (session *Session) LockOf(tables ...string) *Session {
+	session.statement.LockOf(tables...)
 	return session
 }
 
@@ -293,7 +329,7 @@ func (session *Session) Asc(colNames ...string) *Session {
 	return session
 }
 
-// StoreEngine is only avialble mysql dialect currently
+// StoreEngine is only avialble mysql and clickhouse dialects currently
 func (session *Session) StoreEngine(storeEngine string) *Session {
 	session.statement.StoreEngine = storeEngine
 	return session
@@ -348,6 +384,43 @@ func (session *Session) Having(conditions string) *Session {
 	return session
 }
 
+// With adds a common table expression named as name to the query, the name
+// could carry the column list as "name (col1, col2)"
+func (session *Session) With(name string, query interface{}, args ...interface{}) *Session {
+	session.statement.With(name, query, args...)
+	return session
+}
+
+// WithRecursive adds a common table expression which could refer to itself
+func (session *Session) WithRecursive(name string, query interface{}, args ...interface{}) *Session {
+	session.statement.WithRecursive(name, query, args...)
+	return session
+}
+
+// Union combines the rows with the rows of another query without duplicates
+func (session *Session) Union(query interface{}, args ...interface{}) *Session {
+	session.statement.Union(query, args...)
+	return session
+}
+
+// UnionAll combines the rows with all the rows of another query
+func (session *Session) UnionAll(query interface{}, args ...interface{}) *Session {
+	session.statement.UnionAll(query, args...)
+	return session
+}
+
+// Intersect keeps the rows another query returns too
+func (session *Session) Intersect(query interface{}, args ...interface{}) *Session {
+	session.statement.Intersect(query, args...)
+	return session
+}
+
+// Except keeps the rows another query does not return
+func (session *Session) Except(query interface{}, args ...interface{}) *Session {
+	session.statement.Except(query, args...)
+	return session
+}
+
 // DB db return the wrapper of sql.DB
 func (session *Session) DB() *core.DB {
 	return session.db()
@@ -356,6 +429,7 @@ func (session *Session) canCache() bool {
 	if session.statement.RefTable == nil ||
 		session.statement.JoinStr != "" ||
 		session.statement.RawSQL != "" ||
+		session.statement.IsCompound() ||
 		!session.statement.UseCache ||
 		session.statement.IsForUpdate ||
 		session.tx != nil ||
@@ -364,6 +438,29 @@ func (session *Session) canCache() bool {
 	}
 	return true
 }
+
+// canCacheJoin returns true if a joined Find could cache its ids, the beans
+// are always loaded from the main table so the struct could not extend others
+func (session *Session) canCacheJoin() bool {
+	table := session.statement.RefTable
+	if table == nil ||
+		session.statement.JoinStr == "" ||
+		session.statement.RawSQL != "" ||
+		session.statement.IsCompound() ||
+		!session.statement.UseCache ||
+		session.statement.IsForUpdate ||
+		session.tx != nil ||
+		len(session.statement.SelectStr) > 0 ||
+		len(table.PrimaryKeys) == 0 {
+		return false
+	}
+	for _, col := range table.Columns() {
+		if len(col.FieldIndex) > 1 {
+			return false
+		}
+	}
+	return true
+}
 func (session *Session) doPrepare(db *core.DB, sqlStr string) (stmt *core.Stmt, err error) {
 	crc := crc32.ChecksumIEEE([]byte(sqlStr))
 	// TODO try hash(sqlStr+len(sqlStr))
@@ -527,6 +624,16 @@ func (session *Session) convertBeanField(col *schemasvr.Column, fieldValue *refl
 	if scanResult == nil {
 		return nil
 	}
+	if col.IsEncrypted {
+		plaintext, err := session.statement.DecryptValue(col, scanResult)
+		if err != nil {
+			return err
+		}
+		if plaintext == nil {
+			return nil
+		}
+		scanResult = plaintext
+	}
 	if fieldValue.CanAddr() {
 		if structConvert, ok := fieldValue.Addr().Interface().(convert.Conversion); ok {
 			data, ok := convert.AsBytes(scanResult)
@@ -666,6 +773,7 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 	buildAfterProcessors(session, bean)
 	var tempMap = make(map[string]int)
 	var pk schemasvr.PK
+	var trackCols []*schemasvr.Column
 	for i, colName := range fields {
 		var idx int
 		var lKey = strings.ToLower(colName)
@@ -691,6 +799,14 @@ func (session *Session) slice2Bean(scanResults []interface{}, fields []string, b
 		if col.IsPrimaryKey {
 			pk = append(pk, scanResults[i])
 		}
+		if session.tracked != nil {
+			trackCols = append(trackCols, col)
+		}
+	}
+	if session.tracked != nil && table != nil && table.Type != nil {
+		if err := session.track(table, bean, trackCols); err != nil {
+			return nil, err
+		}
 	}
 	return pk, nil
 }
@@ -711,6 +827,26 @@ func (session *Session) Unscoped() *Session {
 	session.statement.SetUnscoped()
 	return session
 }
+
+// AllTenants disables struct tag "tenant", the admin jobs work on the rows of all the tenants
+func (session *Session) AllTenants() *Session {
+	session.statement.AllTenants()
+	return session
+}
+
+// Scopes applies the named scopes of the table, see schemasvr.Scoper
+func (session *Session) Scopes(names ...string) *Session {
+	session.statement.Scopes(names...)
+	return session
+}
+
+// WithoutScopes disables the named default scopes of the table, all of them if
+// no name is given
+func (session *Session) WithoutScopes(names ...string) *Session {
+	session.statement.WithoutScopes(names...)
+	return session
+}
+
 func (session *Session) incrVersionFieldValue(fieldValue *reflect.Value) {
 	switch fieldValue.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -728,6 +864,7 @@ func (session *Session) Context(ctx context.Context) *Session {
 		ctx = context.WithValue(ctx, log.SessionShowSQLKey, session.ctx.Value(log.SessionShowSQLKey))
 	}
 	session.ctx = ctx
+	session.statement.TenantID = ctxsvr.Tenant(ctx)
 	return session
 }
 
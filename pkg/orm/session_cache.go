@@ -0,0 +1,217 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"strings"
+
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// backslashEscapes tells whether the string literals of the dialect escape
+// their quotes with a backslash
+func (engine *Engine) backslashEscapes() bool {
+	return engine.dialect.URI().DBType == schemasvr.MYSQL
+}
+
+// sqlTableNames returns the tables referenced by the sql without schema
+func sqlTableNames(sqlStr string, backslashEscapes bool) []string {
+	tables := utils.SQLTables(sqlStr, backslashEscapes)
+	for i, table := range tables {
+		if idx := strings.LastIndexByte(table, '.'); idx > -1 {
+			tables[i] = table[idx+1:]
+		}
+	}
+	return tables
+}
+
+// clearTablesCache clears the cached sqls of the tables and of the tables
+// whose cached join queries depend on them, the cached beans are cleared too
+// if clearBeans is true
+func (engine *Engine) clearTablesCache(clearBeans bool, tables ...string) {
+	for _, table := range tables {
+		if cacher := engine.GetCacher(table); cacher != nil {
+			engine.logger.Debugf("[cache] clear table: %v", table)
+			cacher.ClearIds(table)
+			if clearBeans {
+				cacher.ClearBeans(table)
+			}
+		}
+		engine.clearDependentIds(table)
+	}
+}
+
+// clearDependentIds clears the cached join queries depending on the table
+func (engine *Engine) clearDependentIds(table string) {
+	for _, dependent := range engine.cacherMgr.Dependents(table) {
+		if cacher := engine.GetCacher(dependent); cacher != nil {
+			engine.logger.Debugf("[cache] clear dependent table: %v of %v", dependent, table)
+			cacher.ClearIds(dependent)
+		}
+	}
+}
+
+// rawInvalidation represents the cache to be cleared after a raw sql executed
+type rawInvalidation struct {
+	tables     []string
+	clearBeans bool
+	// ids of the beans changed by an UPDATE, the beans of the table don't
+	// need to be cleared if they are known
+	table string
+	ids   []string
+	known bool
+}
+
+// newRawInvalidation parses the tables a non SELECT sql will change
+func newRawInvalidation(sqlStr string, backslashEscapes bool) *rawInvalidation {
+	op := utils.SQLOperation(sqlStr, backslashEscapes)
+	if op == "" || op == "SELECT" {
+		return nil
+	}
+	tables := sqlTableNames(sqlStr, backslashEscapes)
+	if len(tables) == 0 {
+		return nil
+	}
+	return &rawInvalidation{
+		tables:     tables,
+		clearBeans: op != "INSERT",
+	}
+}
+
+// prepareRawInvalidation parses the tables a non SELECT sql will change. For
+// an UPDATE of the session's table the ids of the changed rows are loaded via
+// ConvertUpdateSQL so that only their beans will be evicted.
+func (session *Session) prepareRawInvalidation(sqlStr string, args []interface{}) *rawInvalidation {
+	backslashEscapes := session.engine.backslashEscapes()
+	inv := newRawInvalidation(sqlStr, backslashEscapes)
+	if inv == nil {
+		return nil
+	}
+	table := session.statement.RefTable
+	if utils.SQLOperation(sqlStr, backslashEscapes) != "UPDATE" || len(inv.tables) != 1 || table == nil ||
+		table.Name != inv.tables[0] || !session.statement.UseCache ||
+		session.engine.GetCacher(table.Name) == nil {
+		return inv
+	}
+	ids, ok := session.loadUpdatedIDs(table, sqlStr, args)
+	if ok {
+		inv.table = table.Name
+		inv.ids = ids
+		inv.known = true
+	}
+	return inv
+}
+
+func (session *Session) loadUpdatedIDs(table *schemasvr.Table, sqlStr string, args []interface{}) ([]string, bool) {
+	oldhead, newsql := session.statement.ConvertUpdateSQL(sqlStr)
+	if newsql == "" {
+		return nil, false
+	}
+	var nStart int
+	if len(args) > 0 {
+		if strings.Contains(sqlStr, "?") {
+			nStart = strings.Count(oldhead, "?")
+		} else {
+			nStart = strings.Count(oldhead, "$")
+		}
+	}
+	if nStart > len(args) {
+		return nil, false
+	}
+	// the query resets the statement which is still needed by the update
+	prepareStmt, useCache := session.prepareStmt, session.statement.UseCache
+	autoReset := session.autoResetStatement
+	session.autoResetStatement = false
+	defer func() {
+		session.prepareStmt, session.statement.UseCache = prepareStmt, useCache
+		session.autoResetStatement = autoReset
+	}()
+	rows, err := session.NoCache().queryRows(newsql, args[nStart:]...)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		if len(ids) >= 500 {
+			return nil, false
+		}
+		var res = make([]string, len(table.PrimaryKeys))
+		if err := rows.ScanSlice(&res); err != nil {
+			return nil, false
+		}
+		var pk schemasvr.PK = make([]interface{}, len(table.PrimaryKeys))
+		for i, col := range table.PKColumns() {
+			if pk[i], err = col.ConvertID(res[i]); err != nil {
+				return nil, false
+			}
+		}
+		sid, err := pk.ToString()
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, sid)
+	}
+	if rows.Err() != nil {
+		return nil, false
+	}
+	return ids, true
+}
+
+// applyRawInvalidation clears the cache after the raw sql executed, in a
+// transaction the tables are cleared again once it is committed
+func (session *Session) applyRawInvalidation(inv *rawInvalidation) {
+	if inv == nil {
+		return
+	}
+	if inv.known {
+		if cacher := session.engine.GetCacher(inv.table); cacher != nil {
+			for _, id := range inv.ids {
+				cacher.DelBean(inv.table, id)
+			}
+		}
+		session.engine.clearTablesCache(false, inv.tables...)
+	} else {
+		session.engine.clearTablesCache(inv.clearBeans, inv.tables...)
+	}
+	if !session.isAutoCommit {
+		if session.txCacheTables == nil {
+			session.txCacheTables = make(map[string]bool)
+		}
+		for _, table := range inv.tables {
+			session.txCacheTables[table] = true
+		}
+	}
+}
+
+// clearTxCacheTables clears the tables changed by raw sqls in the committed transaction
+func (session *Session) clearTxCacheTables() {
+	if len(session.txCacheTables) == 0 {
+		return
+	}
+	var tables = make([]string, 0, len(session.txCacheTables))
+	for table := range session.txCacheTables {
+		tables = append(tables, table)
+	}
+	session.txCacheTables = nil
+	session.engine.clearTablesCache(true, tables...)
+}
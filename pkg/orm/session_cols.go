@@ -98,6 +98,13 @@ func (session *Session) Select(str string) *Session {
 	return session
 }
 
+// Over selects the result of the window function into the column, partitionBy
+// and orderBy are the comma separated expressions of the window
+func (session *Session) Over(column, function, partitionBy, orderBy string) *Session {
+	session.statement.Over(column, function, partitionBy, orderBy)
+	return session
+}
+
 // Cols provides some columns to special
 func (session *Session) Cols(columns ...string) *Session {
 	session.statement.Cols(columns...)
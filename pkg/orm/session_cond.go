@@ -65,6 +65,13 @@ func (session *Session) NotIn(column string, args ...interface{}) *Session {
 	return session
 }
 
+// EncryptedEq provides the condition column = arg on a column tagged
+// encrypted(deterministic), the rows written with the older keys are matched too
+func (session *Session) EncryptedEq(column string, arg interface{}) *Session {
+	session.statement.EncryptedEq(column, arg)
+	return session
+}
+
 // Conds returns session query conditions except auto bean conditions
 func (session *Session) Conds() builder.Cond {
 	return session.statement.Conds()
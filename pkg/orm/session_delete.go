@@ -132,15 +132,25 @@ func (session *Session) Delete(beans ...interface{}) (int64, error) {
 	if len(condSQL) == 0 && (pLimitN == nil || *pLimitN == 0) {
 		return 0, ErrNeedDeletedCond
 	}
+	scopeCond, err := session.statement.CondScopes(session.statement.RefTable, false)
+	if err != nil {
+		return 0, err
+	}
+	scopeSQL, scopeArgs, err := session.statement.GenCondSQL(scopeCond)
+	if err != nil {
+		return 0, err
+	}
+	if len(scopeSQL) > 0 {
+		if len(condSQL) > 0 {
+			condSQL = fmt.Sprintf("(%s) AND %s", condSQL, scopeSQL)
+		} else {
+			condSQL = scopeSQL
+		}
+		condArgs = append(condArgs, scopeArgs...)
+	}
 	var tableNameNoQuote = session.statement.TableName()
 	var tableName = session.engine.Quote(tableNameNoQuote)
 	var table = session.statement.RefTable
-	var deleteSQL string
-	if len(condSQL) > 0 {
-		deleteSQL = fmt.Sprintf("DELETE FROM %v WHERE %v", tableName, condSQL)
-	} else {
-		deleteSQL = fmt.Sprintf("DELETE FROM %v", tableName)
-	}
 	var orderSQL string
 	if len(session.statement.OrderStr) > 0 {
 		orderSQL += fmt.Sprintf(" ORDER BY %s", session.statement.OrderStr)
@@ -150,29 +160,32 @@ func (session *Session) Delete(beans ...interface{}) (int64, error) {
 		orderSQL += fmt.Sprintf(" LIMIT %d", limitNValue)
 	}
 	if len(orderSQL) > 0 {
-		switch session.engine.dialect.URI().DBType {
-		case schemasvr.POSTGRES:
-			inSQL := fmt.Sprintf("ctid IN (SELECT ctid FROM %s%s)", tableName, orderSQL)
-			if len(condSQL) > 0 {
-				deleteSQL += " AND " + inSQL
-			} else {
-				deleteSQL += " WHERE " + inSQL
-			}
-		case schemasvr.SQLITE:
-			inSQL := fmt.Sprintf("rowid IN (SELECT rowid FROM %s%s)", tableName, orderSQL)
-			if len(condSQL) > 0 {
-				deleteSQL += " AND " + inSQL
-			} else {
-				deleteSQL += " WHERE " + inSQL
-			}
-			// TODO: how to handle delete limit on mssql?
-		case schemasvr.MSSQL:
+		// TODO: how to handle delete limit on mssql?
+		if session.engine.dialect.URI().DBType == schemasvr.MSSQL {
 			return 0, ErrNotImplemented
-		default:
-			deleteSQL += orderSQL
 		}
+		var whereSQL string
+		if len(condSQL) > 0 {
+			whereSQL = " WHERE " + condSQL
+		}
+		// the conditions move to the rows limited by the sub query
+		if inSQL, ok := session.statement.LimitRowsCond(tableName, whereSQL+orderSQL); ok {
+			condSQL, orderSQL = inSQL, ""
+		}
+	}
+	var deleteSQL string
+	if len(condSQL) > 0 {
+		deleteSQL = fmt.Sprintf("DELETE FROM %v WHERE %v", tableName, condSQL)
+	} else {
+		deleteSQL = fmt.Sprintf("DELETE FROM %v", tableName)
 	}
-	var realSQL string
+	deleteSQL += orderSQL
+	var (
+		realSQL   string
+		historyOp = schemasvr.HistoryDelete
+		// the SQL before the WHERE clause, which selects the history images
+		realPrefix = "DELETE FROM " + tableName
+	)
 	argsForCache := make([]interface{}, 0, len(condArgs)*2)
 	if session.statement.GetUnscoped() || table == nil || table.DeletedColumn() == nil { // tag "deleted" is disabled
 		realSQL = deleteSQL
@@ -183,33 +196,15 @@ func (session *Session) Delete(beans ...interface{}) (int64, error) {
 		copy(argsForCache, condArgs)
 		argsForCache = append(condArgs, argsForCache...)
 		deletedColumn := table.DeletedColumn()
-		realSQL = fmt.Sprintf("UPDATE %v SET %v = ? WHERE %v",
+		historyOp = schemasvr.HistorySoftDelete
+		realPrefix = fmt.Sprintf("UPDATE %v SET %v = ?",
 			session.engine.Quote(session.statement.TableName()),
-			session.engine.Quote(deletedColumn.Name),
-			condSQL)
-		if len(orderSQL) > 0 {
-			switch session.engine.dialect.URI().DBType {
-			case schemasvr.POSTGRES:
-				inSQL := fmt.Sprintf("ctid IN (SELECT ctid FROM %s%s)", tableName, orderSQL)
-				if len(condSQL) > 0 {
-					realSQL += " AND " + inSQL
-				} else {
-					realSQL += " WHERE " + inSQL
-				}
-			case schemasvr.SQLITE:
-				inSQL := fmt.Sprintf("rowid IN (SELECT rowid FROM %s%s)", tableName, orderSQL)
-				if len(condSQL) > 0 {
-					realSQL += " AND " + inSQL
-				} else {
-					realSQL += " WHERE " + inSQL
-				}
-				// TODO: how to handle delete limit on mssql?
-			case schemasvr.MSSQL:
-				return 0, ErrNotImplemented
-			default:
-				realSQL += orderSQL
-			}
+			session.engine.Quote(deletedColumn.Name))
+		realSQL = realPrefix
+		if len(condSQL) > 0 {
+			realSQL += " WHERE " + condSQL
 		}
+		realSQL += orderSQL
 		// !oinume! Insert nowTime to the head of session.statement.Params
 		condArgs = append(condArgs, "")
 		paramsLen := len(condArgs)
@@ -228,8 +223,15 @@ func (session *Session) Delete(beans ...interface{}) (int64, error) {
 	if cacher := session.engine.GetCacher(tableNameNoQuote); cacher != nil && session.statement.UseCache {
 		_ = session.cacheDelete(table, tableNameNoQuote, deleteSQL, argsForCache...)
 	}
+	if session.statement.UseCache {
+		session.engine.clearDependentIds(tableNameNoQuote)
+	}
 	session.statement.RefTable = table
-	res, err := session.exec(realSQL, condArgs...)
+	whereArgs := condArgs
+	if historyOp == schemasvr.HistorySoftDelete {
+		whereArgs = condArgs[1:]
+	}
+	res, err := session.execWithHistory(historyOp, tableName, realSQL[len(realPrefix):], whereArgs, realSQL, condArgs...)
 	if err != nil {
 		return 0, err
 	}
@@ -261,5 +263,14 @@ func (session *Session) Delete(beans ...interface{}) (int64, error) {
 	}
 	cleanupProcessorsClosures(&session.afterClosures)
 	// --
-	return res.RowsAffected()
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	var deletedColumns []string
+	if historyOp == schemasvr.HistorySoftDelete {
+		deletedColumns = []string{table.DeletedColumn().Name}
+	}
+	session.emitEvent(EventDeleted, tableNameNoQuote, bean, deletedColumns, affected)
+	return affected, nil
 }
@@ -0,0 +1,914 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/csv"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/dbm/pkg/orm/convert"
+	"github.com/bhojpur/dbm/pkg/orm/internal/json"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// DataFormat represents the format of the exported and imported rows
+type DataFormat int
+
+// enumerates the data formats
+const (
+	// FormatCSV is comma separated values with a header of the column names,
+	// empty values of non string columns are nulls
+	FormatCSV DataFormat = iota
+	// FormatJSONLines is a JSON object keyed by the column names per line
+	FormatJSONLines
+	// FormatParquet is an Apache Parquet file with a flat schema
+	FormatParquet
+)
+
+// String implements fmt.Stringer
+func (format DataFormat) String() string {
+	switch format {
+	case FormatCSV:
+		return "csv"
+	case FormatJSONLines:
+		return "jsonl"
+	case FormatParquet:
+		return "parquet"
+	}
+	return "DataFormat(" + strconv.Itoa(int(format)) + ")"
+}
+
+// importBatchSize is the beans inserted by one statement while importing
+const importBatchSize = 100
+
+// RowError represents a row failed to import
+type RowError struct {
+	// Row is the 1-based number of the record in the input, the CSV header excluded
+	Row    int
+	Column string
+	Err    error
+}
+
+// Error implements error
+func (e *RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("row %d column %s: %v", e.Row, e.Column, e.Err)
+	}
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportErrors represents the rows failed to import, the other rows have been inserted
+type ImportErrors []*RowError
+
+// Error implements error
+func (errs ImportErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	return fmt.Sprintf("%d rows failed to import, the first: %v", len(errs), errs[0])
+}
+
+var (
+	conversionType = reflect.TypeOf((*convert.Conversion)(nil)).Elem()
+	valuerType     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType    = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// exportColumns returns the columns could be read from the database
+func exportColumns(table *schemasvr.Table) []*schemasvr.Column {
+	var cols = make([]*schemasvr.Column, 0, len(table.Columns()))
+	for _, col := range table.Columns() {
+		if col.MapType != schemasvr.ONLYTODB {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// Export writes the rows of the table set by Table with a bean, the
+// conditions of the session apply. Values are converted with the column
+// mapping of the bean: Conversion implementations are written as ToDB
+// returns, times in the TZLocation of the engine, binary values base64
+// encoded in text formats and JSON columns as JSON text.
+func (session *Session) Export(w io.Writer, format DataFormat) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+	if session.statement.LastError != nil {
+		return 0, session.statement.LastError
+	}
+	table := session.statement.RefTable
+	if table == nil {
+		return 0, ErrTableNotFound
+	}
+	cols := exportColumns(table)
+	ew, err := newExportWriter(w, format, table.Type, cols)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := session.Rows(reflect.New(table.Type).Interface())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	var values = make([]interface{}, len(cols))
+	for rows.Next() {
+		bean := reflect.New(table.Type)
+		if err := rows.Scan(bean.Interface()); err != nil {
+			return count, err
+		}
+		for i, col := range cols {
+			fieldValue, err := col.ValueOfV(&bean)
+			if err != nil {
+				return count, err
+			}
+			if values[i], err = session.exportValue(col, *fieldValue); err != nil {
+				return count, fmt.Errorf("column %s: %w", col.Name, err)
+			}
+		}
+		if err := ew.write(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, ew.close()
+}
+
+// exportValue returns the field value as nil, bool, int64, uint64, float64,
+// string, []byte or time.Time
+func (session *Session) exportValue(col *schemasvr.Column, fieldValue reflect.Value) (interface{}, error) {
+	if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+		return nil, nil
+	}
+	var conversion convert.Conversion
+	if fieldValue.CanAddr() && fieldValue.Addr().Type().Implements(conversionType) {
+		conversion = fieldValue.Addr().Interface().(convert.Conversion)
+	} else if fieldValue.Type().Implements(conversionType) {
+		conversion = fieldValue.Interface().(convert.Conversion)
+	}
+	if conversion != nil {
+		data, err := conversion.ToDB()
+		if err != nil || data == nil {
+			return nil, err
+		}
+		if col.SQLType.IsBlob() {
+			return data, nil
+		}
+		return string(data), nil
+	}
+	if fieldValue.Kind() == reflect.Ptr {
+		fieldValue = fieldValue.Elem()
+	}
+	fieldType := fieldValue.Type()
+	if fieldType.ConvertibleTo(schemasvr.TimeType) {
+		t := fieldValue.Convert(schemasvr.TimeType).Interface().(time.Time)
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t.In(session.engine.TZLocation), nil
+	}
+	if !col.IsJSON && fieldType.Implements(valuerType) {
+		v, err := fieldValue.Interface().(driver.Valuer).Value()
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return exportDriverValue(v), nil
+	}
+	switch fieldValue.Kind() {
+	case reflect.Bool:
+		return fieldValue.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fieldValue.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fieldValue.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return fieldValue.Float(), nil
+	case reflect.String:
+		return fieldValue.String(), nil
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.Uint8 {
+			if fieldValue.IsNil() {
+				return nil, nil
+			}
+			return fieldValue.Bytes(), nil
+		}
+	}
+	data, err := json.DefaultJSONHandler.Marshal(fieldValue.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func exportDriverValue(v driver.Value) interface{} {
+	switch t := v.(type) {
+	case bool, int64, float64, string, []byte, time.Time:
+		return t
+	}
+	return convert.AsString(v)
+}
+
+// exportString formats a value exported as text
+func exportString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return base64.StdEncoding.EncodeToString(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	}
+	return convert.AsString(v)
+}
+
+type exportWriter interface {
+	write(values []interface{}) error
+	close() error
+}
+
+func newExportWriter(w io.Writer, format DataFormat, beanType reflect.Type, cols []*schemasvr.Column) (exportWriter, error) {
+	var names = make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(names); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter{w: cw, record: make([]string, len(cols))}, nil
+	case FormatJSONLines:
+		return &jsonExportWriter{w: bufio.NewWriter(w), names: names}, nil
+	case FormatParquet:
+		var columns = make([]parquetColumn, len(cols))
+		var md = make([]string, len(cols))
+		for i, col := range cols {
+			columns[i] = newParquetColumn(col, beanType)
+			md[i] = columns[i].metadata()
+		}
+		pw, err := writer.NewCSVWriterFromWriter(md, w, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetExportWriter{w: pw, columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unsupported data format %v", format)
+}
+
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (ew *csvExportWriter) write(values []interface{}) error {
+	for i, v := range values {
+		ew.record[i] = exportString(v)
+	}
+	return ew.w.Write(ew.record)
+}
+
+func (ew *csvExportWriter) close() error {
+	ew.w.Flush()
+	return ew.w.Error()
+}
+
+type jsonExportWriter struct {
+	w     *bufio.Writer
+	names []string
+}
+
+func (ew *jsonExportWriter) write(values []interface{}) error {
+	// write the keys in the order of the columns
+	if err := ew.w.WriteByte('{'); err != nil {
+		return err
+	}
+	for i, v := range values {
+		if i > 0 {
+			_ = ew.w.WriteByte(',')
+		}
+		name, err := stdjson.Marshal(ew.names[i])
+		if err != nil {
+			return err
+		}
+		data, err := stdjson.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, _ = ew.w.Write(name)
+		_ = ew.w.WriteByte(':')
+		_, _ = ew.w.Write(data)
+	}
+	_, err := ew.w.WriteString("}\n")
+	return err
+}
+
+func (ew *jsonExportWriter) close() error {
+	return ew.w.Flush()
+}
+
+// parquetColumn is the parquet type of an exported column, convertedType is
+// empty when the values have no converted type
+type parquetColumn struct {
+	name          string
+	typ           parquet.Type
+	convertedType string
+}
+
+func (pcol parquetColumn) metadata() string {
+	md := fmt.Sprintf("name=%s, type=%s, repetitiontype=OPTIONAL", pcol.name, pcol.typ)
+	if pcol.convertedType != "" {
+		md += ", convertedtype=" + pcol.convertedType
+	}
+	return md
+}
+
+// newParquetColumn maps a column of the bean to a parquet column
+func newParquetColumn(col *schemasvr.Column, beanType reflect.Type) parquetColumn {
+	var pcol = parquetColumn{name: col.Name, typ: parquet.Type_BYTE_ARRAY, convertedType: "UTF8"}
+	var fieldType = beanType
+	for _, i := range col.FieldIndex {
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		fieldType = fieldType.Field(i).Type
+	}
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Implements(conversionType) || reflect.PtrTo(fieldType).Implements(conversionType) {
+		if col.SQLType.IsBlob() {
+			pcol.convertedType = ""
+		}
+		return pcol
+	}
+	if fieldType.ConvertibleTo(schemasvr.TimeType) {
+		pcol.typ, pcol.convertedType = parquet.Type_INT64, "TIMESTAMP_MICROS"
+		return pcol
+	}
+	if !col.IsJSON && fieldType.Implements(valuerType) {
+		return pcol
+	}
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		pcol.typ, pcol.convertedType = parquet.Type_BOOLEAN, ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		pcol.typ, pcol.convertedType = parquet.Type_INT64, ""
+	case reflect.Float32, reflect.Float64:
+		pcol.typ, pcol.convertedType = parquet.Type_DOUBLE, ""
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.Uint8 {
+			pcol.convertedType = ""
+		} else {
+			pcol.convertedType = "JSON"
+		}
+	case reflect.Map, reflect.Struct, reflect.Array:
+		pcol.convertedType = "JSON"
+	}
+	return pcol
+}
+
+type parquetExportWriter struct {
+	w       *writer.CSVWriter
+	columns []parquetColumn
+}
+
+// write converts the exported values to the types of the parquet columns, a
+// new row is passed each time as the writer keeps the rows until a flush
+func (ew *parquetExportWriter) write(values []interface{}) error {
+	var row = make([]interface{}, len(values))
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		switch ew.columns[i].typ {
+		case parquet.Type_BYTE_ARRAY:
+			if b, ok := v.([]byte); ok {
+				row[i] = string(b)
+			} else {
+				row[i] = exportString(v)
+			}
+		case parquet.Type_INT64:
+			switch t := v.(type) {
+			case int64:
+				row[i] = t
+			case uint64:
+				row[i] = int64(t)
+			case time.Time:
+				row[i] = types.TimeToTIMESTAMP_MICROS(t, true)
+			default:
+				return fmt.Errorf("parquet column %s expects integer but got %T", ew.columns[i].name, v)
+			}
+		default:
+			row[i] = v
+		}
+	}
+	return ew.w.Write(row)
+}
+
+func (ew *parquetExportWriter) close() error {
+	return ew.w.WriteStop()
+}
+
+// importReader reads the records of an import, rowErr is reported when only
+// the record is invalid
+type importReader interface {
+	read() (record map[string]interface{}, rowErr error, err error)
+}
+
+func newImportReader(r io.Reader, format DataFormat) (importReader, []string, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		header = append([]string(nil), header...)
+		return &csvImportReader{r: cr, header: header}, header, nil
+	case FormatJSONLines:
+		return &jsonImportReader{r: bufio.NewReader(r)}, nil, nil
+	case FormatParquet:
+		var ra io.ReaderAt
+		var size int64
+		if seeker, ok := r.(interface {
+			io.ReaderAt
+			io.Seeker
+		}); ok {
+			var err error
+			if size, err = seeker.Seek(0, io.SeekEnd); err != nil {
+				return nil, nil, err
+			}
+			ra = seeker
+		} else {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, nil, err
+			}
+			ra, size = bytes.NewReader(data), int64(len(data))
+		}
+		return newParquetImportReader(ra, size)
+	}
+	return nil, nil, fmt.Errorf("unsupported data format %v", format)
+}
+
+type csvImportReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func (ir *csvImportReader) read() (map[string]interface{}, error, error) {
+	record, err := ir.r.Read()
+	if err == io.EOF {
+		return nil, nil, err
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+			return nil, err, nil
+		}
+		return nil, nil, err
+	}
+	var values = make(map[string]interface{}, len(record))
+	for i, v := range record {
+		values[ir.header[i]] = v
+	}
+	return values, nil, nil
+}
+
+type jsonImportReader struct {
+	r *bufio.Reader
+}
+
+func (ir *jsonImportReader) read() (map[string]interface{}, error, error) {
+	for {
+		line, err := ir.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		dec := stdjson.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var values map[string]interface{}
+		if err := dec.Decode(&values); err != nil {
+			return nil, err, nil
+		}
+		for k, v := range values {
+			if n, ok := v.(stdjson.Number); ok {
+				values[k] = n.String()
+			}
+		}
+		return values, nil, nil
+	}
+}
+
+// parquetFile reads a parquet file through an io.ReaderAt, each Open gets a
+// reader with its own offset
+type parquetFile struct {
+	*io.SectionReader
+	r    io.ReaderAt
+	size int64
+}
+
+func newParquetFile(r io.ReaderAt, size int64) *parquetFile {
+	return &parquetFile{SectionReader: io.NewSectionReader(r, 0, size), r: r, size: size}
+}
+
+func (f *parquetFile) Open(string) (source.ParquetFile, error) {
+	return newParquetFile(f.r, f.size), nil
+}
+
+func (f *parquetFile) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("parquet file is read only")
+}
+
+func (f *parquetFile) Write([]byte) (int, error) {
+	return 0, errors.New("parquet file is read only")
+}
+
+func (f *parquetFile) Close() error {
+	return nil
+}
+
+// parquetImportBatch is the number of rows read from each column at once
+const parquetImportBatch = 1024
+
+type parquetImportReader struct {
+	r       *reader.ParquetReader
+	header  []string
+	columns []*parquet.SchemaElement
+	numRows int64
+	readRow int64
+	batch   [][]interface{}
+	pos     int
+}
+
+// newParquetImportReader opens a parquet file with a flat schema, the nested
+// and repeated columns could not be imported
+func newParquetImportReader(ra io.ReaderAt, size int64) (_ importReader, _ []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid parquet file: %v", r)
+		}
+	}()
+	pr, err := reader.NewParquetColumnReader(newParquetFile(ra, size), 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ir = parquetImportReader{r: pr, numRows: pr.GetNumRows()}
+	for _, elem := range pr.Footer.GetSchema()[1:] {
+		if elem.GetNumChildren() > 0 || elem.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+			return nil, nil, fmt.Errorf("parquet column %s: only flat schemas could be imported", elem.GetName())
+		}
+		ir.header = append(ir.header, elem.GetName())
+		ir.columns = append(ir.columns, elem)
+	}
+	return &ir, ir.header, nil
+}
+
+func (ir *parquetImportReader) read() (map[string]interface{}, error, error) {
+	if ir.pos >= len(ir.batch) {
+		if ir.readRow >= ir.numRows {
+			return nil, nil, io.EOF
+		}
+		if err := ir.readBatch(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var values = make(map[string]interface{}, len(ir.header))
+	for i, name := range ir.header {
+		values[name] = parquetImportValue(ir.columns[i], ir.batch[ir.pos][i])
+	}
+	ir.pos++
+	return values, nil, nil
+}
+
+// readBatch reads the next rows column by column
+func (ir *parquetImportReader) readBatch() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid parquet file: %v", r)
+		}
+	}()
+	var n = ir.numRows - ir.readRow
+	if n > parquetImportBatch {
+		n = parquetImportBatch
+	}
+	var rows = make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = make([]interface{}, len(ir.columns))
+	}
+	for i := range ir.columns {
+		values, _, _, err := ir.r.ReadColumnByIndex(int64(i), n)
+		if err != nil {
+			return err
+		}
+		if int64(len(values)) != n {
+			return fmt.Errorf("parquet column %s has %d of %d rows", ir.header[i], len(values), n)
+		}
+		for j, v := range values {
+			rows[j][i] = v
+		}
+	}
+	ir.batch, ir.pos = rows, 0
+	ir.readRow += n
+	return nil
+}
+
+// parquetImportValue converts the timestamps to time.Time and the byte arrays
+// without a string type to []byte
+func parquetImportValue(elem *parquet.SchemaElement, v interface{}) interface{} {
+	switch t := v.(type) {
+	case int64:
+		if elem.IsSetConvertedType() {
+			switch elem.GetConvertedType() {
+			case parquet.ConvertedType_TIMESTAMP_MILLIS:
+				return types.TIMESTAMP_MILLISToTime(t, true)
+			case parquet.ConvertedType_TIMESTAMP_MICROS:
+				return types.TIMESTAMP_MICROSToTime(t, true)
+			}
+		}
+	case string:
+		switch elem.GetType() {
+		case parquet.Type_INT96:
+			return types.INT96ToTime(t)
+		case parquet.Type_BYTE_ARRAY, parquet.Type_FIXED_LEN_BYTE_ARRAY:
+			if !elem.IsSetConvertedType() {
+				return []byte(t)
+			}
+		}
+	}
+	return v
+}
+
+// ImportRows reads the rows of the format and inserts them into the table of
+// bean, a pointer to a struct. Values are coerced to the field types, the rows
+// failing the coercion or the insert are skipped and reported as
+// ImportErrors. Out of a transaction a failed batch is inserted row by row to
+// find the failed rows, in a transaction the insert error is returned.
+func (session *Session) ImportRows(r io.Reader, format DataFormat, bean interface{}) (int64, error) {
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+	beanValue := reflect.ValueOf(bean)
+	if beanValue.Kind() != reflect.Ptr || beanValue.Elem().Kind() != reflect.Struct {
+		return 0, errors.New("needs a pointer to a struct")
+	}
+	table, err := session.engine.TableInfo(bean)
+	if err != nil {
+		return 0, err
+	}
+	tableName := session.statement.TableName()
+
+	ir, header, err := newImportReader(r, format)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range header {
+		if table.GetColumn(name) == nil {
+			return 0, fmt.Errorf("column %s not found in table %s", name, table.Name)
+		}
+	}
+
+	var (
+		inserted int64
+		rowErrs  ImportErrors
+		beans    = reflect.MakeSlice(reflect.SliceOf(beanValue.Type()), 0, importBatchSize)
+		rowNums  []int
+	)
+	flush := func() error {
+		if beans.Len() == 0 {
+			return nil
+		}
+		n, errs, err := session.importBatch(tableName, beans, rowNums)
+		inserted += n
+		rowErrs = append(rowErrs, errs...)
+		beans = beans.Slice(0, 0)
+		rowNums = rowNums[:0]
+		return err
+	}
+
+	for row := 1; ; row++ {
+		record, rowErr, err := ir.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return inserted, err
+		}
+		if rowErr != nil {
+			rowErrs = append(rowErrs, &RowError{Row: row, Err: rowErr})
+			continue
+		}
+		b := reflect.New(table.Type)
+		if rowErr := session.importRecord(table, b, record); rowErr != nil {
+			rowErr.Row = row
+			rowErrs = append(rowErrs, rowErr)
+			continue
+		}
+		beans = reflect.Append(beans, b)
+		rowNums = append(rowNums, row)
+		if beans.Len() >= importBatchSize {
+			if err := flush(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return inserted, err
+	}
+	if len(rowErrs) > 0 {
+		return inserted, rowErrs
+	}
+	return inserted, nil
+}
+
+func (session *Session) importRecord(table *schemasvr.Table, bean reflect.Value, record map[string]interface{}) *RowError {
+	for name, v := range record {
+		col := table.GetColumn(name)
+		if col == nil {
+			return &RowError{Column: name, Err: errors.New("unknown column")}
+		}
+		if col.MapType == schemasvr.ONLYFROMDB {
+			continue
+		}
+		fieldValue, err := col.ValueOfV(&bean)
+		if err != nil {
+			return &RowError{Column: col.Name, Err: err}
+		}
+		if err := session.importValue(col, *fieldValue, v); err != nil {
+			return &RowError{Column: col.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// importValue coerces an imported value to the field
+func (session *Session) importValue(col *schemasvr.Column, fieldValue reflect.Value, src interface{}) error {
+	if s, ok := src.(string); ok && s == "" && fieldValue.Kind() != reflect.String {
+		src = nil
+	}
+	if src == nil {
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		return nil
+	}
+	if fieldValue.CanAddr() && fieldValue.Addr().Type().Implements(conversionType) {
+		data, err := importBytes(col, src, false)
+		if err != nil {
+			return err
+		}
+		return fieldValue.Addr().Interface().(convert.Conversion).FromDB(data)
+	}
+	if fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+		}
+		return session.importValue(col, fieldValue.Elem(), src)
+	}
+
+	fieldType := fieldValue.Type()
+	if fieldType.ConvertibleTo(schemasvr.TimeType) {
+		var t time.Time
+		switch v := src.(type) {
+		case time.Time:
+			t = v
+		case string:
+			if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				t = parsed
+			} else {
+				parsed, err := convert.String2Time(v, session.engine.TZLocation, session.engine.TZLocation)
+				if err != nil {
+					return err
+				}
+				t = *parsed
+			}
+		default:
+			i, err := convert.AsInt64(src)
+			if err != nil {
+				return fmt.Errorf("unsupported value %T as time", src)
+			}
+			t = time.Unix(i, 0)
+		}
+		fieldValue.Set(reflect.ValueOf(t.In(session.engine.TZLocation)).Convert(fieldType))
+		return nil
+	}
+	if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Uint8 {
+		data, err := importBytes(col, src, true)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetBytes(data)
+		return nil
+	}
+	if fieldValue.CanAddr() && fieldValue.Addr().Type().Implements(scannerType) {
+		return fieldValue.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	switch fieldType.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		data, err := importBytes(col, src, false)
+		if err != nil {
+			return err
+		}
+		return json.DefaultJSONHandler.Unmarshal(data, fieldValue.Addr().Interface())
+	}
+	return convert.AssignValue(fieldValue, src)
+}
+
+// importBytes returns the value as bytes, text is base64 decoded if isBinary
+func importBytes(col *schemasvr.Column, src interface{}, isBinary bool) ([]byte, error) {
+	switch v := src.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if isBinary || col.SQLType.IsBlob() {
+			return base64.StdEncoding.DecodeString(v)
+		}
+		return []byte(v), nil
+	case map[string]interface{}, []interface{}:
+		return stdjson.Marshal(v)
+	}
+	return []byte(exportString(src)), nil
+}
+
+// importBatch inserts the beans, out of a transaction a failed batch is
+// inserted row by row to report the failed rows
+func (session *Session) importBatch(tableName string, beans reflect.Value, rowNums []int) (int64, ImportErrors, error) {
+	if tableName != "" {
+		session.Table(tableName)
+	}
+	n, err := session.Insert(beans.Interface())
+	if err == nil {
+		return n, nil, nil
+	}
+	if !session.isAutoCommit {
+		return 0, nil, &RowError{Row: rowNums[0], Err: err}
+	}
+	var inserted int64
+	var rowErrs ImportErrors
+	for i := 0; i < beans.Len(); i++ {
+		if tableName != "" {
+			session.Table(tableName)
+		}
+		n, err := session.Insert(beans.Index(i).Interface())
+		if err != nil {
+			rowErrs = append(rowErrs, &RowError{Row: rowNums[i], Err: err})
+			continue
+		}
+		inserted += n
+	}
+	return inserted, rowErrs, nil
+}
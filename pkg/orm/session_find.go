@@ -150,7 +150,7 @@ func (session *Session) find(rowsSlicePtr interface{}, condiBean ...interface{})
 	if err != nil {
 		return err
 	}
-	if session.statement.ColumnMap.IsEmpty() && session.canCache() {
+	if session.statement.ColumnMap.IsEmpty() && (session.canCache() || session.canCacheJoin()) {
 		if cacher := session.engine.GetCacher(session.statement.TableName()); cacher != nil &&
 			!session.statement.IsDistinct &&
 			!session.statement.GetUnscoped() {
@@ -259,12 +259,13 @@ func (session *Session) noCacheFind(table *schemasvr.Table, containerValue refle
 	return rows.Err()
 }
 func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr interface{}, args ...interface{}) (err error) {
-	if !session.canCache() ||
+	if !(session.canCache() || session.canCacheJoin()) ||
 		utils.IndexNoCase(sqlStr, "having") != -1 ||
 		utils.IndexNoCase(sqlStr, "group by") != -1 {
 		return ErrCacheFailed
 	}
 	tableName := session.statement.TableName()
+	isJoined := session.statement.JoinStr != ""
 	cacher := session.engine.cacherMgr.GetCacher(tableName)
 	if cacher == nil {
 		return nil
@@ -314,6 +315,9 @@ func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr in
 		if err != nil {
 			return err
 		}
+		if isJoined {
+			session.engine.cacherMgr.AddDependency(tableName, sqlTableNames(newsql, session.engine.backslashEscapes())...)
+		}
 	} else {
 		session.engine.logger.Debugf("[cache] cache hit sql: %v, %v, %v, %v", tableName, sqlStr, newsql, args)
 	}
@@ -369,6 +373,10 @@ func (session *Session) cacheFind(t reflect.Type, sqlStr string, rowsSlicePtr in
 			session.engine.tagParser,
 			session.engine.DatabaseTZ,
 		)
+		session.statement.Encryptor = session.engine.encryptor
+		// the ids are found in the rows of the tenant and the scopes
+		session.statement.AllTenants()
+		session.statement.WithoutScopes()
 		if len(table.PrimaryKeys) == 1 {
 			ff := make([]interface{}, 0, len(ides))
 			for _, ie := range ides {
@@ -0,0 +1,358 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/sql/pkg/builder"
+
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
+	dialectsvr "github.com/bhojpur/dbm/pkg/orm/dialect"
+	"github.com/bhojpur/dbm/pkg/orm/internal/json"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// ErrAuditedTableNoPK the history of an audited table needs its primary key
+var ErrAuditedTableNoPK = errors.New("audited table has no primary key")
+
+// AsOf queries the history of the audited table, Find, Get, Count and the other
+// queries return the rows as they were at t
+func (session *Session) AsOf(t time.Time) *Session {
+	changedAt, err := dialectsvr.FormatColumnTime(session.engine.dialect, session.engine.DatabaseTZ,
+		schemasvr.NewHistoryChangedAtColumn(), t)
+	if err != nil {
+		session.statement.LastError = err
+		return session
+	}
+	// the cached beans are the current rows
+	session.statement.UseCache = false
+	session.statement.AsOf(changedAt)
+	return session
+}
+
+// rowImage holds the values of a row by column name
+type rowImage map[string]interface{}
+
+// historyRecorder records the changes of an audited table in its history table
+type historyRecorder struct {
+	session   *Session
+	table     *schemasvr.Table
+	tableName string
+}
+
+// withHistory runs f which changes the rows of the table in the transaction of
+// the session, or in a new one if the session is not in a transaction, so the
+// changes and their history are committed together
+func (session *Session) withHistory(table *schemasvr.Table, tableName string, f func(rec *historyRecorder) error) error {
+	if len(table.PrimaryKeys) == 0 {
+		return fmt.Errorf("%w: %s", ErrAuditedTableNoPK, tableName)
+	}
+	rec := &historyRecorder{session: session, table: table, tableName: tableName}
+	if !session.isAutoCommit {
+		return f(rec)
+	}
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if err := f(rec); err != nil {
+		_ = session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+// execWithHistory executes the update or delete sqlStr of the rows selected by
+// the from and where clauses and records their images before and after it
+func (session *Session) execWithHistory(op, from, where string, whereArgs []interface{}, sqlStr string, args ...interface{}) (sql.Result, error) {
+	var (
+		table     = session.statement.RefTable
+		tableName = session.statement.TableName()
+	)
+	if table == nil || !table.Audited {
+		return session.exec(sqlStr, args...)
+	}
+	var res sql.Result
+	err := session.withHistory(table, tableName, func(rec *historyRecorder) error {
+		before, err := rec.query(fmt.Sprintf("SELECT * FROM %s%s", from, where), whereArgs...)
+		if err != nil {
+			return err
+		}
+		if res, err = session.exec(sqlStr, args...); err != nil {
+			return err
+		}
+		if op == schemasvr.HistoryDelete {
+			return rec.record(op, before, before)
+		}
+		after, err := rec.queryImages(before)
+		if err != nil {
+			return err
+		}
+		return rec.record(op, before, after)
+	})
+	return res, err
+}
+
+// query returns the images of the rows selected by sqlStr without touching the
+// statement of the session
+func (rec *historyRecorder) query(sqlStr string, args ...interface{}) ([]rowImage, error) {
+	session := rec.session
+	autoReset, lastSQL, lastArgs := session.autoResetStatement, session.lastSQL, session.lastSQLArgs
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement, session.lastSQL, session.lastSQLArgs = autoReset, lastSQL, lastArgs
+	}()
+	rows, err := session.queryRows(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fields, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	var images []rowImage
+	for rows.Next() {
+		results, err := session.engine.scanInterfaces(rows, fields, types)
+		if err != nil {
+			return nil, err
+		}
+		image := make(rowImage, len(fields))
+		for i, name := range fields {
+			col := rec.table.GetColumn(name)
+			if col == nil {
+				continue
+			}
+			image[col.Name], err = rec.imageValue(col, results[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		images = append(images, image)
+	}
+	return images, rows.Err()
+}
+
+// imageValue converts the scanned result of the column, keeping the NULLs
+func (rec *historyRecorder) imageValue(col *schemasvr.Column, result interface{}) (interface{}, error) {
+	if valuer, ok := result.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err != nil || v == nil {
+			return nil, err
+		}
+	}
+	v, err := convert.Interface2Interface(rec.session.engine.TZLocation, result)
+	if err != nil {
+		return nil, err
+	}
+	if bs, ok := v.([]byte); ok && !col.SQLType.IsBlob() {
+		return string(bs), nil
+	}
+	return v, nil
+}
+
+// queryImages returns the current images of the rows with the primary keys of images
+func (rec *historyRecorder) queryImages(images []rowImage) ([]rowImage, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	var conds = make([]builder.Cond, 0, len(images))
+	for _, image := range images {
+		var eq = make(builder.Eq)
+		for _, pk := range rec.table.PrimaryKeys {
+			eq[rec.session.engine.Quote(pk)] = image[pk]
+		}
+		conds = append(conds, eq)
+	}
+	condSQL, condArgs, err := rec.session.statement.GenCondSQL(builder.Or(conds...))
+	if err != nil {
+		return nil, err
+	}
+	return rec.query(fmt.Sprintf("SELECT * FROM %s WHERE %s", rec.session.engine.Quote(rec.tableName), condSQL), condArgs...)
+}
+
+// queryBean returns the current image of the row of the bean
+func (rec *historyRecorder) queryBean(bean interface{}) ([]rowImage, error) {
+	var image = make(rowImage, len(rec.table.PrimaryKeys))
+	for _, col := range rec.table.PKColumns() {
+		v, err := col.ValueOf(bean)
+		if err != nil {
+			return nil, err
+		}
+		image[col.Name] = v.Interface()
+	}
+	return rec.queryImages([]rowImage{image})
+}
+
+// mapKey returns the image of the primary key of a row inserted from a map
+func (rec *historyRecorder) mapKey(columns []string, args []interface{}) (rowImage, error) {
+	var image = make(rowImage, len(rec.table.PrimaryKeys))
+	for _, pk := range rec.table.PrimaryKeys {
+		for i, col := range columns {
+			if strings.EqualFold(col, pk) {
+				image[pk] = args[i]
+			}
+		}
+		if _, ok := image[pk]; !ok {
+			return nil, fmt.Errorf("%w: %s needs the primary key %s in the map", ErrAuditedTableNoPK, rec.tableName, pk)
+		}
+	}
+	return image, nil
+}
+
+// key returns the primary key of the image
+func (rec *historyRecorder) key(image rowImage) string {
+	var values = make([]string, 0, len(rec.table.PrimaryKeys))
+	for _, pk := range rec.table.PrimaryKeys {
+		values = append(values, fmt.Sprint(image[pk]))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// record inserts the images of the rows changed by op into the history table,
+// the updates which changed nothing are left out
+func (rec *historyRecorder) record(op string, before, after []rowImage) error {
+	var (
+		session    = rec.session
+		engine     = session.engine
+		history    = rec.table.HistoryName(rec.tableName)
+		beforeKeys = make(map[string]rowImage, len(before))
+	)
+	for _, image := range before {
+		beforeKeys[rec.key(image)] = image
+	}
+	changedAt, _, err := engine.nowTime(schemasvr.NewHistoryChangedAtColumn())
+	if err != nil {
+		return err
+	}
+	var actor interface{}
+	if s := ctxsvr.Actor(session.ctx); s != "" {
+		actor = s
+	}
+	colNames := append([]string{schemasvr.HistoryOp, schemasvr.HistoryActor, schemasvr.HistoryChangedAt, schemasvr.HistoryBefore},
+		rec.table.ColumnsSeq()...)
+	var places = strings.TrimSuffix(strings.Repeat("?,", len(colNames)), ",")
+	if engine.dialect.Features().AutoincrMode == dialectsvr.SequenceAutoincrMode {
+		colNames = append([]string{schemasvr.HistoryID}, colNames...)
+		places = utils.SeqName(history) + ".nextval," + places
+	}
+	sqlStr := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", engine.Quote(history),
+		engine.dialect.Quoter().Join(colNames, ","), places)
+
+	autoReset, lastSQL, lastArgs := session.autoResetStatement, session.lastSQL, session.lastSQLArgs
+	session.autoResetStatement = false
+	defer func() {
+		session.autoResetStatement, session.lastSQL, session.lastSQLArgs = autoReset, lastSQL, lastArgs
+	}()
+	for _, image := range after {
+		beforeImage, ok := beforeKeys[rec.key(image)]
+		if op == schemasvr.HistoryUpdate && ok && reflect.DeepEqual(beforeImage, image) {
+			continue
+		}
+		var beforeJSON interface{}
+		if ok {
+			bs, err := json.DefaultJSONHandler.Marshal(beforeImage)
+			if err != nil {
+				return err
+			}
+			beforeJSON = string(bs)
+		}
+		args := []interface{}{op, actor, changedAt, beforeJSON}
+		for _, colName := range rec.table.ColumnsSeq() {
+			args = append(args, image[colName])
+		}
+		if _, err := session.exec(sqlStr, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncHistory creates the history table of the audited table named tableName,
+// or adds the columns the table got since
+func (session *Session) syncHistory(table *schemasvr.Table, tableName string, tables []*schemasvr.Table) error {
+	var (
+		engine  = session.engine
+		history = table.HistoryTable(tableName)
+		ctx     = context.Background()
+	)
+	if len(table.PrimaryKeys) == 0 {
+		return fmt.Errorf("%w: %s", ErrAuditedTableNoPK, tableName)
+	}
+	for _, tb := range tables {
+		if !strings.EqualFold(engine.tbNameWithSchema(tb.Name), engine.tbNameWithSchema(history.Name)) {
+			continue
+		}
+		_, oriCols, err := engine.dialect.GetColumns(session.getQueryer(), ctx, history.Name)
+		if err != nil {
+			return err
+		}
+		for _, col := range history.Columns() {
+			var found bool
+			for name := range oriCols {
+				if strings.EqualFold(name, col.Name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				if _, err := session.exec(engine.dialect.AddColumnSQL(history.Name, col)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if engine.dialect.Features().AutoincrMode == dialectsvr.SequenceAutoincrMode {
+		sqlStr, err := engine.dialect.CreateSequenceSQL(ctx, engine.db, utils.SeqName(history.Name))
+		if err != nil {
+			return err
+		}
+		if _, err := session.exec(sqlStr); err != nil {
+			return err
+		}
+	}
+	sqlStr, _, err := engine.dialect.CreateTableSQL(ctx, engine.db, history, history.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := session.exec(sqlStr); err != nil {
+		return err
+	}
+	for _, index := range history.Indexes {
+		if _, err := session.exec(engine.dialect.CreateIndexSQL(history.Name, index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -91,6 +91,28 @@ func (session *Session) insertMultipleStruct(rowsSlicePtr interface{}) (int64, e
 	if len(tableName) == 0 {
 		return 0, ErrTableNotFound
 	}
+	if table := session.statement.RefTable; table.Audited {
+		// the history needs the primary key of every inserted row
+		var affected int64
+		err := session.withHistory(table, tableName, func(*historyRecorder) error {
+			for i := 0; i < sliceValue.Len(); i++ {
+				v := sliceValue.Index(i)
+				if v.Kind() == reflect.Interface {
+					v = v.Elem()
+				}
+				if v.Kind() != reflect.Ptr && v.CanAddr() {
+					v = v.Addr()
+				}
+				cnt, err := session.insertStruct(v.Interface())
+				if err != nil {
+					return err
+				}
+				affected += cnt
+			}
+			return nil
+		})
+		return affected, err
+	}
 	var (
 		table          = session.statement.RefTable
 		size           = sliceValue.Len()
@@ -124,6 +146,9 @@ func (session *Session) insertMultipleStruct(rowsSlicePtr interface{}) (int64, e
 				return 0, err
 			}
 			fieldValue := *ptrFieldValue
+			if err := session.statement.SetTenantValue(col, fieldValue); err != nil {
+				return 0, err
+			}
 			if col.IsAutoIncrement && utils.IsZero(fieldValue.Interface()) {
 				if session.engine.dialect.Features().AutoincrMode == dialectsvr.SequenceAutoincrMode {
 					if i == 0 {
@@ -234,6 +259,7 @@ func (session *Session) insertMultipleStruct(rowsSlicePtr interface{}) (int64, e
 				}
 			}
 		}
+		session.emitEvent(EventCreated, tableName, elemValue, colNames, 1)
 	}
 	cleanupProcessorsClosures(&session.afterClosures)
 	return res.RowsAffected()
@@ -257,6 +283,26 @@ func (session *Session) insertStruct(bean interface{}) (int64, error) {
 	if len(session.statement.TableName()) == 0 {
 		return 0, ErrTableNotFound
 	}
+	if table := session.statement.RefTable; table.Audited {
+		var affected int64
+		err := session.withHistory(table, session.statement.TableName(), func(rec *historyRecorder) error {
+			var err error
+			if affected, err = session.insertStructRow(bean); err != nil {
+				return err
+			}
+			after, err := rec.queryBean(bean)
+			if err != nil {
+				return err
+			}
+			return rec.record(schemasvr.HistoryInsert, nil, after)
+		})
+		return affected, err
+	}
+	return session.insertStructRow(bean)
+}
+
+// insertStructRow inserts the row of the bean into the table of the statement
+func (session *Session) insertStructRow(bean interface{}) (int64, error) {
 	// handle BeforeInsertProcessor
 	for _, closure := range session.beforeClosures {
 		closure(bean)
@@ -301,6 +347,7 @@ func (session *Session) insertStruct(bean interface{}) (int64, error) {
 			}
 		}
 		cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
+		session.emitEvent(EventCreated, tableName, bean, colNames, 1)
 	}
 	// if there is auto increment column and driver don't support return it
 	if len(table.AutoIncrement) > 0 && !session.engine.driver.Features().SupportReturnInsertedID {
@@ -414,6 +461,7 @@ func (session *Session) cacheInsert(table string) error {
 	if !session.statement.UseCache {
 		return nil
 	}
+	session.engine.clearDependentIds(table)
 	cacher := session.engine.cacherMgr.GetCacher(table)
 	if cacher == nil {
 		return nil
@@ -459,6 +507,9 @@ func (session *Session) genInsertColumns(bean interface{}) ([]string, []interfac
 			return nil, nil, err
 		}
 		fieldValue := *fieldValuePtr
+		if err := session.statement.SetTenantValue(col, fieldValue); err != nil {
+			return nil, nil, err
+		}
 		if col.IsAutoIncrement && utils.IsValueZero(fieldValue) {
 			continue
 		}
@@ -595,7 +646,35 @@ func (session *Session) insertMap(columns []string, args []interface{}) (int64,
 	if len(tableName) == 0 {
 		return 0, ErrTableNotFound
 	}
-	sql, args, err := session.statement.GenInsertMapSQL(columns, args)
+	columns, argss, err := session.statement.TenantMapValues(columns, [][]interface{}{args})
+	if err != nil {
+		return 0, err
+	}
+	args = argss[0]
+	if table := session.statement.RefTable; table != nil && table.Audited {
+		var affected int64
+		err := session.withHistory(table, tableName, func(rec *historyRecorder) error {
+			key, err := rec.mapKey(columns, args)
+			if err != nil {
+				return err
+			}
+			if affected, err = session.insertMapRow(tableName, columns, args); err != nil {
+				return err
+			}
+			after, err := rec.queryImages([]rowImage{key})
+			if err != nil {
+				return err
+			}
+			return rec.record(schemasvr.HistoryInsert, nil, after)
+		})
+		return affected, err
+	}
+	return session.insertMapRow(tableName, columns, args)
+}
+
+// insertMapRow inserts the row of the map into the table
+func (session *Session) insertMapRow(tableName string, columns []string, args []interface{}) (int64, error) {
+	sql, sqlArgs, err := session.statement.GenInsertMapSQL(columns, args)
 	if err != nil {
 		return 0, err
 	}
@@ -603,7 +682,7 @@ func (session *Session) insertMap(columns []string, args []interface{}) (int64,
 	if err := session.cacheInsert(tableName); err != nil {
 		return 0, err
 	}
-	res, err := session.exec(sql, args...)
+	res, err := session.exec(sql, sqlArgs...)
 	if err != nil {
 		return 0, err
 	}
@@ -611,6 +690,7 @@ func (session *Session) insertMap(columns []string, args []interface{}) (int64,
 	if err != nil {
 		return 0, err
 	}
+	session.emitEvent(EventCreated, tableName, mapBean(columns, args), columns, affected)
 	return affected, nil
 }
 func (session *Session) insertMultipleMap(columns []string, argss [][]interface{}) (int64, error) {
@@ -618,6 +698,25 @@ func (session *Session) insertMultipleMap(columns []string, argss [][]interface{
 	if len(tableName) == 0 {
 		return 0, ErrTableNotFound
 	}
+	columns, argss, err := session.statement.TenantMapValues(columns, argss)
+	if err != nil {
+		return 0, err
+	}
+	if table := session.statement.RefTable; table != nil && table.Audited {
+		// the history needs the primary key of every inserted row
+		var affected int64
+		err := session.withHistory(table, tableName, func(*historyRecorder) error {
+			for _, args := range argss {
+				cnt, err := session.insertMap(columns, args)
+				if err != nil {
+					return err
+				}
+				affected += cnt
+			}
+			return nil
+		})
+		return affected, err
+	}
 	sql, args, err := session.statement.GenInsertMultipleMapSQL(columns, argss)
 	if err != nil {
 		return 0, err
@@ -634,5 +733,19 @@ func (session *Session) insertMultipleMap(columns []string, argss [][]interface{
 	if err != nil {
 		return 0, err
 	}
+	for _, args := range argss {
+		session.emitEvent(EventCreated, tableName, mapBean(columns, args), columns, 1)
+	}
 	return affected, nil
 }
+
+// mapBean returns the values of the columns as the bean of an event
+func mapBean(columns []string, args []interface{}) map[string]interface{} {
+	bean := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		if i < len(args) {
+			bean[col] = args[i]
+		}
+	}
+	return bean
+}
@@ -22,9 +22,9 @@ package orm
 
 import (
 	"database/sql"
-	"strings"
 
 	"github.com/bhojpur/dbm/pkg/orm/core"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
 )
 
 func (session *Session) queryPreprocess(sqlStr *string, paramStr ...interface{}) {
@@ -34,7 +34,7 @@ func (session *Session) queryPreprocess(sqlStr *string, paramStr ...interface{})
 	session.lastSQL = *sqlStr
 	session.lastSQLArgs = paramStr
 }
-func (session *Session) queryRows(sqlStr string, args ...interface{}) (*core.Rows, error) {
+func (session *Session) queryRows(sqlStr string, args ...interface{}) (rows *core.Rows, err error) {
 	defer session.resetStatement()
 	if session.statement.LastError != nil {
 		return nil, session.statement.LastError
@@ -42,9 +42,25 @@ func (session *Session) queryRows(sqlStr string, args ...interface{}) (*core.Row
 	session.queryPreprocess(&sqlStr, args...)
 	session.lastSQL = sqlStr
 	session.lastSQLArgs = args
+	// the common table expressions are followed by a SELECT or a write
+	op := utils.SQLOperation(sqlStr, session.engine.backslashEscapes())
+	isSelect := op == "SELECT"
+	if utils.IsWriteOperation(op) || session.statement.IsForUpdate {
+		// e.g. INSERT ... RETURNING
+		markWritten(session.ctx)
+	}
+	if !isSelect {
+		inv := newRawInvalidation(sqlStr, session.engine.backslashEscapes())
+		defer func() {
+			if err == nil {
+				session.applyRawInvalidation(inv)
+			}
+		}()
+	}
 	if session.isAutoCommit {
 		var db *core.DB
-		if session.sessionType == groupSession && strings.EqualFold(strings.TrimSpace(sqlStr)[:6], "select") && !session.statement.IsForUpdate {
+		if session.sessionType == groupSession && isSelect && !session.statement.IsForUpdate &&
+			!session.engine.engineGroup.mustReadMaster(session.ctx) {
 			db = session.engine.engineGroup.Slave().DB()
 		} else {
 			db = session.DB()
@@ -144,6 +160,7 @@ func (session *Session) exec(sqlStr string, args ...interface{}) (sql.Result, er
 	session.queryPreprocess(&sqlStr, args...)
 	session.lastSQL = sqlStr
 	session.lastSQLArgs = args
+	markWritten(session.ctx)
 	if !session.isAutoCommit {
 		if session.prepareStmt {
 			stmt, err := session.doPrepareTx(sqlStr)
@@ -176,5 +193,11 @@ func (session *Session) Exec(sqlOrArgs ...interface{}) (sql.Result, error) {
 	if err != nil {
 		return nil, err
 	}
-	return session.exec(sqlStr, args...)
+	inv := session.prepareRawInvalidation(sqlStr, args)
+	res, err := session.exec(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	session.applyRawInvalidation(inv)
+	return res, nil
 }
@@ -274,6 +274,11 @@ func (session *Session) Sync(beans ...interface{}) error {
 				break
 			}
 		}
+		if table.Audited {
+			if err = session.syncHistory(table, tbName, tables); err != nil {
+				return err
+			}
+		}
 		// this is a new table
 		if oriTable == nil {
 			err = session.StoreEngine(session.statement.StoreEngine).createTable(bean)
@@ -320,7 +325,7 @@ func (session *Session) Sync(beans ...interface{}) error {
 					strings.HasPrefix(curType, schemasvr.Varchar) {
 					// currently only support mysql & postgres
 					if engine.dialect.URI().DBType == schemasvr.MYSQL ||
-						engine.dialect.URI().DBType == schemasvr.POSTGRES {
+						engine.dialect.URI().DBType.IsPostgres() {
 						engine.logger.Infof("Table %s column %s change type from %s to %s\n",
 							tbNameWithSchema, col.Name, curType, expectedType)
 						_, err = session.exec(engine.dialect.ModifyColumnSQL(tbNameWithSchema, col))
@@ -0,0 +1,162 @@
+package orm
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+)
+
+// Change represents a column of a tracked bean changed since it was loaded,
+// the values are the ones put into the database
+type Change struct {
+	Column string
+	Old    interface{}
+	New    interface{}
+}
+
+// Tracked enables the tracked mode of the session, the beans loaded by Get and
+// Find remember the values of their columns until the session is closed, so
+// Update writes only the changed columns and Changes reports them. The beans are
+// identified by their primary keys.
+func (session *Session) Tracked() *Session {
+	if session.tracked == nil {
+		session.tracked = make(map[string]map[string]interface{})
+	}
+	return session
+}
+
+// Changes returns the changed columns of a bean loaded by the tracked session
+func (session *Session) Changes(bean interface{}) ([]Change, error) {
+	if session.tracked == nil {
+		return nil, ErrNotTracked
+	}
+	table, err := session.engine.TableInfo(bean)
+	if err != nil {
+		return nil, err
+	}
+	changes, ok, err := session.changes(table, bean)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotTracked
+	}
+	return changes, nil
+}
+
+// trackKey returns the key of the bean in the tracked values, false if the
+// bean has no primary key
+func (session *Session) trackKey(table *schemasvr.Table, bean interface{}) (string, bool, error) {
+	pkCols := table.PKColumns()
+	if len(pkCols) == 0 {
+		return "", false, nil
+	}
+	keys := make([]string, 0, len(pkCols)+1)
+	keys = append(keys, table.Name)
+	for _, col := range pkCols {
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return "", false, err
+		}
+		keys = append(keys, fmt.Sprint(fieldValue.Interface()))
+	}
+	return strings.Join(keys, "\x00"), true, nil
+}
+
+// track remembers the values of the columns loaded into the bean
+func (session *Session) track(table *schemasvr.Table, bean interface{}, cols []*schemasvr.Column) error {
+	key, ok, err := session.trackKey(table, bean)
+	if err != nil || !ok {
+		return err
+	}
+	values := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return err
+		}
+		if values[col.Name], err = session.statement.PlainValue(col, *fieldValue); err != nil {
+			return err
+		}
+	}
+	session.tracked[key] = values
+	return nil
+}
+
+// retrack remembers the current values of the tracked columns of the bean
+func (session *Session) retrack(table *schemasvr.Table, bean interface{}) error {
+	key, ok, err := session.trackKey(table, bean)
+	if err != nil || !ok {
+		return err
+	}
+	values, ok := session.tracked[key]
+	if !ok {
+		return nil
+	}
+	cols := make([]*schemasvr.Column, 0, len(values))
+	for name := range values {
+		if col := table.GetColumn(name); col != nil {
+			cols = append(cols, col)
+		}
+	}
+	return session.track(table, bean, cols)
+}
+
+// changes returns the changed columns of the bean in the order of the table,
+// false if the bean is not tracked
+func (session *Session) changes(table *schemasvr.Table, bean interface{}) ([]Change, bool, error) {
+	key, ok, err := session.trackKey(table, bean)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	values, ok := session.tracked[key]
+	if !ok {
+		return nil, false, nil
+	}
+	var changes []Change
+	for _, col := range table.Columns() {
+		old, ok := values[col.Name]
+		// the updated time is written by every update
+		if !ok || col.IsUpdated {
+			continue
+		}
+		fieldValue, err := col.ValueOf(bean)
+		if err != nil {
+			return nil, false, err
+		}
+		v, err := session.statement.PlainValue(col, *fieldValue)
+		if err != nil {
+			return nil, false, err
+		}
+		if !reflect.DeepEqual(old, v) {
+			changes = append(changes, Change{
+				Column: col.Name,
+				Old:    old,
+				New:    v,
+			})
+		}
+	}
+	return changes, true, nil
+}
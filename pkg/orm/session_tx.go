@@ -20,10 +20,35 @@ package orm
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
 // Begin a transaction
 func (session *Session) Begin() error {
+	return session.begin(nil)
+}
+
+// BeginTx begins a transaction in the context with the isolation level and
+// the read only flag of opts, nil opts means the defaults of the driver. The
+// read only transactions of a session of an EngineGroup run on a slave.
+func (session *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) error {
+	if ctx != nil {
+		session.Context(ctx)
+	}
+	return session.begin(opts)
+}
+func (session *Session) begin(opts *sql.TxOptions) error {
 	if session.isAutoCommit {
-		tx, err := session.DB().BeginTx(session.ctx, nil)
+		db := session.DB()
+		if opts != nil && opts.ReadOnly && session.sessionType == groupSession &&
+			!session.engine.engineGroup.mustReadMaster(session.ctx) {
+			db = session.engine.engineGroup.Slave().DB()
+		}
+		tx, err := db.BeginTx(session.ctx, opts)
 		if err != nil {
 			return err
 		}
@@ -41,6 +66,8 @@ func (session *Session) Rollback() error {
 		session.saveLastSQL("ROLL BACK")
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
+		session.txCacheTables = nil
+		session.dropTxEvents("")
 		return session.tx.Rollback()
 	}
 	return nil
@@ -53,6 +80,7 @@ func (session *Session) Commit() error {
 		session.isCommitedOrRollbacked = true
 		session.isAutoCommit = true
 		if err := session.tx.Commit(); err != nil {
+			session.dropTxEvents("")
 			return err
 		}
 		// handle processors after tx committed
@@ -89,6 +117,8 @@ func (session *Session) Commit() error {
 		cleanUpFunc(&session.afterInsertBeans)
 		cleanUpFunc(&session.afterUpdateBeans)
 		cleanUpFunc(&session.afterDeleteBeans)
+		session.clearTxCacheTables()
+		session.publishTxEvents()
 	}
 	return nil
 }
@@ -97,3 +127,99 @@ func (session *Session) Commit() error {
 func (session *Session) IsInTx() bool {
 	return !session.isAutoCommit
 }
+
+var savepointNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// execSavepointSQL runs a savepoint statement generated by the dialect in the transaction
+func (session *Session) execSavepointSQL(name string, genSQL func(string) string) error {
+	if session.isAutoCommit {
+		return ErrNotInTransaction
+	}
+	if !savepointNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	sqlStr := genSQL(name)
+	if sqlStr == "" {
+		return ErrSavepointUnsupported
+	}
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(session.ctx, sqlStr)
+	return err
+}
+
+// Savepoint creates a savepoint with the name in the current transaction
+func (session *Session) Savepoint(name string) error {
+	if err := session.execSavepointSQL(name, session.engine.dialect.SavepointSQL); err != nil {
+		return err
+	}
+	session.markTxEvents(name)
+	return nil
+}
+
+// RollbackTo rolls back the work done after the savepoint with the name was
+// created, the transaction stays open
+func (session *Session) RollbackTo(name string) error {
+	if err := session.execSavepointSQL(name, session.engine.dialect.RollbackToSavepointSQL); err != nil {
+		return err
+	}
+	session.dropTxEvents(name)
+	return nil
+}
+
+// Release destroys the savepoint with the name and keeps the work done after it,
+// it does nothing on the databases which keep the savepoints until the transaction ends
+func (session *Session) Release(name string) error {
+	if session.isAutoCommit {
+		return ErrNotInTransaction
+	}
+	if session.engine.dialect.ReleaseSavepointSQL(name) == "" {
+		return nil
+	}
+	return session.execSavepointSQL(name, session.engine.dialect.ReleaseSavepointSQL)
+}
+
+// Transaction runs f in a transaction which is committed if f returns no error
+// and rolled back otherwise. If the session is already in a transaction, f runs
+// in a savepoint nested in it, so only the work of f is rolled back on errors
+// and the outer transaction decides whether it is committed.
+func (session *Session) Transaction(f func(*Session) (interface{}, error)) (interface{}, error) {
+	return session.transaction(nil, f)
+}
+
+// transaction begins the transaction with opts, which are ignored by the
+// transactions nested in savepoints
+func (session *Session) transaction(opts *sql.TxOptions, f func(*Session) (interface{}, error)) (interface{}, error) {
+	if session.isAutoCommit {
+		if err := session.begin(opts); err != nil {
+			return nil, err
+		}
+		result, err := f(session)
+		if err != nil {
+			_ = session.Rollback()
+			return result, err
+		}
+		if err := session.Commit(); err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+	session.txDepth++
+	defer func() {
+		session.txDepth--
+	}()
+	name := fmt.Sprintf("orm_savepoint_%d", session.txDepth)
+	if err := session.Savepoint(name); err != nil {
+		return nil, err
+	}
+	result, err := f(session)
+	if err != nil {
+		if rollbackErr := session.RollbackTo(name); rollbackErr != nil {
+			return result, rollbackErr
+		}
+		return result, err
+	}
+	if err := session.Release(name); err != nil {
+		return result, err
+	}
+	return result, nil
+}
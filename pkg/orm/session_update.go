@@ -145,6 +145,7 @@ func (session *Session) cacheUpdate(table *schemasvr.Table, tableName, sqlStr st
 	}
 	session.engine.logger.Debugf("[cache] clear cached table sql: %v", tableName)
 	cacher.ClearIds(tableName)
+	session.engine.clearDependentIds(tableName)
 	return nil
 }
 
@@ -178,6 +179,7 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	var err error
 	var isMap = t.Kind() == reflect.Map
 	var isStruct = t.Kind() == reflect.Struct
+	var isTracked bool
 	if isStruct {
 		if err := session.statement.SetRefBean(bean); err != nil {
 			return 0, err
@@ -185,7 +187,32 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		if len(session.statement.TableName()) == 0 {
 			return 0, ErrTableNotFound
 		}
-		if session.statement.ColumnStr() == "" {
+		var changes []Change
+		if session.tracked != nil && !session.statement.HasColumnRules() {
+			if changes, isTracked, err = session.changes(session.statement.RefTable, bean); err != nil {
+				return 0, err
+			}
+		}
+		if isTracked {
+			// only the changed columns of the bean, the one loaded if there is no condition
+			if len(changes) == 0 && len(session.statement.IncrColumns) == 0 &&
+				len(session.statement.DecrColumns) == 0 && len(session.statement.ExprColumns) == 0 {
+				return 0, nil
+			}
+			if !session.statement.HasConds() && len(condiBean) == 0 {
+				pk, err := session.statement.RefTable.IDOfV(reflect.ValueOf(bean))
+				if err != nil {
+					return 0, err
+				}
+				session.statement.ID(pk)
+			}
+			if len(changes) > 0 {
+				for _, change := range changes {
+					session.statement.Cols(change.Column)
+				}
+				colNames, args, err = session.genUpdateColumns(bean)
+			}
+		} else if session.statement.ColumnStr() == "" {
 			colNames, args, err = session.statement.BuildUpdates(v, false, false,
 				false, false, true)
 		} else {
@@ -198,10 +225,15 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		colNames = make([]string, 0)
 		args = make([]interface{}, 0)
 		bValue := reflect.Indirect(reflect.ValueOf(bean))
+		var keys = make([]string, 0, bValue.Len())
 		for _, v := range bValue.MapKeys() {
+			keys = append(keys, v.String())
 			colNames = append(colNames, session.engine.Quote(v.String())+" = ?")
 			args = append(args, bValue.MapIndex(v).Interface())
 		}
+		if args, err = session.statement.EncryptMapValues(keys, args); err != nil {
+			return 0, err
+		}
 	} else {
 		return 0, ErrParamsType
 	}
@@ -316,6 +348,11 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		doIncVer = isStruct && (table != nil && table.Version != "" && session.statement.CheckVersion)
 		verValue *reflect.Value
 	)
+	scopeCond, err := session.statement.CondScopes(table, false)
+	if err != nil {
+		return 0, err
+	}
+	cond = cond.And(scopeCond)
 	if doIncVer {
 		verValue, err = table.VersionColumn().ValueOf(bean)
 		if err != nil {
@@ -344,24 +381,13 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	var top string
 	if st.LimitN != nil {
 		limitValue := *st.LimitN
-		switch session.engine.dialect.URI().DBType {
-		case schemasvr.MYSQL:
-			condSQL += fmt.Sprintf(" LIMIT %d", limitValue)
-		case schemasvr.SQLITE:
-			tempCondSQL := condSQL + fmt.Sprintf(" LIMIT %d", limitValue)
-			cond = cond.And(builder.Expr(fmt.Sprintf("rowid IN (SELECT rowid FROM %v %v)",
-				session.engine.Quote(tableName), tempCondSQL), condArgs...))
-			condSQL, condArgs, err = session.statement.GenCondSQL(cond)
-			if err != nil {
-				return 0, err
-			}
-			if len(condSQL) > 0 {
-				condSQL = "WHERE " + condSQL
-			}
-		case schemasvr.POSTGRES:
-			tempCondSQL := condSQL + fmt.Sprintf(" LIMIT %d", limitValue)
-			cond = cond.And(builder.Expr(fmt.Sprintf("CTID IN (SELECT CTID FROM %v %v)",
-				session.engine.Quote(tableName), tempCondSQL), condArgs...))
+		limitSQL := fmt.Sprintf(" LIMIT %d", limitValue)
+		switch dbType := session.engine.dialect.URI().DBType; {
+		case dbType == schemasvr.MYSQL || dbType == schemasvr.COCKROACH:
+			condSQL += limitSQL
+		case dbType == schemasvr.SQLITE || dbType.IsPostgres():
+			inSQL, _ := session.statement.LimitRowsCond(session.engine.Quote(tableName), condSQL+limitSQL)
+			cond = cond.And(builder.Expr(inSQL, condArgs...))
 			condSQL, condArgs, err = session.statement.GenCondSQL(cond)
 			if err != nil {
 				return 0, err
@@ -369,7 +395,7 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 			if len(condSQL) > 0 {
 				condSQL = "WHERE " + condSQL
 			}
-		case schemasvr.MSSQL:
+		case dbType == schemasvr.MSSQL:
 			if st.OrderStr != "" && table != nil && len(table.PrimaryKeys) == 1 {
 				cond = builder.Expr(fmt.Sprintf("%s IN (SELECT TOP (%d) %s FROM %v%v)",
 					table.PrimaryKeys[0], limitValue, table.PrimaryKeys[0],
@@ -403,7 +429,12 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		strings.Join(colNames, ", "),
 		fromSQL,
 		condSQL)
-	res, err := session.exec(sqlStr, append(args, condArgs...)...)
+	var selectFrom = tableAlias
+	if fromSQL != "" {
+		selectFrom = strings.TrimSpace(strings.TrimPrefix(fromSQL, "FROM "))
+	}
+	res, err := session.execWithHistory(schemasvr.HistoryUpdate, selectFrom, " "+condSQL, condArgs,
+		sqlStr, append(args, condArgs...)...)
 	if err != nil {
 		return 0, err
 	} else if doIncVer {
@@ -417,6 +448,9 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 		cacher.ClearIds(tableName)
 		cacher.ClearBeans(tableName)
 	}
+	if session.statement.UseCache {
+		session.engine.clearDependentIds(tableName)
+	}
 	// handle after update processors
 	if session.isAutoCommit {
 		for _, closure := range session.afterClosures {
@@ -445,7 +479,17 @@ func (session *Session) Update(bean interface{}, condiBean ...interface{}) (int6
 	}
 	cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
 	// --
-	return res.RowsAffected()
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if isTracked && affected > 0 {
+		if err := session.retrack(table, bean); err != nil {
+			return 0, err
+		}
+	}
+	session.emitEvent(EventUpdated, tableName, bean, session.updatedColumns(colNames), affected)
+	return affected, nil
 }
 func (session *Session) genUpdateColumns(bean interface{}) ([]string, []interface{}, error) {
 	table := session.statement.RefTable
@@ -471,6 +515,9 @@ func (session *Session) genUpdateColumns(bean interface{}) ([]string, []interfac
 		if (col.IsDeleted && !session.statement.GetUnscoped()) || col.IsCreated {
 			continue
 		}
+		if col.IsTenant && !session.statement.GetAllTenants() {
+			continue
+		}
 		// if only update specify columns
 		if len(session.statement.ColumnMap) > 0 && !session.statement.ColumnMap.Contain(col.Name) {
 			continue
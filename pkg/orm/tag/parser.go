@@ -40,6 +40,8 @@ import (
 var (
 	// ErrUnsupportedType represents an unsupported type error
 	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrEncryptedColumnType the ciphertexts of an encrypted column need a text or binary type
+	ErrEncryptedColumnType = errors.New("encrypted column needs a text or blob type")
 )
 
 // Parser represents a parser for ORM tag
@@ -115,6 +117,21 @@ func (parser *Parser) ParseWithCache(v reflect.Value) (*schema.Table, error) {
 	return table, nil
 }
 
+// CachedTable returns the parsed table with the name, the tables scoped to a
+// tenant are preferred when several structs are mapped to the name
+func (parser *Parser) CachedTable(name string) *schema.Table {
+	var found *schema.Table
+	parser.tableCache.Range(func(_, v interface{}) bool {
+		table := v.(*schema.Table)
+		if table.Name != name {
+			return true
+		}
+		found = table
+		return table.TenantColumn() == nil
+	})
+	return found
+}
+
 // ClearCacheTable removes the database mapper of a type from the cache
 func (parser *Parser) ClearCacheTable(t reflect.Type) {
 	parser.tableCache.Delete(t)
@@ -136,6 +153,25 @@ func addIndex(indexName string, table *schema.Table, col *schema.Column, indexTy
 	}
 }
 
+// addJSONIndex adds an index on the value at the JSON path of the column
+func addJSONIndex(indexName string, table *schema.Table, col *schema.Column, path string) {
+	index, ok := table.Indexes[indexName]
+	if !ok {
+		index = schema.NewIndex(indexName, schema.IndexType)
+		table.AddIndex(index)
+	}
+	index.AddJSONPath(col.Name, path)
+	col.Indexes[index.Name] = index.Type
+}
+
+// jsonPathIndexName returns the words of a JSON path joined by underscores
+func jsonPathIndexName(path string) string {
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
+
 // ErrIgnoreField represents an error to ignore field
 var ErrIgnoreField = errors.New("field will be ignored")
 
@@ -196,11 +232,12 @@ func (parser *Parser) parseFieldWithTags(table *schema.Table, fieldIndex int, fi
 		DefaultIsEmpty:  true,
 	}
 	var ctx = Context{
-		table:      table,
-		col:        col,
-		fieldValue: fieldValue,
-		indexNames: make(map[string]int),
-		parser:     parser,
+		table:        table,
+		col:          col,
+		fieldValue:   fieldValue,
+		indexNames:   make(map[string]int),
+		indexMethods: make(map[string]string),
+		parser:       parser,
 	}
 	for j, tag := range tags {
 		if ctx.ignoreNext {
@@ -239,6 +276,24 @@ func (parser *Parser) parseFieldWithTags(table *schema.Table, fieldIndex int, fi
 			parser.cacherMgr.SetCacher(table.Name, nil)
 		}
 	}
+	if col.IsEncrypted {
+		if col.SQLType.Name == "" {
+			// the deterministic ciphertexts are compared, so they can be indexed
+			if col.IsDeterministic {
+				col.SQLType = schema.SQLType{Name: schema.Varchar, DefaultLength: convert.CiphertextLen(255)}
+			} else {
+				col.SQLType = schema.SQLType{Name: schema.Text}
+			}
+		} else if col.SQLType.IsJson() {
+			// the JSON is encrypted, the database sees a text
+			col.SQLType = schema.SQLType{Name: schema.Text}
+		} else if !col.SQLType.IsText() && !col.SQLType.IsBlob() {
+			return nil, fmt.Errorf("%w: %s", ErrEncryptedColumnType, field.Name)
+		} else if col.Length > 0 {
+			// the declared length is the one of the plaintexts
+			col.Length = convert.CiphertextLen(col.Length)
+		}
+	}
 	if col.SQLType.Name == "" {
 		var err error
 		col.SQLType, err = parser.getSQLTypeByType(field.Type)
@@ -267,6 +322,21 @@ func (parser *Parser) parseFieldWithTags(table *schema.Table, fieldIndex int, fi
 	for indexName, indexType := range ctx.indexNames {
 		addIndex(indexName, table, col, indexType)
 	}
+	for indexName, method := range ctx.indexMethods {
+		if indexName == "" {
+			indexName = col.Name
+		}
+		table.Indexes[indexName].Method = method
+	}
+	for _, idx := range ctx.jsonIndexes {
+		if err := dialect.CheckJSONPath(idx.path); err != nil {
+			return nil, err
+		}
+		if idx.name == "" {
+			idx.name = col.Name + "_" + jsonPathIndexName(idx.path)
+		}
+		addJSONIndex(idx.name, table, col, idx.path)
+	}
 	return col, nil
 }
 func (parser *Parser) parseField(table *schema.Table, fieldIndex int, field reflect.StructField, fieldValue reflect.Value) (*schema.Column, error) {
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/bhojpur/dbm/pkg/orm/cache"
+	"github.com/bhojpur/dbm/pkg/orm/convert"
 	"github.com/bhojpur/dbm/pkg/orm/dialect"
 	"github.com/bhojpur/dbm/pkg/orm/name"
 	"github.com/bhojpur/dbm/pkg/orm/schema"
@@ -416,6 +417,27 @@ func TestParseWithVersion(t *testing.T) {
 	assert.True(t, table.Columns()[1].Nullable)
 	assert.True(t, table.Columns()[1].IsVersion)
 }
+func TestParseWithShardKey(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("mysql"),
+		name.SnakeMapper{},
+		name.GonicMapper{},
+		cache.NewManager(),
+	)
+	type StructWithShardKey struct {
+		Id       int64
+		TenantId int64 `db:"shardkey index"`
+	}
+	table, err := parser.Parse(reflect.ValueOf(new(StructWithShardKey)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(table.Columns()))
+	assert.EqualValues(t, "tenant_id", table.ShardKey)
+	assert.False(t, table.Columns()[0].IsShardKey)
+	assert.True(t, table.Columns()[1].IsShardKey)
+	assert.False(t, table.Columns()[1].Nullable)
+	assert.EqualValues(t, table.Columns()[1], table.ShardKeyColumn())
+}
 func TestParseWithLocale(t *testing.T) {
 	parser := NewParser(
 		"db",
@@ -501,6 +523,158 @@ func TestParseWithJSON(t *testing.T) {
 	assert.EqualValues(t, "default1", table.Columns()[0].Name)
 	assert.True(t, table.Columns()[0].IsJSON)
 }
+func TestParseWithJSONIndex(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("postgres"),
+		name.SnakeMapper{},
+		name.SnakeMapper{},
+		cache.NewManager(),
+	)
+	type StructWithJSONIndex struct {
+		Attrs map[string]interface{} `db:"jsonb gin jsonindex($.address.city) jsonindex(kind, '$.kind')"`
+	}
+	table, err := parser.Parse(reflect.ValueOf(new(StructWithJSONIndex)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, len(table.Columns()))
+	assert.True(t, table.Columns()[0].IsJSON)
+	assert.EqualValues(t, "JSONB", table.Columns()[0].SQLType.Name)
+	assert.EqualValues(t, 3, len(table.Columns()[0].Indexes))
+	assert.EqualValues(t, "GIN", table.Indexes["attrs"].Method)
+	assert.False(t, table.Indexes["attrs"].IsExpr())
+	assert.EqualValues(t, []string{"attrs"}, table.Indexes["attrs_address_city"].Cols)
+	assert.EqualValues(t, []string{"$.address.city"}, table.Indexes["attrs_address_city"].Paths)
+	assert.EqualValues(t, []string{"$.kind"}, table.Indexes["kind"].Paths)
+
+	type StructWithBadJSONIndex struct {
+		Attrs string `db:"json jsonindex(address.city)"`
+	}
+	_, err = parser.Parse(reflect.ValueOf(new(StructWithBadJSONIndex)))
+	assert.ErrorIs(t, err, dialect.ErrInvalidJSONPath)
+}
+func TestParseWithAudit(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("mysql"),
+		name.SnakeMapper{},
+		name.SnakeMapper{},
+		cache.NewManager(),
+	)
+	type StructWithAudit struct {
+		Id   int64 `db:"pk autoincr audit"`
+		Name string
+	}
+	table, err := parser.Parse(reflect.ValueOf(new(StructWithAudit)))
+	assert.NoError(t, err)
+	assert.True(t, table.Audited)
+	assert.EqualValues(t, "struct_with_audit_history", table.HistoryName("struct_with_audit"))
+
+	type StructWithHistory struct {
+		Id   int64  `db:"pk autoincr"`
+		Name string `db:"history(name_log)"`
+	}
+	table, err = parser.Parse(reflect.ValueOf(new(StructWithHistory)))
+	assert.NoError(t, err)
+	assert.True(t, table.Audited)
+	assert.EqualValues(t, "name_log", table.HistoryName("struct_with_history"))
+
+	history := table.HistoryTable("struct_with_history")
+	assert.EqualValues(t, "name_log", history.Name)
+	assert.EqualValues(t, []string{schema.HistoryID}, history.PrimaryKeys)
+	assert.EqualValues(t, []string{schema.HistoryID, schema.HistoryOp, schema.HistoryActor,
+		schema.HistoryChangedAt, schema.HistoryBefore, "id", "name"}, history.ColumnsSeq())
+	assert.True(t, history.GetColumn("id").Nullable)
+	assert.False(t, history.GetColumn("id").IsAutoIncrement)
+	assert.EqualValues(t, []string{"id"}, history.Indexes["pk"].Cols)
+}
+
+func TestParseWithEncrypted(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("mysql"),
+		name.SnakeMapper{},
+		name.SnakeMapper{},
+		cache.NewManager(),
+	)
+	type StructWithEncrypted struct {
+		Token      string            `db:"encrypted"`
+		NationalId string            `db:"encrypted(deterministic) unique"`
+		Age        int               `db:"encrypted"`
+		Attrs      map[string]string `db:"json encrypted"`
+		Raw        []byte            `db:"blob encrypted"`
+		Email      string            `db:"varchar(100) encrypted(deterministic)"`
+	}
+	table, err := parser.Parse(reflect.ValueOf(new(StructWithEncrypted)))
+	assert.NoError(t, err)
+	token := table.GetColumn("token")
+	assert.True(t, token.IsEncrypted)
+	assert.False(t, token.IsDeterministic)
+	assert.EqualValues(t, schema.Text, token.SQLType.Name)
+	nationalID := table.GetColumn("national_id")
+	assert.True(t, nationalID.IsDeterministic)
+	assert.EqualValues(t, schema.Varchar, nationalID.SQLType.Name)
+	assert.EqualValues(t, convert.CiphertextLen(255), nationalID.Length)
+	assert.EqualValues(t, convert.CiphertextLen(100), table.GetColumn("email").Length)
+	assert.EqualValues(t, schema.Text, table.GetColumn("age").SQLType.Name)
+	assert.EqualValues(t, schema.Text, table.GetColumn("attrs").SQLType.Name)
+	assert.True(t, table.GetColumn("attrs").IsJSON)
+	assert.EqualValues(t, schema.Blob, table.GetColumn("raw").SQLType.Name)
+
+	type StructWithBadEncrypted struct {
+		Age int `db:"int encrypted"`
+	}
+	_, err = parser.Parse(reflect.ValueOf(new(StructWithBadEncrypted)))
+	assert.ErrorIs(t, err, ErrEncryptedColumnType)
+
+	type StructWithBadMode struct {
+		Token string `db:"encrypted(fast)"`
+	}
+	_, err = parser.Parse(reflect.ValueOf(new(StructWithBadMode)))
+	assert.Error(t, err)
+}
+
+func TestParseWithTenant(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("mysql"),
+		name.SnakeMapper{},
+		name.SnakeMapper{},
+		cache.NewManager(),
+	)
+	type StructWithTenant struct {
+		Id       int64
+		TenantId int64 `db:"tenant index"`
+		Name     string
+	}
+	table, err := parser.Parse(reflect.ValueOf(new(StructWithTenant)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "tenant_id", table.Tenant)
+	assert.True(t, table.TenantColumn().IsTenant)
+	assert.False(t, table.TenantColumn().Nullable)
+	assert.EqualValues(t, 1, len(table.Indexes))
+}
+
+func TestCachedTable(t *testing.T) {
+	parser := NewParser(
+		"db",
+		dialect.QueryDialect("mysql"),
+		name.SnakeMapper{},
+		name.SnakeMapper{},
+		cache.NewManager(),
+	)
+	type CachedTenant struct {
+		Id       int64
+		TenantId int64 `db:"tenant"`
+	}
+	assert.Nil(t, parser.CachedTable("cached_tenant"))
+	_, err := parser.ParseWithCache(reflect.ValueOf(new(CachedTenant)).Elem())
+	assert.NoError(t, err)
+	table := parser.CachedTable("cached_tenant")
+	if assert.NotNil(t, table) {
+		assert.EqualValues(t, "tenant_id", table.Tenant)
+	}
+}
+
 func TestParseWithSQLType(t *testing.T) {
 	parser := NewParser(
 		"db",
@@ -105,6 +105,8 @@ type Context struct {
 	isIndex         bool
 	isUnique        bool
 	indexNames      map[string]int
+	indexMethods    map[string]string
+	jsonIndexes     []jsonIndex
 	parser          *Parser
 	hasCacheTag     bool
 	hasNoCacheTag   bool
@@ -112,34 +114,46 @@ type Context struct {
 	isUnsigned      bool
 }
 
+// jsonIndex is an index on the value at a JSON path of the column
+type jsonIndex struct {
+	name, path string
+}
+
 // Handler describes tag handler for ORM
 type Handler func(ctx *Context) error
 
 var (
 	// defaultTagHandlers enumerates all the default tag handler
 	defaultTagHandlers = map[string]Handler{
-		"-":        IgnoreHandler,
-		"<-":       OnlyFromDBTagHandler,
-		"->":       OnlyToDBTagHandler,
-		"PK":       PKTagHandler,
-		"NULL":     NULLTagHandler,
-		"NOT":      NotTagHandler,
-		"AUTOINCR": AutoIncrTagHandler,
-		"DEFAULT":  DefaultTagHandler,
-		"CREATED":  CreatedTagHandler,
-		"UPDATED":  UpdatedTagHandler,
-		"DELETED":  DeletedTagHandler,
-		"VERSION":  VersionTagHandler,
-		"UTC":      UTCTagHandler,
-		"LOCAL":    LocalTagHandler,
-		"NOTNULL":  NotNullTagHandler,
-		"INDEX":    IndexTagHandler,
-		"UNIQUE":   UniqueTagHandler,
-		"CACHE":    CacheTagHandler,
-		"NOCACHE":  NoCacheTagHandler,
-		"COMMENT":  CommentTagHandler,
-		"EXTENDS":  ExtendsTagHandler,
-		"UNSIGNED": UnsignedTagHandler,
+		"-":         IgnoreHandler,
+		"<-":        OnlyFromDBTagHandler,
+		"->":        OnlyToDBTagHandler,
+		"PK":        PKTagHandler,
+		"NULL":      NULLTagHandler,
+		"NOT":       NotTagHandler,
+		"AUTOINCR":  AutoIncrTagHandler,
+		"DEFAULT":   DefaultTagHandler,
+		"CREATED":   CreatedTagHandler,
+		"UPDATED":   UpdatedTagHandler,
+		"DELETED":   DeletedTagHandler,
+		"VERSION":   VersionTagHandler,
+		"UTC":       UTCTagHandler,
+		"LOCAL":     LocalTagHandler,
+		"NOTNULL":   NotNullTagHandler,
+		"INDEX":     IndexTagHandler,
+		"UNIQUE":    UniqueTagHandler,
+		"GIN":       GINTagHandler,
+		"AUDIT":     AuditTagHandler,
+		"HISTORY":   AuditTagHandler,
+		"JSONINDEX": JSONIndexTagHandler,
+		"CACHE":     CacheTagHandler,
+		"NOCACHE":   NoCacheTagHandler,
+		"COMMENT":   CommentTagHandler,
+		"EXTENDS":   ExtendsTagHandler,
+		"UNSIGNED":  UnsignedTagHandler,
+		"SHARDKEY":  ShardKeyTagHandler,
+		"TENANT":    TenantTagHandler,
+		"ENCRYPTED": EncryptedTagHandler,
 	}
 )
 
@@ -266,6 +280,21 @@ func DeletedTagHandler(ctx *Context) error {
 	return nil
 }
 
+// ShardKeyTagHandler describes shardkey tag handler
+func ShardKeyTagHandler(ctx *Context) error {
+	ctx.col.IsShardKey = true
+	ctx.col.Nullable = false
+	return nil
+}
+
+// TenantTagHandler describes tenant tag handler, the queries on the table are
+// scoped to the tenant of the context
+func TenantTagHandler(ctx *Context) error {
+	ctx.col.IsTenant = true
+	ctx.col.Nullable = false
+	return nil
+}
+
 // IndexTagHandler describes index tag handler
 func IndexTagHandler(ctx *Context) error {
 	if len(ctx.params) > 0 {
@@ -286,6 +315,37 @@ func UniqueTagHandler(ctx *Context) error {
 	return nil
 }
 
+// GINTagHandler describes a GIN index tag handler, postgres uses it to index
+// the keys and values of jsonb columns, the other databases create a regular index
+func GINTagHandler(ctx *Context) error {
+	var name string
+	if len(ctx.params) > 0 {
+		name = ctx.params[0]
+		ctx.indexNames[name] = schema.IndexType
+	} else {
+		ctx.isIndex = true
+	}
+	ctx.indexMethods[name] = "GIN"
+	return nil
+}
+
+// JSONIndexTagHandler describes an index on the value at a JSON path of the
+// column, jsonindex($.a.b) or jsonindex(name, $.a.b)
+func JSONIndexTagHandler(ctx *Context) error {
+	switch len(ctx.params) {
+	case 1:
+		ctx.jsonIndexes = append(ctx.jsonIndexes, jsonIndex{path: strings.Trim(ctx.params[0], "' ")})
+	case 2:
+		ctx.jsonIndexes = append(ctx.jsonIndexes, jsonIndex{
+			name: ctx.params[0],
+			path: strings.Trim(ctx.params[1], "' "),
+		})
+	default:
+		return fmt.Errorf("jsonindex tag needs a JSON path and an optional index name: %v", ctx.params)
+	}
+	return nil
+}
+
 // UnsignedTagHandler represents the column is unsigned
 func UnsignedTagHandler(ctx *Context) error {
 	ctx.isUnsigned = true
@@ -303,7 +363,7 @@ func CommentTagHandler(ctx *Context) error {
 // SQLTypeTagHandler describes SQL Type tag handler
 func SQLTypeTagHandler(ctx *Context) error {
 	ctx.col.SQLType = schema.SQLType{Name: ctx.tagUname}
-	if ctx.tagUname == "JSON" {
+	if ctx.col.SQLType.IsJson() {
 		ctx.col.IsJSON = true
 	}
 	if len(ctx.params) == 0 {
@@ -403,6 +463,31 @@ func CacheTagHandler(ctx *Context) error {
 	return nil
 }
 
+// AuditTagHandler describes audit tag handler, the changes of the table are
+// recorded in a history table, audit(name) names the history table
+func AuditTagHandler(ctx *Context) error {
+	ctx.table.Audited = true
+	if len(ctx.params) > 0 {
+		ctx.table.History = strings.Trim(ctx.params[0], "' ")
+	}
+	return nil
+}
+
+// EncryptedTagHandler describes encrypted tag handler, encrypted(deterministic)
+// encrypts the equal values to the equal ciphertexts so they can be compared
+func EncryptedTagHandler(ctx *Context) error {
+	ctx.col.IsEncrypted = true
+	for _, param := range ctx.params {
+		switch strings.ToUpper(strings.Trim(param, "' ")) {
+		case "DETERMINISTIC":
+			ctx.col.IsDeterministic = true
+		default:
+			return fmt.Errorf("unknown encrypted mode %s", param)
+		}
+	}
+	return nil
+}
+
 // NoCacheTagHandler describes nocache tag handler
 func NoCacheTagHandler(ctx *Context) error {
 	if !ctx.hasNoCacheTag {
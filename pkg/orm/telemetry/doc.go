@@ -0,0 +1,37 @@
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package telemetry provides an OpenTelemetry hook for the ORM. Every SQL
+// statement executed by an engine is wrapped in a client span and its latency
+// is recorded in a histogram labelled by operation and table.
+//
+//	engine, err := orm.NewEngine("mysql", dsn)
+//	if err != nil {
+//		return err
+//	}
+//	if _, err := telemetry.Instrument(engine); err != nil {
+//		return err
+//	}
+//	// spans are children of the span carried by ctx
+//	err = engine.Context(ctx).Find(&users)
+//
+// For an EngineGroup, use InstrumentGroup so that spans and measurements tell
+// the master and the slaves apart.
+package telemetry
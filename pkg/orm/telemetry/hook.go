@@ -0,0 +1,258 @@
+package telemetry
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	ctxsvr "github.com/bhojpur/dbm/pkg/orm/context"
+	"github.com/bhojpur/dbm/pkg/orm/internal/utils"
+	schemasvr "github.com/bhojpur/dbm/pkg/orm/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/bhojpur/dbm/pkg/orm"
+
+	// RoleKey is the attribute telling whether the statement was sent to the
+	// master or to a slave of an EngineGroup
+	RoleKey = attribute.Key("db.orm.role")
+	// RowsAffectedKey is the attribute holding the rows affected by an
+	// INSERT, UPDATE or DELETE
+	RowsAffectedKey = attribute.Key("db.orm.rows_affected")
+	// ErrorKey is the attribute telling whether the statement failed
+	ErrorKey = attribute.Key("error")
+	// TxAttemptKey is the attempt of the retried transaction running the statement
+	TxAttemptKey = attribute.Key("db.orm.tx_attempt")
+)
+
+// Option configures a Hook
+type Option func(*Hook)
+
+// WithTracerProvider sets the tracer provider, default is the global one
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(h *Hook) {
+		h.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the meter provider, default is the global one
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(h *Hook) {
+		h.meterProvider = provider
+	}
+}
+
+// WithAttributes adds attributes to every span and measurement
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(h *Hook) {
+		h.attrs = append(h.attrs, attrs...)
+	}
+}
+
+// WithRawStatement records db.statement exactly as executed. By default
+// literals in the SQL are replaced with placeholders, and the arguments are
+// never recorded.
+func WithRawStatement() Option {
+	return func(h *Hook) {
+		h.rawStatement = true
+	}
+}
+
+// WithBackslashEscapes tells that the string literals of the database escape
+// their quotes with a backslash, as in MySQL, so that they are redacted as a
+// whole. Instrument sets it from the dialect of the engine.
+func WithBackslashEscapes() Option {
+	return func(h *Hook) {
+		h.backslashEscapes = true
+	}
+}
+
+// Hook implements ctxsvr.Hook to trace the executed SQLs and to measure their
+// latencies.
+type Hook struct {
+	tracerProvider   trace.TracerProvider
+	meterProvider    metric.MeterProvider
+	tracer           trace.Tracer
+	latency          metric.Float64Histogram
+	attrs            []attribute.KeyValue
+	rawStatement     bool
+	backslashEscapes bool
+}
+
+var (
+	_ ctxsvr.Hook = &Hook{}
+)
+
+// NewHook creates a Hook, the attributes of the database should be passed
+// via WithAttributes. Use Instrument to get them from an engine.
+func NewHook(opts ...Option) (*Hook, error) {
+	h := &Hook{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  global.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.tracer = h.tracerProvider.Tracer(instrumentationName)
+	latency, err := h.meterProvider.Meter(instrumentationName).NewFloat64Histogram(
+		"db.client.duration",
+		metric.WithDescription("Duration of the SQL statements"),
+		metric.WithUnit(unit.Milliseconds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	h.latency = latency
+	return h, nil
+}
+
+type spanKey struct{}
+
+type spanInfo struct {
+	span  trace.Span
+	attrs []attribute.KeyValue
+}
+
+// BeforeProcess implements ctxsvr.Hook
+func (h *Hook) BeforeProcess(c *ctxsvr.ContextHook) (context.Context, error) {
+	op := utils.SQLOperation(c.SQL, h.backslashEscapes)
+	attrs := make([]attribute.KeyValue, 0, len(h.attrs)+2)
+	attrs = append(attrs, h.attrs...)
+	attrs = append(attrs, semconv.DBOperationKey.String(op))
+	spanName := op
+	if tables := utils.SQLTables(c.SQL, h.backslashEscapes); len(tables) > 0 {
+		attrs = append(attrs, semconv.DBSQLTableKey.String(tables[0]))
+		spanName = op + " " + tables[0]
+	}
+	if spanName == "" {
+		spanName = "SQL"
+	}
+	statement := c.SQL
+	if !h.rawStatement {
+		statement = utils.RedactSQL(statement, h.backslashEscapes)
+	}
+	spanAttrs := []attribute.KeyValue{semconv.DBStatementKey.String(statement)}
+	if c.TxAttempt > 0 {
+		spanAttrs = append(spanAttrs, TxAttemptKey.Int(c.TxAttempt))
+	}
+	ctx, span := h.tracer.Start(c.Ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(spanAttrs...),
+	)
+	return context.WithValue(ctx, spanKey{}, &spanInfo{span: span, attrs: attrs}), nil
+}
+
+// AfterProcess implements ctxsvr.Hook
+func (h *Hook) AfterProcess(c *ctxsvr.ContextHook) error {
+	info, ok := c.Ctx.Value(spanKey{}).(*spanInfo)
+	if !ok {
+		return nil
+	}
+	if c.Result != nil {
+		if affected, err := c.Result.RowsAffected(); err == nil {
+			info.span.SetAttributes(RowsAffectedKey.Int64(affected))
+		}
+	}
+	if c.Err != nil {
+		info.span.RecordError(c.Err)
+		info.span.SetStatus(codes.Error, c.Err.Error())
+	}
+	info.span.End()
+
+	h.latency.Record(c.Ctx, float64(c.ExecuteTime)/1e6,
+		append(info.attrs, ErrorKey.Bool(c.Err != nil))...)
+	return nil
+}
+
+// DBSystem returns the db.system attribute of the database type
+func DBSystem(dbType schemasvr.DBType) attribute.KeyValue {
+	switch dbType {
+	case schemasvr.MYSQL:
+		return semconv.DBSystemMySQL
+	case schemasvr.POSTGRES:
+		return semconv.DBSystemPostgreSQL
+	case schemasvr.SQLITE:
+		return semconv.DBSystemSqlite
+	case schemasvr.MSSQL:
+		return semconv.DBSystemMSSQL
+	case schemasvr.ORACLE:
+		return semconv.DBSystemOracle
+	case schemasvr.COCKROACH:
+		return semconv.DBSystemCockroachdb
+	}
+	return semconv.DBSystemKey.String(strings.ToLower(string(dbType)))
+}
+
+func engineAttributes(engine *orm.Engine) []attribute.KeyValue {
+	uri := engine.Dialect().URI()
+	attrs := []attribute.KeyValue{DBSystem(uri.DBType)}
+	if uri.DBName != "" {
+		attrs = append(attrs, semconv.DBNameKey.String(uri.DBName))
+	}
+	if uri.User != "" {
+		attrs = append(attrs, semconv.DBUserKey.String(uri.User))
+	}
+	if uri.Host != "" {
+		attrs = append(attrs, semconv.NetPeerNameKey.String(uri.Host))
+	}
+	return attrs
+}
+
+// Instrument creates a Hook for the engine and adds it to the engine
+func Instrument(engine *orm.Engine, opts ...Option) (*Hook, error) {
+	engineOpts := []Option{WithAttributes(engineAttributes(engine)...)}
+	if engine.Dialect().URI().DBType == schemasvr.MYSQL {
+		engineOpts = append(engineOpts, WithBackslashEscapes())
+	}
+	hook, err := NewHook(append(engineOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	engine.AddHook(hook)
+	return hook, nil
+}
+
+// InstrumentGroup adds a Hook to every engine of the group, the role of the
+// engine, master or slave-N, is recorded in the db.orm.role attribute.
+func InstrumentGroup(eg *orm.EngineGroup, opts ...Option) error {
+	if _, err := Instrument(eg.Master(), append([]Option{WithAttributes(RoleKey.String("master"))}, opts...)...); err != nil {
+		return err
+	}
+	for i, slave := range eg.Slaves() {
+		role := RoleKey.String(fmt.Sprintf("slave-%d", i))
+		if _, err := Instrument(slave, append([]Option{WithAttributes(role)}, opts...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package telemetry
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bhojpur/dbm/pkg/orm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+type TelemetryUser struct {
+	Id   int64
+	Name string
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestHook(t *testing.T) {
+	engine, err := orm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "telemetry.db"))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	meterProvider := metrictest.NewMeterProvider()
+	_, err = Instrument(engine, WithTracerProvider(tracerProvider), WithMeterProvider(meterProvider))
+	assert.NoError(t, err)
+
+	assert.NoError(t, engine.Sync(new(TelemetryUser)))
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	_, err = engine.Context(ctx).Insert(&TelemetryUser{Name: "secret"})
+	assert.NoError(t, err)
+	_, err = engine.Context(ctx).Exec("UPDATE telemetry_user SET name = 'another secret' WHERE id = 1")
+	assert.NoError(t, err)
+	_, err = engine.Context(ctx).Exec("SELECT * FROM not_exist_table")
+	assert.Error(t, err)
+	parent.End()
+
+	var children []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			children = append(children, span)
+		}
+	}
+	assert.EqualValues(t, 3, len(children))
+
+	assert.EqualValues(t, "INSERT telemetry_user", children[0].Name())
+	attrs := spanAttrs(children[0])
+	assert.EqualValues(t, "sqlite", attrs[semconv.DBSystemKey].AsString())
+	assert.EqualValues(t, "telemetry_user", attrs[semconv.DBSQLTableKey].AsString())
+	assert.EqualValues(t, 1, attrs[RowsAffectedKey].AsInt64())
+
+	attrs = spanAttrs(children[1])
+	assert.EqualValues(t, "UPDATE telemetry_user SET name = ? WHERE id = ?", attrs[semconv.DBStatementKey].AsString())
+
+	assert.EqualValues(t, codes.Error, children[2].Status().Code)
+
+	var measured int
+	for _, m := range metrictest.AsStructs(meterProvider.MeasurementBatches) {
+		if m.Name == "db.client.duration" {
+			measured++
+		}
+	}
+	assert.True(t, measured >= 3)
+}
+
+func TestHookRawStatement(t *testing.T) {
+	engine, err := orm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "telemetry.db"))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, err = Instrument(engine, WithTracerProvider(tracerProvider), WithMeterProvider(metrictest.NewMeterProvider()), WithRawStatement())
+	assert.NoError(t, err)
+
+	_, err = engine.Exec("SELECT 'literal'")
+	assert.NoError(t, err)
+	spans := recorder.Ended()
+	assert.EqualValues(t, 1, len(spans))
+	assert.EqualValues(t, "SELECT 'literal'", spanAttrs(spans[0])[semconv.DBStatementKey].AsString())
+}
+
+func TestInstrumentGroup(t *testing.T) {
+	dir := t.TempDir()
+	master, err := orm.NewEngine("sqlite3", filepath.Join(dir, "master.db"))
+	assert.NoError(t, err)
+	slave, err := orm.NewEngine("sqlite3", filepath.Join(dir, "slave.db"))
+	assert.NoError(t, err)
+	eg, err := orm.NewEngineGroup(master, []*orm.Engine{slave})
+	assert.NoError(t, err)
+	defer eg.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	assert.NoError(t, InstrumentGroup(eg, WithTracerProvider(tracerProvider), WithMeterProvider(metrictest.NewMeterProvider())))
+
+	_, err = eg.Exec("CREATE TABLE t (id INTEGER)")
+	assert.NoError(t, err)
+	_, err = eg.Query("SELECT 1")
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	assert.EqualValues(t, 2, len(spans))
+	assert.EqualValues(t, "master", spanAttrs(spans[0])[RoleKey].AsString())
+	assert.EqualValues(t, "slave-0", spanAttrs(spans[1])[RoleKey].AsString())
+}